@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
@@ -113,11 +114,15 @@ func main() {
 
 	// Initialize executor registry
 	registry := executor.NewRegistry()
-	registry.Register(service.NewExecutor())
-	registry.Register(system.NewExecutor())
-	registry.Register(user.NewExecutor())
+	serviceExecutor := service.NewExecutor()
+	registry.Register(serviceExecutor)
+	systemExecutor := system.NewExecutor()
+	registry.Register(systemExecutor)
+	userExecutor := user.NewExecutor(cfg.DataDir)
+	registry.Register(userExecutor)
 	registry.Register(file.NewExecutor())
-	registry.Register(package_executor.NewExecutor())
+	packageExecutor := package_executor.NewExecutor(time.Duration(cfg.PackageLockTimeout) * time.Second)
+	registry.Register(packageExecutor)
 
 	logger.Info().Msg("Executor registry initialized")
 
@@ -125,6 +130,53 @@ func main() {
 	collector := monitor.NewCollector(transportClient, registry, time.Duration(cfg.MetricInterval)*time.Second)
 	go collector.Start(ctx)
 
+	// Start file integrity monitor
+	if cfg.FIMEnabled {
+		fim := monitor.NewFileIntegrityMonitor(transportClient, cfg.FIMPaths,
+			filepath.Join(cfg.DataDir, "fim_baseline.json"), time.Duration(cfg.FIMRescanInterval)*time.Second)
+		go fim.Start(ctx)
+	}
+
+	// Start service watcher
+	if len(cfg.WatchServices) > 0 {
+		watcher := monitor.NewServiceWatcher(transportClient, registry, serviceExecutor, cfg.WatchServices,
+			time.Duration(cfg.WatchInterval)*time.Second, monitor.RestartPolicy{
+				Enabled:        cfg.WatchAutoRestart,
+				InitialBackoff: time.Duration(cfg.WatchRestartBackoff) * time.Second,
+				MaxBackoff:     time.Duration(cfg.WatchRestartMaxBackoff) * time.Second,
+				MaxAttempts:    cfg.WatchRestartAttempts,
+			})
+		go watcher.Start(ctx)
+	}
+
+	// Start package inventory reporter
+	if cfg.PackageInventoryInterval > 0 {
+		inventory := monitor.NewPackageInventoryReporter(transportClient, packageExecutor,
+			time.Duration(cfg.PackageInventoryInterval)*time.Second, filepath.Join(cfg.DataDir, "package_inventory.json"))
+		go inventory.Start(ctx)
+	}
+
+	// Start pending update reporter
+	if cfg.PackageUpdateCheckInterval > 0 {
+		updates := monitor.NewUpdateReporter(transportClient, packageExecutor,
+			time.Duration(cfg.PackageUpdateCheckInterval)*time.Second)
+		go updates.Start(ctx)
+	}
+
+	// Start user inventory reporter
+	if cfg.UserInventoryInterval > 0 {
+		users := monitor.NewUserInventoryReporter(transportClient, userExecutor,
+			time.Duration(cfg.UserInventoryInterval)*time.Second, filepath.Join(cfg.DataDir, "user_inventory.json"))
+		go users.Start(ctx)
+	}
+
+	// Start listening port watcher
+	if cfg.ListenerScanInterval > 0 {
+		listeners := monitor.NewListenerWatcher(transportClient, systemExecutor,
+			time.Duration(cfg.ListenerScanInterval)*time.Second, filepath.Join(cfg.DataDir, "listeners.json"))
+		go listeners.Start(ctx)
+	}
+
 	// Start heartbeat loop
 	go heartbeatLoop(ctx, transportClient, id, time.Duration(cfg.HeartbeatInterval)*time.Second)
 
@@ -33,18 +33,45 @@ type Config struct {
 	DataDir   string `json:"data_dir"`
 	LogDir    string `json:"log_dir"`
 	BufferDir string `json:"buffer_dir"`
+
+	// File Integrity Monitoring
+	FIMEnabled        bool     `json:"fim_enabled"`
+	FIMPaths          []string `json:"fim_paths"`
+	FIMRescanInterval int      `json:"fim_rescan_interval"` // seconds
+
+	// Service Watcher
+	WatchServices          []string `json:"watch_services"`
+	WatchInterval          int      `json:"watch_interval"` // seconds, poll fallback
+	WatchAutoRestart       bool     `json:"watch_auto_restart"`
+	WatchRestartBackoff    int      `json:"watch_restart_backoff"`     // seconds, doubles per attempt
+	WatchRestartMaxBackoff int      `json:"watch_restart_max_backoff"` // seconds
+	WatchRestartAttempts   int      `json:"watch_restart_attempts"`    // 0 = unlimited
+
+	// Package Inventory
+	PackageInventoryInterval   int `json:"package_inventory_interval"`    // seconds, 0 = disabled
+	PackageUpdateCheckInterval int `json:"package_update_check_interval"` // seconds, 0 = disabled
+	PackageLockTimeout         int `json:"package_lock_timeout"`          // seconds to wait for dpkg/rpm locks
+
+	// User Inventory
+	UserInventoryInterval int `json:"user_inventory_interval"` // seconds, 0 = disabled
+
+	// Listening Port Watcher
+	ListenerScanInterval int `json:"listener_scan_interval"` // seconds, 0 = disabled
 }
 
 // DefaultConfig returns platform-specific defaults
 func DefaultConfig() *Config {
 	var dataDir, logDir string
+	var fimPaths []string
 
 	if runtime.GOOS == "windows" {
 		dataDir = filepath.Join(os.Getenv("ProgramData"), "einfra", "agent")
 		logDir = filepath.Join(dataDir, "logs")
+		fimPaths = []string{filepath.Join(os.Getenv("SystemRoot"), "System32", "drivers", "etc")}
 	} else {
 		dataDir = "/var/lib/einfra-agent"
 		logDir = "/var/log/einfra-agent"
+		fimPaths = []string{"/etc", "/usr/bin", "/root/.ssh"}
 	}
 
 	return &Config{
@@ -59,6 +86,22 @@ func DefaultConfig() *Config {
 		CertPath:          filepath.Join(dataDir, "certs", "agent.crt"),
 		KeyPath:           filepath.Join(dataDir, "certs", "agent.key"),
 		CACertPath:        filepath.Join(dataDir, "certs", "ca.crt"),
+		FIMEnabled:        false,
+		FIMPaths:          fimPaths,
+		FIMRescanInterval: 3600,
+
+		WatchInterval:          30,
+		WatchRestartBackoff:    5,
+		WatchRestartMaxBackoff: 300,
+		WatchRestartAttempts:   5,
+
+		PackageInventoryInterval:   3600,
+		PackageUpdateCheckInterval: 21600,
+		PackageLockTimeout:         300,
+
+		UserInventoryInterval: 3600,
+
+		ListenerScanInterval: 60,
 	}
 }
 
@@ -0,0 +1,846 @@
+package file
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"einfra/agent/internal/executor"
+	"einfra/agent/internal/logger"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// defaultMaxExtractSize guards against decompression bombs
+	defaultMaxExtractSize = 10 * 1024 * 1024 * 1024
+	// maxArchiveEntries bounds how many entries one extraction may create
+	maxArchiveEntries = 1000000
+)
+
+// archiveFormat infers the archive format from an explicit parameter or file name
+func archiveFormat(format, name string) (string, error) {
+	if format == "" {
+		lower := strings.ToLower(name)
+		switch {
+		case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+			format = "tar.gz"
+		case strings.HasSuffix(lower, ".tar.zst"), strings.HasSuffix(lower, ".tzst"):
+			format = "tar.zst"
+		case strings.HasSuffix(lower, ".tar"):
+			format = "tar"
+		case strings.HasSuffix(lower, ".zip"):
+			format = "zip"
+		}
+	}
+
+	switch format {
+	case "tar", "tar.gz", "tar.zst", "zip":
+		return format, nil
+	case "":
+		return "", fmt.Errorf("cannot infer archive format from %q, set 'format'", name)
+	default:
+		return "", fmt.Errorf("unsupported archive format: %s", format)
+	}
+}
+
+// createArchive packs one or more paths into a tar, tar.gz, tar.zst or zip archive
+func (e *Executor) createArchive(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	sources := action.StringsParam("sources")
+	if src, ok := action.Params["source"].(string); ok {
+		sources = append(sources, src)
+	}
+	if len(sources) == 0 {
+		result.Success = false
+		result.Error = "missing 'source' parameter"
+		return result
+	}
+
+	dest, ok := action.Params["destination"].(string)
+	if !ok {
+		result.Success = false
+		result.Error = "missing 'destination' parameter"
+		return result
+	}
+
+	format, err := archiveFormat(action.StringParam("format", ""), dest)
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+
+	if fileExists(dest) && !action.BoolParam("overwrite", false) {
+		result.Success = false
+		result.Error = "destination already exists (set 'overwrite' to replace it)"
+		return result
+	}
+
+	// Write to a temp file next to the destination, then rename into place
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".einfra-archive-*")
+	if err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to create archive: %v", err)
+		return result
+	}
+	defer os.Remove(tmp.Name())
+
+	var stats archiveStats
+	if format == "zip" {
+		err = writeZip(ctx, tmp, sources, &stats)
+	} else {
+		err = writeTar(ctx, tmp, format, sources, &stats)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to create archive: %v", err)
+		return result
+	}
+
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to move archive into place: %v", err)
+		return result
+	}
+
+	info, _ := os.Stat(dest)
+	logger.Info().
+		Str("destination", dest).
+		Str("format", format).
+		Int("entries", stats.entries).
+		Msg("Archive created")
+
+	result.Data["destination"] = dest
+	result.Data["format"] = format
+	result.Data["entries"] = stats.entries
+	result.Data["bytes"] = stats.bytes
+	if info != nil {
+		result.Data["archive_size"] = info.Size()
+	}
+	result.Success = true
+	return result
+}
+
+// extractArchive unpacks an archive into a directory, refusing any entry that
+// would land outside of it
+func (e *Executor) extractArchive(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	src, ok := action.Params["source"].(string)
+	if !ok {
+		result.Success = false
+		result.Error = "missing 'source' parameter"
+		return result
+	}
+
+	dest, ok := action.Params["destination"].(string)
+	if !ok {
+		result.Success = false
+		result.Error = "missing 'destination' parameter"
+		return result
+	}
+
+	format, err := archiveFormat(action.StringParam("format", ""), src)
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to create destination: %v", err)
+		return result
+	}
+	root, err := filepath.EvalSymlinks(dest)
+	if err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to resolve destination: %v", err)
+		return result
+	}
+
+	x := &extractor{
+		root:      root,
+		overwrite: action.BoolParam("overwrite", false),
+		// Ownership can only be restored when running as root
+		preserveOwner: action.BoolParam("preserve_owner", true) && runtime.GOOS != "windows" && os.Geteuid() == 0,
+		strip:         action.IntParam("strip_components", 0),
+		maxSize:       action.Int64Param("max_size", defaultMaxExtractSize),
+	}
+
+	if format == "zip" {
+		err = x.extractZip(ctx, src)
+	} else {
+		err = x.extractTar(ctx, src, format)
+	}
+	if err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to extract archive: %v", err)
+		result.Data["entries"] = x.stats.entries
+		return result
+	}
+
+	logger.Info().
+		Str("source", src).
+		Str("destination", root).
+		Int("entries", x.stats.entries).
+		Msg("Archive extracted")
+
+	result.Data["destination"] = root
+	result.Data["format"] = format
+	result.Data["entries"] = x.stats.entries
+	result.Data["bytes"] = x.stats.bytes
+	result.Data["skipped"] = x.skipped
+	result.Success = true
+	return result
+}
+
+// archiveStats counts what was written or extracted
+type archiveStats struct {
+	entries int
+	bytes   int64
+}
+
+// writeTar streams sources into a tar archive with optional compression
+func writeTar(ctx context.Context, out io.Writer, format string, sources []string, stats *archiveStats) error {
+	var (
+		w      = out
+		closer io.Closer
+	)
+	switch format {
+	case "tar.gz":
+		gz := gzip.NewWriter(out)
+		w, closer = gz, gz
+	case "tar.zst":
+		zw, err := zstd.NewWriter(out)
+		if err != nil {
+			return err
+		}
+		w, closer = zw, zw
+	}
+
+	tw := tar.NewWriter(w)
+	for _, src := range sources {
+		if err := walkSource(ctx, src, func(path, name string, info os.FileInfo) error {
+			link := ""
+			if info.Mode()&os.ModeSymlink != 0 {
+				var err error
+				if link, err = os.Readlink(path); err != nil {
+					return err
+				}
+			}
+
+			hdr, err := tar.FileInfoHeader(info, link)
+			if err != nil {
+				return err
+			}
+			hdr.Name = name
+			if info.IsDir() {
+				hdr.Name += "/"
+			}
+			hdr.Uname = lookupUser(hdr.Uid)
+			hdr.Gname = lookupGroup(hdr.Gid)
+
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			stats.entries++
+
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+			n, err := copyFileTo(tw, path)
+			stats.bytes += n
+			return err
+		}); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if closer != nil {
+		return closer.Close()
+	}
+	return nil
+}
+
+// writeZip streams sources into a zip archive
+func writeZip(ctx context.Context, out io.Writer, sources []string, stats *archiveStats) error {
+	zw := zip.NewWriter(out)
+	for _, src := range sources {
+		if err := walkSource(ctx, src, func(path, name string, info os.FileInfo) error {
+			hdr, err := zip.FileInfoHeader(info)
+			if err != nil {
+				return err
+			}
+			hdr.Name = filepath.ToSlash(name)
+			if info.IsDir() {
+				hdr.Name += "/"
+			} else {
+				hdr.Method = zip.Deflate
+			}
+
+			w, err := zw.CreateHeader(hdr)
+			if err != nil {
+				return err
+			}
+			stats.entries++
+
+			switch {
+			case info.Mode()&os.ModeSymlink != 0:
+				// Zip stores the symlink target as the entry content
+				link, err := os.Readlink(path)
+				if err != nil {
+					return err
+				}
+				_, err = io.WriteString(w, link)
+				return err
+			case info.Mode().IsRegular():
+				n, err := copyFileTo(w, path)
+				stats.bytes += n
+				return err
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// walkSource visits src and everything below it, naming entries relative to src's parent
+func walkSource(ctx context.Context, src string, fn func(path, name string, info os.FileInfo) error) error {
+	src = filepath.Clean(src)
+	base := filepath.Dir(src)
+
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// Sockets and devices cannot be archived portably
+		if !info.Mode().IsRegular() && !info.IsDir() && info.Mode()&os.ModeSymlink == 0 {
+			return nil
+		}
+
+		name, err := filepath.Rel(base, path)
+		if err != nil {
+			return err
+		}
+		return fn(path, filepath.ToSlash(name), info)
+	})
+}
+
+// copyFileTo copies a file's content into w
+func copyFileTo(w io.Writer, path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return io.Copy(w, f)
+}
+
+// extractor writes archive entries below root
+type extractor struct {
+	root          string
+	overwrite     bool
+	preserveOwner bool
+	strip         int
+	maxSize       int64
+	stats         archiveStats
+	skipped       []string
+
+	// created holds the regular files and directories written so far; modes
+	// and times are only applied to these
+	created map[string]os.FileInfo
+	// links holds the symlinks created, checked again once all entries exist
+	links []string
+}
+
+// extractTar unpacks a possibly compressed tar archive
+func (x *extractor) extractTar(ctx context.Context, src, format string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	switch format {
+	case "tar.gz":
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	case "tar.zst":
+		zr, err := zstd.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	// Directory modes and times are applied last so restrictive modes don't block children
+	var dirs []*tar.Header
+
+	tr := tar.NewReader(r)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		target, ok, err := x.target(hdr.Name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if err := x.countEntry(hdr.Size); err != nil {
+			return err
+		}
+
+		// FileInfo maps the Unix setuid/setgid/sticky bits to their os.FileMode flags
+		mode := hdr.FileInfo().Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0700); err != nil {
+				return err
+			}
+			x.record(target)
+			dirs = append(dirs, hdr)
+			continue
+		case tar.TypeReg:
+			if err := x.writeFile(target, tr, mode, hdr.Size); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := x.symlink(target, hdr.Linkname); err != nil {
+				return err
+			}
+			if x.preserveOwner {
+				os.Lchown(target, hdr.Uid, hdr.Gid)
+			}
+			continue
+		case tar.TypeLink:
+			linkTarget, ok, err := x.target(hdr.Linkname)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+			// Only files written by this archive, never a symlink that os.Link
+			// would link itself and Chmod would then follow
+			if info, err := os.Lstat(linkTarget); err != nil || !info.Mode().IsRegular() || !x.owns(linkTarget) {
+				return fmt.Errorf("hard link %s must point to a regular file in the archive: %s", hdr.Name, hdr.Linkname)
+			}
+			if err := x.prepare(target); err != nil {
+				return err
+			}
+			if err := os.Link(linkTarget, target); err != nil {
+				return err
+			}
+			x.record(target)
+		default:
+			// Devices and FIFOs are never created from archives
+			x.skipped = append(x.skipped, hdr.Name)
+			continue
+		}
+
+		if err := x.setMeta(target, hdr.Uid, hdr.Gid, mode, hdr.ModTime); err != nil {
+			return err
+		}
+	}
+
+	if err := x.checkLinks(); err != nil {
+		return err
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		hdr := dirs[i]
+		target, _, _ := x.target(hdr.Name)
+		x.setMeta(target, hdr.Uid, hdr.Gid, hdr.FileInfo().Mode()&(os.ModePerm|os.ModeSetgid|os.ModeSticky), hdr.ModTime)
+	}
+
+	return nil
+}
+
+// extractZip unpacks a zip archive. Zip does not carry ownership, so only
+// permissions and times are restored
+func (x *extractor) extractZip(ctx context.Context, src string) error {
+	zr, err := zip.OpenReader(src)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	type dirMeta struct {
+		path    string
+		mode    os.FileMode
+		modTime time.Time
+	}
+	var dirs []dirMeta
+
+	for _, zf := range zr.File {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		target, ok, err := x.target(zf.Name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if err := x.countEntry(int64(zf.UncompressedSize64)); err != nil {
+			return err
+		}
+
+		mode := zf.Mode()
+		switch {
+		case mode.IsDir():
+			if err := os.MkdirAll(target, 0700); err != nil {
+				return err
+			}
+			x.record(target)
+			dirs = append(dirs, dirMeta{target, mode.Perm(), zf.Modified})
+			continue
+		case mode&os.ModeSymlink != 0:
+			link, err := readZipEntry(zf, 4096)
+			if err != nil {
+				return err
+			}
+			if err := x.symlink(target, string(link)); err != nil {
+				return err
+			}
+			continue
+		case mode.IsRegular():
+			rc, err := zf.Open()
+			if err != nil {
+				return err
+			}
+			err = x.writeFile(target, rc, mode.Perm(), int64(zf.UncompressedSize64))
+			rc.Close()
+			if err != nil {
+				return err
+			}
+			if err := x.setMeta(target, -1, -1, mode.Perm(), zf.Modified); err != nil {
+				return err
+			}
+		default:
+			x.skipped = append(x.skipped, zf.Name)
+		}
+	}
+
+	if err := x.checkLinks(); err != nil {
+		return err
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		x.setMeta(dirs[i].path, -1, -1, dirs[i].mode, dirs[i].modTime)
+	}
+
+	return nil
+}
+
+// target maps an archive entry name to a path below root. It returns false for
+// entries removed entirely by strip_components, and an error for any entry
+// that would escape root ("zip slip")
+func (x *extractor) target(name string) (string, bool, error) {
+	clean := filepath.ToSlash(name)
+	if strings.HasPrefix(clean, "/") || filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return "", false, fmt.Errorf("illegal absolute path in archive: %s", name)
+	}
+
+	parts := strings.Split(strings.Trim(clean, "/"), "/")
+	for _, p := range parts {
+		if p == ".." {
+			return "", false, fmt.Errorf("illegal path traversal in archive: %s", name)
+		}
+	}
+	if x.strip > 0 {
+		if len(parts) <= x.strip {
+			return "", false, nil
+		}
+		parts = parts[x.strip:]
+	}
+
+	target := filepath.Join(x.root, filepath.FromSlash(strings.Join(parts, "/")))
+	if !withinRoot(x.root, target) {
+		return "", false, fmt.Errorf("illegal path in archive: %s", name)
+	}
+
+	// A symlink already on disk (from this archive or before) must not
+	// redirect the write outside root, including through directories that
+	// prepare would create below it
+	parent, err := resolveExisting(filepath.Dir(target))
+	if err != nil || !withinRoot(x.root, parent) {
+		return "", false, fmt.Errorf("archive entry escapes destination through a symlink: %s", name)
+	}
+
+	return target, true, nil
+}
+
+// symlink creates a link after checking it resolves inside root
+func (x *extractor) symlink(target, link string) error {
+	if filepath.IsAbs(link) || filepath.VolumeName(link) != "" {
+		return fmt.Errorf("symlink %s points outside destination: %s", target, link)
+	}
+	dir, err := resolveExisting(filepath.Dir(target))
+	if err != nil {
+		return err
+	}
+	resolved, err := resolveLink(dir, link)
+	if err != nil || !withinRoot(x.root, resolved) {
+		return fmt.Errorf("symlink %s points outside destination: %s", target, link)
+	}
+	if err := x.prepare(target); err != nil {
+		return err
+	}
+	if err := os.Symlink(link, target); err != nil {
+		return err
+	}
+	x.links = append(x.links, target)
+	return nil
+}
+
+// checkLinks resolves every symlink again once all entries exist, since a
+// later entry can change what an earlier link points to. Links that now
+// lead outside root are removed
+func (x *extractor) checkLinks() error {
+	for _, target := range x.links {
+		info, err := os.Lstat(target)
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+		link, err := os.Readlink(target)
+		if err != nil {
+			return err
+		}
+		dir, err := resolveExisting(filepath.Dir(target))
+		if err == nil && withinRoot(x.root, dir) {
+			var resolved string
+			if resolved, err = resolveLink(dir, link); err == nil && withinRoot(x.root, resolved) {
+				continue
+			}
+		}
+		os.Remove(target)
+		return fmt.Errorf("symlink %s points outside destination: %s", target, link)
+	}
+	return nil
+}
+
+// record remembers target as created by this archive if it is a regular
+// file or a real directory
+func (x *extractor) record(target string) {
+	info, err := os.Lstat(target)
+	if err != nil || !(info.Mode().IsRegular() || info.IsDir()) {
+		return
+	}
+	if x.created == nil {
+		x.created = make(map[string]os.FileInfo)
+	}
+	x.created[target] = info
+}
+
+// owns reports whether target is still the file or directory this archive
+// created, rather than a symlink or anything else swapped in by a later entry
+func (x *extractor) owns(target string) bool {
+	want, ok := x.created[target]
+	if !ok {
+		return false
+	}
+	info, err := os.Lstat(target)
+	return err == nil && (info.Mode().IsRegular() || info.IsDir()) && os.SameFile(want, info)
+}
+
+// setMeta applies ownership (unless uid is negative), mode and times to an
+// entry this archive created. Anything else is left alone
+func (x *extractor) setMeta(target string, uid, gid int, mode os.FileMode, modTime time.Time) error {
+	if !x.owns(target) {
+		return nil
+	}
+	if x.preserveOwner && uid >= 0 {
+		os.Lchown(target, uid, gid)
+	}
+	// After chown, which clears setuid and setgid
+	if err := os.Chmod(target, mode); err != nil {
+		return err
+	}
+	os.Chtimes(target, modTime, modTime)
+	return nil
+}
+
+// writeFile copies an entry's content to target. The caller sets the final
+// mode, after any chown
+func (x *extractor) writeFile(target string, r io.Reader, mode os.FileMode, size int64) error {
+	if err := x.prepare(target); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+
+	// Never trust the declared size alone
+	n, err := io.Copy(f, io.LimitReader(r, size+1))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if n > size {
+		return fmt.Errorf("entry %s is larger than its declared size", target)
+	}
+	x.record(target)
+	return nil
+}
+
+// prepare makes the parent directory and clears an existing entry when overwriting
+func (x *extractor) prepare(target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	if _, err := os.Lstat(target); err == nil {
+		if !x.overwrite {
+			return fmt.Errorf("%s already exists (set 'overwrite' to replace it)", target)
+		}
+		if err := os.Remove(target); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// countEntry enforces the entry and size limits
+func (x *extractor) countEntry(size int64) error {
+	x.stats.entries++
+	x.stats.bytes += size
+	if x.stats.entries > maxArchiveEntries {
+		return fmt.Errorf("archive has more than %d entries", maxArchiveEntries)
+	}
+	if x.maxSize > 0 && x.stats.bytes > x.maxSize {
+		return fmt.Errorf("archive expands beyond max_size (%d bytes)", x.maxSize)
+	}
+	return nil
+}
+
+// readZipEntry reads a small zip entry fully
+func readZipEntry(zf *zip.File, limit int64) ([]byte, error) {
+	rc, err := zf.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(io.LimitReader(rc, limit))
+}
+
+// resolveExisting resolves the symlinks in the deepest existing ancestor of
+// path and appends the components that do not exist yet
+func resolveExisting(path string) (string, error) {
+	var missing []string
+	for {
+		if _, err := os.Lstat(path); err == nil {
+			break
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			break
+		}
+		missing = append(missing, filepath.Base(path))
+		path = parent
+	}
+
+	// Fails for a dangling symlink, which MkdirAll cannot traverse safely
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+	for i := len(missing) - 1; i >= 0; i-- {
+		resolved = filepath.Join(resolved, missing[i])
+	}
+	return resolved, nil
+}
+
+// resolveLink follows a relative link from dir one component at a time, so
+// ".." applies to where an existing symlink really points rather than to
+// the link text. ".." after a component that does not exist yet is refused,
+// since a later entry could make that component a symlink
+func resolveLink(dir, link string) (string, error) {
+	current := dir
+	missing := false
+	for _, part := range strings.Split(filepath.ToSlash(link), "/") {
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			if missing {
+				return "", fmt.Errorf("link crosses a missing component: %s", link)
+			}
+			current = filepath.Dir(current)
+			continue
+		}
+		current = filepath.Join(current, part)
+		if missing {
+			continue
+		}
+		info, err := os.Lstat(current)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				missing = true
+				continue
+			}
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			if current, err = filepath.EvalSymlinks(current); err != nil {
+				return "", err
+			}
+		}
+	}
+	return current, nil
+}
+
+// withinRoot reports whether path is root or below it
+func withinRoot(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
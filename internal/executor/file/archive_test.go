@@ -0,0 +1,265 @@
+package file
+
+import (
+	"archive/tar"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// tarEntry is one entry of a test archive
+type tarEntry struct {
+	name     string
+	typeflag byte
+	linkname string
+	mode     int64
+	body     string
+}
+
+// buildTar builds an uncompressed tar archive in dir
+func buildTar(t *testing.T, dir string, entries []tarEntry) string {
+	t.Helper()
+	path := filepath.Join(dir, "test.tar")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	tw := tar.NewWriter(f)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Typeflag: e.typeflag, Linkname: e.linkname, Mode: e.mode, Size: int64(len(e.body))}
+		if hdr.Mode == 0 {
+			hdr.Mode = 0644
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// setupOutside creates root and a sibling outside/secret the archive must not touch
+func setupOutside(t *testing.T) (base, root string) {
+	t.Helper()
+	base = t.TempDir()
+	root = filepath.Join(base, "dest")
+	if err := os.MkdirAll(filepath.Join(base, "outside"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(base, "outside", "secret"), []byte("secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	return base, root
+}
+
+// assertOutsideUntouched checks the modes of outside/ and outside/secret
+func assertOutsideUntouched(t *testing.T, base string) {
+	t.Helper()
+	for path, want := range map[string]os.FileMode{
+		filepath.Join(base, "outside"):           0700,
+		filepath.Join(base, "outside", "secret"): 0600,
+	} {
+		info, err := os.Lstat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != want {
+			t.Errorf("%s mode = %o, want %o", path, got, want)
+		}
+	}
+}
+
+func TestExtractTarSymlinkChain(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []tarEntry
+	}{
+		{
+			name: "links created before the symlink they pass through",
+			entries: []tarEntry{
+				{name: "a", typeflag: tar.TypeSymlink, linkname: "b/b/../outside/secret"},
+				{name: "d", typeflag: tar.TypeSymlink, linkname: "b/b/../outside"},
+				{name: "b", typeflag: tar.TypeSymlink, linkname: "."},
+				{name: "h", typeflag: tar.TypeLink, linkname: "a", mode: 0777},
+				{name: "d", typeflag: tar.TypeDir, mode: 0777},
+			},
+		},
+		{
+			name: "links created after the symlink they pass through",
+			entries: []tarEntry{
+				{name: "b", typeflag: tar.TypeSymlink, linkname: "."},
+				{name: "a", typeflag: tar.TypeSymlink, linkname: "b/b/../outside/secret"},
+				{name: "h", typeflag: tar.TypeLink, linkname: "a", mode: 0777},
+			},
+		},
+		{
+			name: "hard link to a symlink inside root",
+			entries: []tarEntry{
+				{name: "f", typeflag: tar.TypeReg, body: "x"},
+				{name: "s", typeflag: tar.TypeSymlink, linkname: "f"},
+				{name: "h", typeflag: tar.TypeLink, linkname: "s", mode: 04777},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base, root := setupOutside(t)
+			src := buildTar(t, t.TempDir(), tt.entries)
+
+			x := &extractor{root: root}
+			if err := x.extractTar(context.Background(), src, "tar"); err == nil {
+				t.Error("extractTar succeeded, want an error")
+			}
+			assertOutsideUntouched(t, base)
+			if info, err := os.Stat(filepath.Join(root, "f")); err == nil && info.Mode().Perm() != 0644 {
+				t.Errorf("f mode = %o, want 644", info.Mode().Perm())
+			}
+		})
+	}
+}
+
+func TestExtractTarDirOverExistingSymlink(t *testing.T) {
+	base, root := setupOutside(t)
+	if err := os.Symlink("../outside", filepath.Join(root, "d")); err != nil {
+		t.Fatal(err)
+	}
+	src := buildTar(t, t.TempDir(), []tarEntry{{name: "d", typeflag: tar.TypeDir, mode: 0777}})
+
+	x := &extractor{root: root}
+	if err := x.extractTar(context.Background(), src, "tar"); err != nil {
+		t.Fatalf("extractTar: %v", err)
+	}
+	assertOutsideUntouched(t, base)
+}
+
+func TestExtractTarHardLink(t *testing.T) {
+	_, root := setupOutside(t)
+	src := buildTar(t, t.TempDir(), []tarEntry{
+		{name: "f", typeflag: tar.TypeReg, body: "x"},
+		{name: "h", typeflag: tar.TypeLink, linkname: "f", mode: 0600},
+	})
+
+	x := &extractor{root: root}
+	if err := x.extractTar(context.Background(), src, "tar"); err != nil {
+		t.Fatalf("extractTar: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(root, "h"))
+	if err != nil || string(data) != "x" {
+		t.Errorf("h = %q, %v; want \"x\"", data, err)
+	}
+}
+
+func TestExtractorTarget(t *testing.T) {
+	base, root := setupOutside(t)
+	if err := os.Symlink(filepath.Join(base, "outside"), filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(".", filepath.Join(root, "self")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		strip int
+		want  string // "" when the entry is dropped
+		err   bool
+	}{
+		{name: "a/b", want: "a/b"},
+		{name: "./a/", want: "a"},
+		{name: "/etc/passwd", err: true},
+		{name: "../x", err: true},
+		{name: "a/../../x", err: true},
+		{name: "a/../b", err: true},
+		{name: "escape/x", err: true},
+		{name: "escape/new/x", err: true},
+		{name: "self/x", want: "self/x"},
+		{name: "top", strip: 1},
+		{name: "top/a/b", strip: 1, want: "a/b"},
+	}
+	for _, tt := range tests {
+		x := &extractor{root: root, strip: tt.strip}
+		got, ok, err := x.target(tt.name)
+		switch {
+		case tt.err:
+			if err == nil {
+				t.Errorf("target(%q) = %q, want an error", tt.name, got)
+			}
+		case err != nil:
+			t.Errorf("target(%q): %v", tt.name, err)
+		case tt.want == "":
+			if ok {
+				t.Errorf("target(%q) = %q, want it dropped", tt.name, got)
+			}
+		case !ok || got != filepath.Join(root, tt.want):
+			t.Errorf("target(%q) = %q, %v; want %q", tt.name, got, ok, filepath.Join(root, tt.want))
+		}
+	}
+}
+
+func TestExtractorSymlink(t *testing.T) {
+	_, root := setupOutside(t)
+	if err := os.Mkdir(filepath.Join(root, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(".", filepath.Join(root, "self")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		target string
+		link   string
+		err    bool
+	}{
+		{target: "l1", link: "sub"},
+		{target: "l2", link: "missing"},
+		{target: "l3", link: "sub/../sub"},
+		{target: "sub/l4", link: "../sub"},
+		{target: "sub/l5", link: "../missing/file"},
+		{target: "l6", link: "/etc/passwd", err: true},
+		{target: "l7", link: "..", err: true},
+		{target: "sub/l8", link: "../../outside", err: true},
+		{target: "l9", link: "missing/../sub", err: true},
+		{target: "l10", link: "self/self/../outside", err: true},
+	}
+	for _, tt := range tests {
+		x := &extractor{root: root}
+		err := x.symlink(filepath.Join(root, tt.target), tt.link)
+		if tt.err != (err != nil) {
+			t.Errorf("symlink(%s -> %s) error = %v, want error %v", tt.target, tt.link, err, tt.err)
+		}
+	}
+}
+
+func TestExtractorCheckLinks(t *testing.T) {
+	base, root := setupOutside(t)
+	x := &extractor{root: root}
+	if err := x.symlink(filepath.Join(root, "a"), "b/secret"); err != nil {
+		t.Fatal(err)
+	}
+	if err := x.checkLinks(); err != nil {
+		t.Fatalf("checkLinks: %v", err)
+	}
+
+	// b appearing later as a link out of root redirects a
+	if err := os.Symlink(filepath.Join(base, "outside"), filepath.Join(root, "b")); err != nil {
+		t.Fatal(err)
+	}
+	if err := x.checkLinks(); err == nil {
+		t.Error("checkLinks succeeded, want an error")
+	}
+	if _, err := os.Lstat(filepath.Join(root, "a")); !os.IsNotExist(err) {
+		t.Errorf("escaping link a was not removed: %v", err)
+	}
+}
@@ -0,0 +1,233 @@
+package file
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxDiffEdits bounds the edit distance diffLines searches. Memory grows with
+// its square, so larger changes are summarized instead of shown
+const maxDiffEdits = 1000
+
+// diffOp is one line of an edit script
+type diffOp struct {
+	kind byte // ' ', '-', '+'
+	line string
+}
+
+// unifiedDiff renders the difference between two texts in unified format
+// with the given number of context lines. It returns "" when they are equal
+func unifiedDiff(oldText, newText, oldName, newName string, context int) string {
+	if oldText == newText {
+		return ""
+	}
+
+	oldLines, newLines := splitKeepEmpty(oldText), splitKeepEmpty(newText)
+	ops, ok := diffLines(oldLines, newLines)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldName, newName)
+	if !ok {
+		// Too costly to compute; still non-empty so callers see a change
+		fmt.Fprintf(&b, "Files differ (%d lines -> %d lines, more than %d changed lines to show)\n",
+			len(oldLines), len(newLines), maxDiffEdits)
+		return b.String()
+	}
+
+	// Walk the script, emitting a hunk around each run of changes
+	oldLine, newLine := 1, 1
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			oldLine++
+			newLine++
+			i++
+			continue
+		}
+
+		// Back up to include leading context
+		start := i
+		for start > 0 && i-start < context && ops[start-1].kind == ' ' {
+			start--
+		}
+		hunkOld := oldLine - (i - start)
+		hunkNew := newLine - (i - start)
+
+		// Extend until a run of unchanged lines longer than 2*context
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == ' ' {
+				run++
+			}
+			if run == len(ops) || run-end > 2*context {
+				end += min(context, run-end)
+				break
+			}
+			end = run
+		}
+
+		oldCount, newCount := 0, 0
+		for _, op := range ops[start:end] {
+			if op.kind != '+' {
+				oldCount++
+			}
+			if op.kind != '-' {
+				newCount++
+			}
+		}
+
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n", hunkRange(hunkOld, oldCount), hunkRange(hunkNew, newCount))
+		for _, op := range ops[start:end] {
+			b.WriteByte(op.kind)
+			b.WriteString(op.line)
+			b.WriteByte('\n')
+		}
+
+		for _, op := range ops[i:end] {
+			if op.kind != '+' {
+				oldLine++
+			}
+			if op.kind != '-' {
+				newLine++
+			}
+		}
+		i = end
+	}
+
+	return b.String()
+}
+
+// hunkRange formats a unified diff line range
+func hunkRange(start, count int) string {
+	if count == 0 {
+		// An empty range refers to the line before the insertion point
+		return fmt.Sprintf("%d,0", start-1)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// diffLines computes a shortest edit script with Myers' algorithm. It
+// returns false when the texts need more than maxDiffEdits edits
+func diffLines(a, b []string) ([]diffOp, bool) {
+	// Common leading and trailing lines never need the search
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	middle, ok := myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])
+	if !ok {
+		return nil, false
+	}
+
+	ops := make([]diffOp, 0, prefix+len(middle)+suffix)
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	ops = append(ops, middle...)
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops, true
+}
+
+// myers runs the greedy forward search, keeping for each edit distance d only
+// the 2d+3 frontier entries backtracking reads, so memory grows with the
+// square of the edit count rather than with the file size
+func myers(a, b []string) ([]diffOp, bool) {
+	n, m := len(a), len(b)
+	max := n + m
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	var trace [][]int
+
+	for d := 0; d <= max; d++ {
+		if d > maxDiffEdits {
+			return nil, false
+		}
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, a, b, d), true
+			}
+		}
+	}
+	return nil, true
+}
+
+// backtrack walks the saved frontiers from the end to rebuild the edit script.
+// trace[d] holds the frontier for diagonals -d-1 through d+1
+func backtrack(trace [][]int, a, b []string, d int) []diffOp {
+	x, y := len(a), len(b)
+	var ops []diffOp
+
+	for ; d > 0; d-- {
+		v := trace[d]
+		at := func(k int) int { return v[k+d+1] }
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, diffOp{' ', a[x]})
+		}
+		if x == prevX {
+			y--
+			ops = append(ops, diffOp{'+', b[y]})
+		} else {
+			x--
+			ops = append(ops, diffOp{'-', a[x]})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		ops = append(ops, diffOp{' ', a[x]})
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// splitKeepEmpty splits text into lines, dropping only the final terminator
+func splitKeepEmpty(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
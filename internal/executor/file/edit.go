@@ -0,0 +1,263 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"einfra/agent/internal/executor"
+	"einfra/agent/internal/fsutil"
+	"einfra/agent/internal/logger"
+)
+
+// maxEditSize bounds files handled by template and line edits
+const maxEditSize = 10 * 1024 * 1024
+
+// renderTemplate renders a Go text/template with backend-supplied variables and
+// writes it atomically, only when the content changed
+func (e *Executor) renderTemplate(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	dest, ok := action.Params["destination"].(string)
+	if !ok {
+		result.Success = false
+		result.Error = "missing 'destination' parameter"
+		return result
+	}
+
+	text, ok := action.Params["template"].(string)
+	if !ok {
+		src, ok := action.Params["template_path"].(string)
+		if !ok {
+			result.Success = false
+			result.Error = "missing 'template' or 'template_path' parameter"
+			return result
+		}
+		data, err := readLimited(src)
+		if err != nil {
+			result.Success = false
+			result.Error = fmt.Sprintf("failed to read template: %v", err)
+			return result
+		}
+		text = string(data)
+	}
+
+	vars, _ := action.Params["variables"].(map[string]interface{})
+
+	tmpl, err := template.New(dest).Option("missingkey=error").Parse(text)
+	if err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("invalid template: %v", err)
+		return result
+	}
+
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, vars); err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to render template: %v", err)
+		return result
+	}
+
+	return e.applyEdit(action, dest, rendered.String(), action.BoolParam("create", true), result)
+}
+
+// lineInFile ensures a line (or a line matching a regexp) is present or absent
+func (e *Executor) lineInFile(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	path, ok := action.Params["path"].(string)
+	if !ok {
+		result.Success = false
+		result.Error = "missing 'path' parameter"
+		return result
+	}
+
+	state := action.StringParam("state", "present")
+	if state != "present" && state != "absent" {
+		result.Success = false
+		result.Error = "'state' must be 'present' or 'absent'"
+		return result
+	}
+
+	line, hasLine := action.Params["line"].(string)
+	if state == "present" && !hasLine {
+		result.Success = false
+		result.Error = "missing 'line' parameter"
+		return result
+	}
+
+	var re *regexp.Regexp
+	if expr := action.StringParam("regexp", ""); expr != "" {
+		var err error
+		if re, err = regexp.Compile(expr); err != nil {
+			result.Success = false
+			result.Error = fmt.Sprintf("invalid 'regexp' parameter: %v", err)
+			return result
+		}
+	} else if !hasLine {
+		result.Success = false
+		result.Error = "missing 'line' or 'regexp' parameter"
+		return result
+	}
+
+	current, err := readLimited(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to read file: %v", err)
+		return result
+	}
+
+	lines := splitKeepEmpty(string(current))
+	matches := func(l string) bool {
+		if re != nil {
+			return re.MatchString(l)
+		}
+		return l == line
+	}
+
+	if state == "absent" {
+		kept := lines[:0:0]
+		for _, l := range lines {
+			if !matches(l) {
+				kept = append(kept, l)
+			}
+		}
+		lines = kept
+	} else {
+		lines, err = ensureLine(lines, line, matches, action)
+		if err != nil {
+			result.Success = false
+			result.Error = err.Error()
+			return result
+		}
+	}
+
+	content := strings.Join(lines, "\n")
+	if len(lines) > 0 {
+		content += "\n"
+	}
+
+	return e.applyEdit(action, path, content, action.BoolParam("create", false), result)
+}
+
+// ensureLine replaces the last matching line, or inserts line when nothing matches
+func ensureLine(lines []string, line string, matches func(string) bool, action *executor.Action) ([]string, error) {
+	for i := len(lines) - 1; i >= 0; i-- {
+		if matches(lines[i]) {
+			lines[i] = line
+			return lines, nil
+		}
+	}
+	for _, l := range lines {
+		if l == line {
+			return lines, nil
+		}
+	}
+
+	pos := len(lines)
+	if after := action.StringParam("insert_after", ""); after != "" && after != "EOF" {
+		re, err := regexp.Compile(after)
+		if err != nil {
+			return nil, fmt.Errorf("invalid 'insert_after' parameter: %v", err)
+		}
+		for i := len(lines) - 1; i >= 0; i-- {
+			if re.MatchString(lines[i]) {
+				pos = i + 1
+				break
+			}
+		}
+	} else if before := action.StringParam("insert_before", ""); before != "" {
+		if before == "BOF" {
+			pos = 0
+		} else {
+			re, err := regexp.Compile(before)
+			if err != nil {
+				return nil, fmt.Errorf("invalid 'insert_before' parameter: %v", err)
+			}
+			for i := len(lines) - 1; i >= 0; i-- {
+				if re.MatchString(lines[i]) {
+					pos = i
+					break
+				}
+			}
+		}
+	}
+
+	lines = append(lines, "")
+	copy(lines[pos+1:], lines[pos:])
+	lines[pos] = line
+	return lines, nil
+}
+
+// applyEdit diffs new content against the file and writes it unless unchanged or dry-run
+func (e *Executor) applyEdit(action *executor.Action, path, content string, create bool, result *executor.Result) *executor.Result {
+	current, err := readLimited(path)
+	exists := err == nil
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to read file: %v", err)
+		return result
+	}
+	if !exists && !create {
+		result.Success = false
+		result.Error = fmt.Sprintf("file does not exist: %s", path)
+		return result
+	}
+
+	var perm os.FileMode
+	if mode := action.StringParam("mode", ""); mode != "" {
+		parsed, err := strconv.ParseUint(mode, 8, 32)
+		if err != nil {
+			result.Success = false
+			result.Error = fmt.Sprintf("invalid 'mode' parameter: %v", err)
+			return result
+		}
+		perm = os.FileMode(parsed)
+	}
+
+	oldName := path
+	if !exists {
+		oldName = "/dev/null"
+	}
+	diff := unifiedDiff(string(current), content, oldName, path, 3)
+	changed := !exists || diff != ""
+	dryRun := action.BoolParam("dry_run", false)
+
+	result.Data["path"] = path
+	result.Data["changed"] = changed
+	result.Data["diff"] = diff
+	result.Data["dry_run"] = dryRun
+	result.Data["written"] = false
+
+	if changed && !dryRun {
+		if err := fsutil.WriteFileAtomic(path, []byte(content), perm); err != nil {
+			result.Success = false
+			result.Error = fmt.Sprintf("failed to write file: %v", err)
+			return result
+		}
+		result.Data["written"] = true
+
+		logger.Info().
+			Str("action", action.Type).
+			Str("path", path).
+			Msg("File updated")
+	}
+
+	result.Success = true
+	return result
+}
+
+// readLimited reads a file, refusing anything larger than maxEditSize
+func readLimited(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > maxEditSize {
+		return nil, fmt.Errorf("file too large (max %d bytes)", maxEditSize)
+	}
+	return os.ReadFile(path)
+}
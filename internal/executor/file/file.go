@@ -29,6 +29,13 @@ func (e *Executor) SupportedActions() []string {
 		"file_chmod",
 		"file_chown",
 		"dir_create",
+		"file_tail",
+		"file_grep",
+		"file_follow",
+		"file_archive",
+		"file_extract",
+		"file_template",
+		"file_lineinfile",
 	}
 }
 
@@ -50,6 +57,20 @@ func (e *Executor) Execute(ctx context.Context, action *executor.Action) *execut
 		return e.chmod(ctx, action, result)
 	case "dir_create":
 		return e.createDir(ctx, action, result)
+	case "file_tail":
+		return e.tailFile(ctx, action, result)
+	case "file_grep":
+		return e.grepFile(ctx, action, result)
+	case "file_follow":
+		return e.followFile(ctx, action, result)
+	case "file_archive":
+		return e.createArchive(ctx, action, result)
+	case "file_extract":
+		return e.extractArchive(ctx, action, result)
+	case "file_template":
+		return e.renderTemplate(ctx, action, result)
+	case "file_lineinfile":
+		return e.lineInFile(ctx, action, result)
 	default:
 		result.Success = false
 		result.Error = "unknown file action"
@@ -57,36 +78,6 @@ func (e *Executor) Execute(ctx context.Context, action *executor.Action) *execut
 	}
 }
 
-// listFiles lists directory contents
-func (e *Executor) listFiles(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
-	path, ok := action.Params["path"].(string)
-	if !ok {
-		path = "/"
-	}
-
-	entries, err := os.ReadDir(path)
-	if err != nil {
-		result.Success = false
-		result.Error = fmt.Sprintf("failed to read directory: %v", err)
-		return result
-	}
-
-	files := make([]map[string]interface{}, 0, len(entries))
-	for _, entry := range entries {
-		info, _ := entry.Info()
-		files = append(files, map[string]interface{}{
-			"name":   entry.Name(),
-			"is_dir": entry.IsDir(),
-			"size":   info.Size(),
-			"mode":   info.Mode().String(),
-		})
-	}
-
-	result.Data["files"] = files
-	result.Success = true
-	return result
-}
-
 // readFile reads file content (limited size)
 func (e *Executor) readFile(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
 	path, ok := action.Params["path"].(string)
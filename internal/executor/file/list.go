@@ -0,0 +1,391 @@
+package file
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"einfra/agent/internal/executor"
+)
+
+const (
+	// defaultListLimit is the page size when 'limit' is not given
+	defaultListLimit = 1000
+	// maxListLimit bounds a single page
+	maxListLimit = 10000
+	// maxListScan bounds how many entries one listing may visit
+	maxListScan = 1000000
+)
+
+// fileEntry is one row of a directory listing
+type fileEntry struct {
+	Name       string    `json:"name"`
+	Path       string    `json:"path"`
+	Type       string    `json:"type"`
+	IsDir      bool      `json:"is_dir"`
+	Size       int64     `json:"size"`
+	Mode       string    `json:"mode"`
+	ModTime    time.Time `json:"mod_time"`
+	UID        int       `json:"uid"`
+	GID        int       `json:"gid"`
+	Owner      string    `json:"owner,omitempty"`
+	Group      string    `json:"group,omitempty"`
+	LinkTarget string    `json:"link_target,omitempty"`
+	Depth      int       `json:"depth"`
+}
+
+// listFilter holds the matching criteria for file_list
+type listFilter struct {
+	glob           string
+	regex          *regexp.Regexp
+	fileType       string
+	minSize        int64
+	maxSize        int64
+	modifiedAfter  time.Time
+	modifiedBefore time.Time
+	owner          string
+	group          string
+}
+
+// listCursor identifies the last entry of the previous page
+type listCursor struct {
+	Key  string `json:"k"`
+	Path string `json:"p"`
+}
+
+// listFiles lists directory contents, optionally recursively, with filters,
+// sorting and cursor-based pagination
+func (e *Executor) listFiles(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	root, ok := action.Params["path"].(string)
+	if !ok {
+		root = "/"
+	}
+	root = filepath.Clean(root)
+
+	filter, err := parseListFilter(action)
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+
+	maxDepth := action.IntParam("max_depth", 1)
+	if maxDepth < 1 {
+		result.Success = false
+		result.Error = "'max_depth' must be at least 1"
+		return result
+	}
+
+	limit := action.IntParam("limit", defaultListLimit)
+	if limit <= 0 || limit > maxListLimit {
+		result.Success = false
+		result.Error = fmt.Sprintf("'limit' must be between 1 and %d", maxListLimit)
+		return result
+	}
+
+	sortKey := action.StringParam("sort", "name")
+	if sortKey != "name" && sortKey != "path" && sortKey != "size" && sortKey != "mtime" {
+		result.Success = false
+		result.Error = "'sort' must be one of name, path, size, mtime"
+		return result
+	}
+	desc := action.StringParam("order", "asc") == "desc"
+
+	var cursor *listCursor
+	if raw := action.StringParam("cursor", ""); raw != "" {
+		cursor, err = decodeListCursor(raw)
+		if err != nil {
+			result.Success = false
+			result.Error = "invalid 'cursor' parameter"
+			return result
+		}
+	}
+
+	if _, err := os.Stat(root); err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to read directory: %v", err)
+		return result
+	}
+
+	var (
+		entries    []*fileEntry
+		walkErrors []string
+		scanned    int
+		truncated  bool
+	)
+
+	var walk func(dir string, depth int) error
+	walk = func(dir string, depth int) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		dirEntries, err := os.ReadDir(dir)
+		if err != nil {
+			if len(walkErrors) < 100 {
+				walkErrors = append(walkErrors, err.Error())
+			}
+			return nil
+		}
+
+		for _, d := range dirEntries {
+			if scanned >= maxListScan {
+				truncated = true
+				return nil
+			}
+			scanned++
+
+			full := filepath.Join(dir, d.Name())
+			info, err := d.Info()
+			if err != nil {
+				// Entry vanished or is unreadable between ReadDir and Lstat
+				if len(walkErrors) < 100 {
+					walkErrors = append(walkErrors, err.Error())
+				}
+				continue
+			}
+
+			entry := newFileEntry(full, info, depth)
+			if filter.matches(entry) {
+				entries = append(entries, entry)
+			}
+
+			// Never descend through symlinks to avoid cycles
+			if d.IsDir() && depth < maxDepth {
+				if err := walk(full, depth+1); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if err := walk(root, 1); err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("listing aborted: %v", err)
+		return result
+	}
+
+	keyOf := listSortKey(sortKey)
+	sort.Slice(entries, func(i, j int) bool {
+		ki, kj := keyOf(entries[i]), keyOf(entries[j])
+		if ki == kj {
+			return lessOrdered(entries[i].Path, entries[j].Path, desc)
+		}
+		return lessOrdered(ki, kj, desc)
+	})
+
+	// Keyset pagination: resume strictly after the cursor position so pages
+	// stay consistent even if entries are added or removed between calls
+	start := 0
+	if cursor != nil {
+		start = sort.Search(len(entries), func(i int) bool {
+			k := keyOf(entries[i])
+			if k == cursor.Key {
+				return lessOrdered(cursor.Path, entries[i].Path, desc)
+			}
+			return lessOrdered(cursor.Key, k, desc)
+		})
+	}
+
+	end := start + limit
+	if end > len(entries) {
+		end = len(entries)
+	}
+	page := entries[start:end]
+
+	resolveOwners(page)
+
+	if end < len(entries) {
+		last := page[len(page)-1]
+		result.Data["next_cursor"] = encodeListCursor(&listCursor{Key: keyOf(last), Path: last.Path})
+	}
+	result.Data["files"] = page
+	result.Data["total"] = len(entries)
+	result.Data["scanned"] = scanned
+	result.Data["truncated"] = truncated
+	if len(walkErrors) > 0 {
+		result.Data["errors"] = walkErrors
+	}
+	result.Success = true
+	return result
+}
+
+// parseListFilter builds a filter from action parameters
+func parseListFilter(action *executor.Action) (*listFilter, error) {
+	f := &listFilter{
+		glob:     action.StringParam("pattern", ""),
+		fileType: action.StringParam("type", ""),
+		minSize:  action.Int64Param("min_size", -1),
+		maxSize:  action.Int64Param("max_size", -1),
+		owner:    action.StringParam("owner", ""),
+		group:    action.StringParam("group", ""),
+	}
+
+	if f.glob != "" {
+		if _, err := filepath.Match(f.glob, ""); err != nil {
+			return nil, fmt.Errorf("invalid 'pattern' parameter: %v", err)
+		}
+	}
+
+	if expr := action.StringParam("regex", ""); expr != "" {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid 'regex' parameter: %v", err)
+		}
+		f.regex = re
+	}
+
+	switch f.fileType {
+	case "", "file", "dir", "symlink", "other":
+	default:
+		return nil, fmt.Errorf("'type' must be one of file, dir, symlink, other")
+	}
+
+	var err error
+	if f.modifiedAfter, err = parseTimeParam(action, "modified_after"); err != nil {
+		return nil, err
+	}
+	if f.modifiedBefore, err = parseTimeParam(action, "modified_before"); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// matches reports whether an entry passes every configured filter
+func (f *listFilter) matches(entry *fileEntry) bool {
+	if f.glob != "" {
+		if ok, _ := filepath.Match(f.glob, entry.Name); !ok {
+			return false
+		}
+	}
+	if f.regex != nil && !f.regex.MatchString(entry.Path) {
+		return false
+	}
+	if f.fileType != "" && f.fileType != entry.Type {
+		return false
+	}
+	if f.minSize >= 0 && entry.Size < f.minSize {
+		return false
+	}
+	if f.maxSize >= 0 && entry.Size > f.maxSize {
+		return false
+	}
+	if !f.modifiedAfter.IsZero() && !entry.ModTime.After(f.modifiedAfter) {
+		return false
+	}
+	if !f.modifiedBefore.IsZero() && !entry.ModTime.Before(f.modifiedBefore) {
+		return false
+	}
+	if f.owner != "" && f.owner != strconv.Itoa(entry.UID) && f.owner != lookupUser(entry.UID) {
+		return false
+	}
+	if f.group != "" && f.group != strconv.Itoa(entry.GID) && f.group != lookupGroup(entry.GID) {
+		return false
+	}
+	return true
+}
+
+// newFileEntry converts Lstat info into a listing row
+func newFileEntry(path string, info os.FileInfo, depth int) *fileEntry {
+	uid, gid := fileOwner(info)
+	entry := &fileEntry{
+		Name:    info.Name(),
+		Path:    path,
+		IsDir:   info.IsDir(),
+		Size:    info.Size(),
+		Mode:    info.Mode().String(),
+		ModTime: info.ModTime().UTC(),
+		UID:     uid,
+		GID:     gid,
+		Depth:   depth,
+	}
+
+	switch {
+	case info.Mode().IsRegular():
+		entry.Type = "file"
+	case info.IsDir():
+		entry.Type = "dir"
+	case info.Mode()&os.ModeSymlink != 0:
+		entry.Type = "symlink"
+		entry.LinkTarget, _ = os.Readlink(path)
+	default:
+		entry.Type = "other"
+	}
+
+	return entry
+}
+
+// resolveOwners fills in user and group names for a page of entries
+func resolveOwners(entries []*fileEntry) {
+	for _, entry := range entries {
+		entry.Owner = lookupUser(entry.UID)
+		entry.Group = lookupGroup(entry.GID)
+	}
+}
+
+// listSortKey returns a function producing a lexically comparable sort key
+func listSortKey(key string) func(*fileEntry) string {
+	switch key {
+	case "size":
+		return func(f *fileEntry) string { return fmt.Sprintf("%020d", f.Size) }
+	case "mtime":
+		return func(f *fileEntry) string { return fmt.Sprintf("%020d", f.ModTime.UnixNano()) }
+	case "path":
+		return func(f *fileEntry) string { return f.Path }
+	default:
+		return func(f *fileEntry) string { return f.Name }
+	}
+}
+
+// lessOrdered compares two keys honoring the sort direction
+func lessOrdered(a, b string, desc bool) bool {
+	if desc {
+		return a > b
+	}
+	return a < b
+}
+
+// encodeListCursor serializes a cursor into an opaque token
+func encodeListCursor(c *listCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeListCursor parses a token produced by encodeListCursor
+func decodeListCursor(raw string) (*listCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, err
+	}
+	var c listCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// parseTimeParam accepts an RFC3339 string or unix seconds
+func parseTimeParam(action *executor.Action, key string) (time.Time, error) {
+	switch v := action.Params[key].(type) {
+	case nil:
+		return time.Time{}, nil
+	case float64:
+		return time.Unix(int64(v), 0), nil
+	case string:
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid '%s' parameter: %v", key, err)
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid '%s' parameter", key)
+}
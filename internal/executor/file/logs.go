@@ -0,0 +1,558 @@
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"einfra/agent/internal/executor"
+)
+
+const (
+	// maxLineLength truncates very long lines in results
+	maxLineLength = 4096
+	// maxTailBytes bounds how much a single file_tail may return
+	maxTailBytes = 4 * 1024 * 1024
+	// maxFollowTimeout bounds how long file_follow may block a task
+	maxFollowTimeout = 300
+	// tailChunkSize is the backwards read size when counting lines
+	tailChunkSize = 64 * 1024
+)
+
+// tailFile returns the last N lines or bytes of a file, continuing into the
+// previous rotation (path.1 or path.1.gz) when the current file is too short
+func (e *Executor) tailFile(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	path, ok := action.Params["path"].(string)
+	if !ok {
+		result.Success = false
+		result.Error = "missing 'path' parameter"
+		return result
+	}
+
+	if action.HasParam("bytes") {
+		n := action.Int64Param("bytes", 0)
+		if n <= 0 || n > maxTailBytes {
+			result.Success = false
+			result.Error = fmt.Sprintf("'bytes' must be between 1 and %d", maxTailBytes)
+			return result
+		}
+		return e.tailBytes(path, n, result)
+	}
+
+	lines := action.IntParam("lines", 100)
+	if lines <= 0 || lines > 100000 {
+		result.Success = false
+		result.Error = "'lines' must be between 1 and 100000"
+		return result
+	}
+
+	out, scanned, size, complete, err := tailLines(path, lines)
+	if err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to tail file: %v", err)
+		return result
+	}
+
+	files := []string{path}
+	// The rotated file only continues the lines when all of the current file was read
+	if len(out) < lines && complete && action.BoolParam("include_rotated", true) {
+		if rotated := rotatedPath(path); rotated != "" {
+			prev, prevScanned, err := tailRotated(ctx, rotated, lines-len(out))
+			if err == nil {
+				out = append(prev, out...)
+				scanned += prevScanned
+				files = append([]string{rotated}, files...)
+			}
+		}
+	}
+
+	result.Data["lines"] = out
+	result.Data["line_count"] = len(out)
+	result.Data["file_size"] = size
+	result.Data["bytes_scanned"] = scanned
+	result.Data["files"] = files
+	if !complete && len(out) < lines {
+		result.Data["truncated"] = true
+	}
+	result.Success = true
+	return result
+}
+
+// tailBytes returns the last n bytes of a file
+func (e *Executor) tailBytes(path string, n int64, result *executor.Result) *executor.Result {
+	f, err := os.Open(path)
+	if err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to open file: %v", err)
+		return result
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to stat file: %v", err)
+		return result
+	}
+
+	offset := info.Size() - n
+	if offset < 0 {
+		offset = 0
+	}
+
+	buf := make([]byte, info.Size()-offset)
+	read, err := f.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to read file: %v", err)
+		return result
+	}
+
+	result.Data["content"] = string(buf[:read])
+	result.Data["offset"] = offset
+	result.Data["file_size"] = info.Size()
+	result.Data["bytes_scanned"] = read
+	result.Success = true
+	return result
+}
+
+// tailLines reads backwards from the end of a file until it has n lines or
+// has read maxTailBytes. It returns the lines, the bytes scanned, the file
+// size and whether it reached the start of the file
+func tailLines(path string, n int) ([]string, int64, int64, bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, 0, false, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, 0, 0, false, err
+	}
+	size := info.Size()
+
+	window := size
+	if window > maxTailBytes {
+		window = maxTailBytes
+	}
+	// Chunks fill buf from the end, so buf[start:] is always the tail read so far
+	buf := make([]byte, window)
+	start := window
+	pos := size
+	newlines := 0
+
+	// A trailing newline terminates the last line rather than starting a new one
+	for start > 0 && newlines <= n {
+		chunk := int64(tailChunkSize)
+		if chunk > start {
+			chunk = start
+		}
+		start -= chunk
+		pos -= chunk
+
+		if _, err := f.ReadAt(buf[start:start+chunk], pos); err != nil && err != io.EOF {
+			return nil, 0, 0, false, err
+		}
+		newlines += bytes.Count(buf[start:start+chunk], []byte{'\n'})
+	}
+	data := buf[start:]
+
+	lines := splitLines(data)
+	// The first line is partial unless we reached the start of the file
+	if pos > 0 && len(lines) > 0 {
+		lines = lines[1:]
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+
+	return lines, int64(len(data)), size, pos == 0, nil
+}
+
+// tailRotated returns the last n lines of a rotated, possibly gzipped, file
+func tailRotated(ctx context.Context, path string, n int) ([]string, int64, error) {
+	if !strings.HasSuffix(path, ".gz") {
+		lines, scanned, _, _, err := tailLines(path, n)
+		return lines, scanned, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer gz.Close()
+
+	// Compressed files cannot be read backwards, so keep a ring of the last n lines
+	ring := make([]string, 0, n)
+	reader := bufio.NewReader(gz)
+	var scanned int64
+	for {
+		if ctx.Err() != nil {
+			return nil, scanned, ctx.Err()
+		}
+		line, consumed, err := readLine(reader)
+		scanned += int64(consumed)
+		if consumed > 0 {
+			if len(ring) == n {
+				ring = ring[1:]
+			}
+			ring = append(ring, line)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, scanned, err
+		}
+	}
+
+	return ring, scanned, nil
+}
+
+// rotatedPath returns the most recent rotation of a log file, if any
+func rotatedPath(path string) string {
+	for _, candidate := range []string{path + ".1", path + ".1.gz"} {
+		if fileExists(candidate) {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// grepFile searches a byte window of a file with a regular expression
+func (e *Executor) grepFile(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	path, ok := action.Params["path"].(string)
+	if !ok {
+		result.Success = false
+		result.Error = "missing 'path' parameter"
+		return result
+	}
+
+	pattern, ok := action.Params["pattern"].(string)
+	if !ok || pattern == "" {
+		result.Success = false
+		result.Error = "missing 'pattern' parameter"
+		return result
+	}
+	if action.BoolParam("ignore_case", false) {
+		pattern = "(?i)" + pattern
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("invalid pattern: %v", err)
+		return result
+	}
+
+	maxMatches := action.IntParam("max_matches", 100)
+	contextLines := action.IntParam("context", 0)
+	offset := action.Int64Param("offset", 0)
+	length := action.Int64Param("length", 0)
+	if maxMatches <= 0 || contextLines < 0 || contextLines > 100 || offset < 0 || length < 0 {
+		result.Success = false
+		result.Error = "invalid 'max_matches', 'context', 'offset' or 'length' parameter"
+		return result
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to open file: %v", err)
+		return result
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to stat file: %v", err)
+		return result
+	}
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to seek: %v", err)
+		return result
+	}
+
+	var src io.Reader = f
+	if length > 0 {
+		src = io.LimitReader(f, length)
+	}
+	reader := bufio.NewReader(src)
+
+	type match struct {
+		// LineNumber counts from 'offset', not from the start of the file
+		LineNumber int      `json:"line_number"`
+		Offset     int64    `json:"offset"`
+		Line       string   `json:"line"`
+		Before     []string `json:"before,omitempty"`
+		After      []string `json:"after,omitempty"`
+	}
+
+	var (
+		matches   []*match
+		before    []string
+		pending   []*match // matches still collecting trailing context
+		pos       = offset
+		lineNo    int
+		truncated bool
+	)
+
+	for {
+		if lineNo%1000 == 0 && ctx.Err() != nil {
+			result.Success = false
+			result.Error = fmt.Sprintf("search aborted: %v", ctx.Err())
+			return result
+		}
+
+		line, consumed, err := readLine(reader)
+		if err != nil && err != io.EOF {
+			result.Success = false
+			result.Error = fmt.Sprintf("failed to read file: %v", err)
+			return result
+		}
+		if consumed == 0 {
+			break
+		}
+		lineNo++
+		lineStart := pos
+		pos += int64(consumed)
+
+		// Feed trailing context to earlier matches
+		still := pending[:0]
+		for _, m := range pending {
+			m.After = append(m.After, line)
+			if len(m.After) < contextLines {
+				still = append(still, m)
+			}
+		}
+		pending = still
+
+		isMatch := re.MatchString(line)
+		if isMatch && len(matches) >= maxMatches {
+			// Leave it for the next call, which resumes at this line
+			truncated = true
+			pos = lineStart
+			break
+		}
+		if isMatch {
+			m := &match{
+				LineNumber: lineNo,
+				Offset:     lineStart,
+				Line:       line,
+				Before:     append([]string(nil), before...),
+			}
+			matches = append(matches, m)
+			if contextLines > 0 {
+				pending = append(pending, m)
+			}
+		} else if len(matches) >= maxMatches && len(pending) == 0 {
+			truncated = true
+			pos = lineStart
+			break
+		}
+
+		if contextLines > 0 {
+			if len(before) == contextLines {
+				before = before[1:]
+			}
+			before = append(before, line)
+		}
+
+		if err != nil {
+			break
+		}
+	}
+
+	result.Data["matches"] = matches
+	result.Data["match_count"] = len(matches)
+	result.Data["truncated"] = truncated
+	result.Data["file_size"] = info.Size()
+	result.Data["bytes_scanned"] = pos - offset
+	result.Data["next_offset"] = pos
+	result.Success = true
+	return result
+}
+
+// followFile collects lines appended to a file until the timeout expires.
+// The returned next_offset can be passed back as 'offset' to resume
+func (e *Executor) followFile(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	path, ok := action.Params["path"].(string)
+	if !ok {
+		result.Success = false
+		result.Error = "missing 'path' parameter"
+		return result
+	}
+
+	timeout := action.IntParam("timeout", action.Timeout)
+	if timeout <= 0 {
+		timeout = 10
+	}
+	if timeout > maxFollowTimeout {
+		timeout = maxFollowTimeout
+	}
+	maxLines := action.IntParam("max_lines", 1000)
+
+	f, err := os.Open(path)
+	if err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to open file: %v", err)
+		return result
+	}
+	defer func() { f.Close() }()
+
+	info, err := f.Stat()
+	if err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to stat file: %v", err)
+		return result
+	}
+
+	offset := action.Int64Param("offset", info.Size())
+	if offset > info.Size() {
+		offset = info.Size()
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to seek: %v", err)
+		return result
+	}
+
+	followCtx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+
+	var (
+		lines     []string
+		scanned   int64
+		rotations int
+		partial   []byte
+		reader    = bufio.NewReader(f)
+	)
+
+	// drain reads all complete lines currently available
+	drain := func() {
+		for len(lines) < maxLines {
+			chunk, err := reader.ReadBytes('\n')
+			scanned += int64(len(chunk))
+			offset += int64(len(chunk))
+			if err != nil {
+				// Keep incomplete lines until the writer finishes them
+				partial = append(partial, chunk...)
+				return
+			}
+			line := append(partial, chunk...)
+			partial = nil
+			lines = append(lines, truncateLine(strings.TrimRight(string(line), "\r\n")))
+		}
+	}
+
+	drain()
+follow:
+	for len(lines) < maxLines {
+		select {
+		case <-followCtx.Done():
+			break follow
+		case <-ticker.C:
+		}
+
+		// Detect rotation (new inode) or truncation and reopen from the start
+		current, err := os.Stat(path)
+		if err == nil && (!os.SameFile(info, current) || current.Size() < offset) {
+			drain()
+			// The old file will not grow any more, so its last line is complete
+			if len(partial) > 0 && len(lines) < maxLines {
+				lines = append(lines, truncateLine(strings.TrimRight(string(partial), "\r\n")))
+			}
+			if newFile, err := os.Open(path); err == nil {
+				f.Close()
+				f = newFile
+				info = current
+				offset = 0
+				partial = nil
+				reader = bufio.NewReader(f)
+				rotations++
+			}
+		}
+		drain()
+	}
+
+	if len(partial) > 0 {
+		// Report the unfinished line's bytes as not yet consumed
+		offset -= int64(len(partial))
+	}
+
+	result.Data["lines"] = lines
+	result.Data["line_count"] = len(lines)
+	result.Data["bytes_scanned"] = scanned
+	result.Data["next_offset"] = offset
+	result.Data["rotations"] = rotations
+	result.Data["duration_seconds"] = timeout
+	result.Success = true
+	return result
+}
+
+// readLine reads one line, truncating it to maxLineLength while still consuming it.
+// It returns the number of bytes consumed including the newline
+func readLine(r *bufio.Reader) (string, int, error) {
+	var (
+		buf      []byte
+		consumed int
+	)
+	for {
+		chunk, err := r.ReadSlice('\n')
+		consumed += len(chunk)
+		if len(buf) < maxLineLength {
+			buf = append(buf, chunk...)
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		return truncateLine(strings.TrimRight(string(buf), "\r\n")), consumed, err
+	}
+}
+
+// splitLines splits data into lines without a trailing empty element
+func splitLines(data []byte) []string {
+	text := strings.TrimSuffix(string(data), "\n")
+	if text == "" {
+		return []string{}
+	}
+	parts := strings.Split(text, "\n")
+	for i, p := range parts {
+		parts[i] = truncateLine(strings.TrimSuffix(p, "\r"))
+	}
+	return parts
+}
+
+// truncateLine caps a line at maxLineLength
+func truncateLine(line string) string {
+	if len(line) > maxLineLength {
+		return line[:maxLineLength]
+	}
+	return line
+}
+
+// fileExists checks if a file exists
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
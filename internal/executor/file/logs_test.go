@@ -0,0 +1,126 @@
+package file
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"einfra/agent/internal/executor"
+)
+
+func TestTailLines(t *testing.T) {
+	dir := t.TempDir()
+	long := strings.Repeat("x", 1000)
+
+	tests := []struct {
+		name     string
+		content  string
+		n        int
+		want     []string
+		complete bool
+	}{
+		{name: "empty", content: "", n: 3, want: []string{}, complete: true},
+		{name: "fewer lines", content: "a\nb\n", n: 3, want: []string{"a", "b"}, complete: true},
+		{name: "last lines", content: "a\nb\nc\nd\n", n: 2, want: []string{"c", "d"}, complete: true},
+		{name: "no trailing newline", content: "a\nb\nc", n: 2, want: []string{"b", "c"}, complete: true},
+		{name: "crlf", content: "a\r\nb\r\n", n: 5, want: []string{"a", "b"}, complete: true},
+		{
+			name:     "spans chunks",
+			content:  strings.Repeat(long+"\n", 200) + "last\n",
+			n:        2,
+			want:     []string{long, "last"},
+			complete: false,
+		},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, strings.ReplaceAll(tt.name, " ", "_"))
+		if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		got, _, size, complete, err := tailLines(path, tt.n)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: lines = %q, want %q", tt.name, got, tt.want)
+		}
+		if complete != tt.complete {
+			t.Errorf("%s: complete = %v, want %v", tt.name, complete, tt.complete)
+		}
+		if size != int64(len(tt.content)) {
+			t.Errorf("%s: size = %d, want %d", tt.name, size, len(tt.content))
+		}
+	}
+}
+
+func TestTailLinesStopsAtMaxBytes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "big.log")
+	line := strings.Repeat("y", 1024*1024-1) + "\n"
+	if err := os.WriteFile(path, []byte(strings.Repeat(line, 6)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lines, scanned, _, complete, err := tailLines(path, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if complete {
+		t.Error("complete = true for a file larger than maxTailBytes")
+	}
+	if scanned != maxTailBytes {
+		t.Errorf("scanned = %d, want %d", scanned, maxTailBytes)
+	}
+	if len(lines) != 3 {
+		t.Errorf("got %d lines, want the 3 whole lines in the window", len(lines))
+	}
+}
+
+func TestTailFileRotated(t *testing.T) {
+	tests := []struct {
+		name      string
+		current   string
+		wantLines int
+		wantFiles int
+		truncated bool
+	}{
+		{name: "short current file continues into rotated", current: "c1\nc2\n", wantLines: 4, wantFiles: 2},
+		{
+			// Reading stops inside the current file, so splicing in the
+			// rotated file would hide a gap
+			name:      "current file larger than the window",
+			current:   strings.Repeat(strings.Repeat("z", 2*1024*1024-1)+"\n", 3),
+			wantLines: 1,
+			wantFiles: 1,
+			truncated: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "app.log")
+			if err := os.WriteFile(path+".1", []byte("r1\nr2\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(tt.current), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			action := &executor.Action{Type: "file_tail", Params: map[string]interface{}{"path": path, "lines": float64(4)}}
+			result := NewExecutor().Execute(context.Background(), action)
+			if !result.Success {
+				t.Fatalf("file_tail failed: %s", result.Error)
+			}
+			if got := len(result.Data["lines"].([]string)); got != tt.wantLines {
+				t.Errorf("got %d lines, want %d", got, tt.wantLines)
+			}
+			if got := len(result.Data["files"].([]string)); got != tt.wantFiles {
+				t.Errorf("files = %v, want %d", result.Data["files"], tt.wantFiles)
+			}
+			if got, _ := result.Data["truncated"].(bool); got != tt.truncated {
+				t.Errorf("truncated = %v, want %v", got, tt.truncated)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+//go:build !windows
+
+package file
+
+import (
+	"os"
+	"os/user"
+	"strconv"
+	"sync"
+	"syscall"
+)
+
+var (
+	userNames  sync.Map // uid -> name
+	groupNames sync.Map // gid -> name
+)
+
+// fileOwner returns the uid and gid of a file
+func fileOwner(info os.FileInfo) (int, int) {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return int(st.Uid), int(st.Gid)
+	}
+	return -1, -1
+}
+
+// lookupUser resolves a uid to a user name, caching the result
+func lookupUser(uid int) string {
+	if uid < 0 {
+		return ""
+	}
+	if name, ok := userNames.Load(uid); ok {
+		return name.(string)
+	}
+	name := ""
+	if u, err := user.LookupId(strconv.Itoa(uid)); err == nil {
+		name = u.Username
+	}
+	userNames.Store(uid, name)
+	return name
+}
+
+// lookupGroup resolves a gid to a group name, caching the result
+func lookupGroup(gid int) string {
+	if gid < 0 {
+		return ""
+	}
+	if name, ok := groupNames.Load(gid); ok {
+		return name.(string)
+	}
+	name := ""
+	if g, err := user.LookupGroupId(strconv.Itoa(gid)); err == nil {
+		name = g.Name
+	}
+	groupNames.Store(gid, name)
+	return name
+}
@@ -0,0 +1,20 @@
+//go:build windows
+
+package file
+
+import "os"
+
+// fileOwner is not available on Windows, where ownership lives in ACLs
+func fileOwner(info os.FileInfo) (int, int) {
+	return -1, -1
+}
+
+// lookupUser is not available on Windows
+func lookupUser(uid int) string {
+	return ""
+}
+
+// lookupGroup is not available on Windows
+func lookupGroup(gid int) string {
+	return ""
+}
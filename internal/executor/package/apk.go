@@ -0,0 +1,228 @@
+package package_executor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// apkWorldPath lists the packages explicitly requested, with any version constraints
+const apkWorldPath = "/etc/apk/world"
+
+// apkManager drives apk for Alpine
+type apkManager struct{}
+
+// name is the binary detection looks for
+func (m *apkManager) name() string {
+	return "apk"
+}
+
+// lockFiles is the apk database lock
+func (m *apkManager) lockFiles() []lockFile {
+	return []lockFile{{path: "/lib/apk/db/lock", kind: lockHeld}}
+}
+
+// listInstalled parses `apk list --installed`. apk does not record which
+// repository a package came from, so Repo is left empty
+func (m *apkManager) listInstalled(ctx context.Context) ([]Package, error) {
+	output, err := queryCommand(ctx, "apk", "list", "--installed")
+	if err != nil {
+		return nil, err
+	}
+
+	packages := make([]Package, 0)
+	for _, line := range strings.Split(output, "\n") {
+		// musl-1.2.4-r2 x86_64 {musl} (MIT) [installed]
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		name, version := splitNameVersion(fields[0])
+		if version == "" {
+			continue
+		}
+		packages = append(packages, Package{Name: name, Version: version, Arch: fields[1]})
+	}
+	return packages, nil
+}
+
+// install installs packages. An exact version is recorded in
+// /etc/apk/world, so it stays pinned until the package is unheld
+func (m *apkManager) install(ctx context.Context, packages []string, version string, downgrade bool) (string, error) {
+	if version != "" {
+		packages = []string{packages[0] + "=" + version}
+	}
+	return runCommand(ctx, "apk", append([]string{"add", "--no-progress"}, packages...)...)
+}
+
+// update refreshes the index, then upgrades the given packages or everything
+func (m *apkManager) update(ctx context.Context, packages []string, securityOnly bool) (string, error) {
+	if securityOnly {
+		return "", unsupported(m.name(), "security-only updates")
+	}
+	refresh, err := runCommand(ctx, "apk", "update", "--no-progress")
+	if err != nil {
+		return refresh, err
+	}
+	output, err := runCommand(ctx, "apk", append([]string{"upgrade", "--no-progress"}, packages...)...)
+	return refresh + output, err
+}
+
+// remove removes packages, and their configuration files when purging
+func (m *apkManager) remove(ctx context.Context, packages []string, purge bool) (string, error) {
+	args := []string{"del", "--no-progress"}
+	if purge {
+		args = append(args, "--purge")
+	}
+	return runCommand(ctx, "apk", append(args, packages...)...)
+}
+
+// search parses `apk search -v`: "name-1.2.3-r0 - description"
+func (m *apkManager) search(ctx context.Context, query string) ([]Package, error) {
+	output, err := queryCommand(ctx, "apk", "search", "-v", query)
+	if err != nil {
+		return nil, err
+	}
+
+	packages := make([]Package, 0)
+	for _, line := range strings.Split(output, "\n") {
+		nameVersion, summary, _ := strings.Cut(line, " - ")
+		name, version := splitNameVersion(strings.TrimSpace(nameVersion))
+		if version == "" {
+			continue
+		}
+		packages = append(packages, Package{Name: name, Version: version, Summary: summary})
+	}
+	return packages, nil
+}
+
+// inventory reads the apk installed database
+func (m *apkManager) inventory(ctx context.Context) ([]InventoryRecord, error) {
+	return readAPKDB(apkInstalledPath)
+}
+
+// checkUpdates parses `apk version -l '<'`; apk has no security metadata
+func (m *apkManager) checkUpdates(ctx context.Context, refresh bool) ([]Update, int, error) {
+	if refresh {
+		if _, err := runCommand(ctx, "apk", "update", "--no-progress"); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	output, err := queryCommand(ctx, "apk", "version", "-l", "<")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return parseAPKVersion(output), -1, nil
+}
+
+// parseAPKVersion parses `apk version -l '<'` rows into updates
+func parseAPKVersion(output string) []Update {
+	updates := make([]Update, 0)
+	for _, line := range strings.Split(output, "\n") {
+		// musl-1.2.3-r0    < 1.2.4-r0
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[1] != "<" {
+			continue
+		}
+		name, current := splitNameVersion(fields[0])
+		updates = append(updates, Update{Name: name, Current: current, Available: fields[2]})
+	}
+	return updates
+}
+
+// compareVersions asks `apk version -t`, which prints <, = or >
+func (m *apkManager) compareVersions(ctx context.Context, a, b string) (int, error) {
+	output, err := queryCommand(ctx, "apk", "version", "-t", a, b)
+	if err != nil {
+		return 0, err
+	}
+	switch strings.TrimSpace(output) {
+	case "<":
+		return -1, nil
+	case ">":
+		return 1, nil
+	case "=":
+		return 0, nil
+	}
+	return 0, fmt.Errorf("unexpected apk version output: %q", strings.TrimSpace(output))
+}
+
+// hold pins packages to their installed version in /etc/apk/world
+func (m *apkManager) hold(ctx context.Context, packages []string) (string, error) {
+	installed, err := m.listInstalled(ctx)
+	if err != nil {
+		return "", err
+	}
+	versions := installedVersions(installed)
+
+	pins := make([]string, 0, len(packages))
+	for _, p := range packages {
+		version, ok := versions[p]
+		if !ok {
+			return "", fmt.Errorf("%s is not installed", p)
+		}
+		pins = append(pins, p+"="+version)
+	}
+	return runCommand(ctx, "apk", append([]string{"add", "--no-progress"}, pins...)...)
+}
+
+// unhold replaces version pins with plain names. Packages that are not
+// pinned are skipped, since `apk add` would install them
+func (m *apkManager) unhold(ctx context.Context, packages []string) (string, error) {
+	held, err := m.listHeld(ctx)
+	if err != nil {
+		return "", err
+	}
+	pinned := make(map[string]bool, len(held))
+	for _, p := range held {
+		pinned[p.Name] = true
+	}
+
+	var names []string
+	for _, p := range packages {
+		if pinned[p] {
+			names = append(names, p)
+		}
+	}
+	if len(names) == 0 {
+		return "", nil
+	}
+	return runCommand(ctx, "apk", append([]string{"add", "--no-progress"}, names...)...)
+}
+
+// listHeld reads the version constraints in /etc/apk/world
+func (m *apkManager) listHeld(ctx context.Context) ([]Package, error) {
+	data, err := os.ReadFile(apkWorldPath)
+	if err != nil {
+		return nil, err
+	}
+
+	packages := make([]Package, 0)
+	for _, entry := range strings.Fields(string(data)) {
+		// name=1.2.3-r0, name~1.2 or name<2; a bare @tag only selects a repository
+		i := strings.IndexAny(entry, "=<>~")
+		if i <= 0 {
+			continue
+		}
+		packages = append(packages, Package{Name: entry[:i], Version: strings.TrimLeft(entry[i:], "=<>~")})
+	}
+	return packages, nil
+}
+
+// splitNameVersion splits "name-1.2.3-r0" (or an rpm "name-version-release");
+// the version is always the last two dash-separated fields while names may
+// contain dashes themselves
+func splitNameVersion(s string) (string, string) {
+	i := strings.LastIndex(s, "-")
+	if i <= 0 {
+		return s, ""
+	}
+	j := strings.LastIndex(s[:i], "-")
+	if j <= 0 {
+		return s, ""
+	}
+	return s[:j], s[j+1:]
+}
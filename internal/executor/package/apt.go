@@ -0,0 +1,247 @@
+package package_executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// aptManager drives apt-get for Debian and Ubuntu
+type aptManager struct{}
+
+// name is the binary detection looks for
+func (m *aptManager) name() string {
+	return "apt-get"
+}
+
+// lockFiles are the dpkg and apt locks, taken with fcntl
+func (m *aptManager) lockFiles() []lockFile {
+	return []lockFile{
+		{path: "/var/lib/dpkg/lock-frontend", kind: lockHeld},
+		{path: "/var/lib/dpkg/lock", kind: lockHeld},
+		{path: "/var/lib/apt/lists/lock", kind: lockHeld},
+		{path: "/var/cache/apt/archives/lock", kind: lockHeld},
+	}
+}
+
+// listInstalled parses `apt list --installed`, whose archive list gives the source repo
+func (m *aptManager) listInstalled(ctx context.Context) ([]Package, error) {
+	output, err := queryCommand(ctx, "apt", "list", "--installed")
+	if err != nil {
+		return nil, err
+	}
+
+	packages := make([]Package, 0)
+	for _, line := range strings.Split(output, "\n") {
+		// zlib1g/jammy-updates,now 1:1.2.11.dfsg-2ubuntu9.2 amd64 [installed]
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		name, archives, ok := strings.Cut(fields[0], "/")
+		if !ok {
+			continue
+		}
+		packages = append(packages, Package{
+			Name:    name,
+			Version: fields[1],
+			Arch:    fields[2],
+			Repo:    aptRepo(archives),
+		})
+	}
+	return packages, nil
+}
+
+// install installs packages without prompting and keeps existing config files
+func (m *aptManager) install(ctx context.Context, packages []string, version string, downgrade bool) (string, error) {
+	args := []string{"install", "-y", "-o", "Dpkg::Options::=--force-confold"}
+	if downgrade {
+		args = append(args, "--allow-downgrades")
+	}
+	if version != "" {
+		packages = []string{packages[0] + "=" + version}
+	}
+	return runCommand(ctx, "apt-get", append(args, packages...)...)
+}
+
+// update refreshes package lists, then upgrades the given packages or everything
+func (m *aptManager) update(ctx context.Context, packages []string, securityOnly bool) (string, error) {
+	if securityOnly {
+		return "", unsupported(m.name(), "security-only updates")
+	}
+
+	refresh, err := runCommand(ctx, "apt-get", "update")
+	if err != nil {
+		return refresh, err
+	}
+
+	args := []string{"upgrade", "-y", "-o", "Dpkg::Options::=--force-confold"}
+	if len(packages) > 0 {
+		args = append([]string{"install", "--only-upgrade", "-y", "-o", "Dpkg::Options::=--force-confold"}, packages...)
+	}
+	output, err := runCommand(ctx, "apt-get", args...)
+	return refresh + output, err
+}
+
+// remove removes packages, and their configuration files when purging
+func (m *aptManager) remove(ctx context.Context, packages []string, purge bool) (string, error) {
+	op := "remove"
+	if purge {
+		op = "purge"
+	}
+	return runCommand(ctx, "apt-get", append([]string{op, "-y"}, packages...)...)
+}
+
+// search matches package names and reports the candidate version of each
+func (m *aptManager) search(ctx context.Context, query string) ([]Package, error) {
+	output, err := queryCommand(ctx, "apt-cache", "search", "--names-only", query)
+	if err != nil {
+		return nil, err
+	}
+
+	packages := make([]Package, 0)
+	for _, line := range strings.Split(output, "\n") {
+		// name - summary
+		name, summary, ok := strings.Cut(line, " - ")
+		if !ok {
+			continue
+		}
+		packages = append(packages, Package{Name: name, Summary: summary})
+	}
+	if len(packages) == 0 {
+		return packages, nil
+	}
+
+	// Only the page the caller can receive is worth a policy lookup
+	names := make([]string, 0, maxSearchResults)
+	for i := 0; i < len(packages) && i < maxSearchResults; i++ {
+		names = append(names, packages[i].Name)
+	}
+	candidates := m.candidates(ctx, names)
+	for i := range packages {
+		packages[i].Version = candidates[packages[i].Name]
+	}
+	return packages, nil
+}
+
+// candidates reads the version apt would install from `apt-cache policy`
+func (m *aptManager) candidates(ctx context.Context, names []string) map[string]string {
+	versions := make(map[string]string)
+	output, err := queryCommand(ctx, "apt-cache", append([]string{"policy"}, names...)...)
+	if err != nil {
+		return versions
+	}
+
+	var current string
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.HasPrefix(line, " ") && strings.HasSuffix(line, ":") {
+			current = strings.TrimSuffix(line, ":")
+			continue
+		}
+		if v, ok := strings.CutPrefix(strings.TrimSpace(line), "Candidate:"); ok && current != "" {
+			if v = strings.TrimSpace(v); v != "(none)" {
+				versions[current] = v
+			}
+		}
+	}
+	return versions
+}
+
+// inventory reads the dpkg status database
+func (m *aptManager) inventory(ctx context.Context) ([]InventoryRecord, error) {
+	return readDpkgStatus(dpkgStatusPath)
+}
+
+// checkUpdates parses `apt list --upgradable`; updates from a -security
+// archive count as security fixes
+func (m *aptManager) checkUpdates(ctx context.Context, refresh bool) ([]Update, int, error) {
+	if refresh {
+		if _, err := runCommand(ctx, "apt-get", "update"); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	output, err := queryCommand(ctx, "apt", "list", "--upgradable")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	updates := make([]Update, 0)
+	for _, line := range strings.Split(output, "\n") {
+		// openssl/jammy-updates,jammy-security 3.0.2-0ubuntu1.12 amd64 [upgradable from: 3.0.2-0ubuntu1.10]
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		name, archives, ok := strings.Cut(fields[0], "/")
+		if !ok {
+			continue
+		}
+		u := Update{
+			Name:      name,
+			Available: fields[1],
+			Arch:      fields[2],
+			Repo:      aptRepo(archives),
+			Security:  strings.Contains(archives, "-security"),
+		}
+		if i := strings.Index(line, "upgradable from: "); i >= 0 {
+			u.Current = strings.TrimSuffix(strings.TrimSpace(line[i+len("upgradable from: "):]), "]")
+		}
+		updates = append(updates, u)
+	}
+	return updates, securityCount(updates), nil
+}
+
+// compareVersions asks dpkg, which exits 0 when the relation holds
+func (m *aptManager) compareVersions(ctx context.Context, a, b string) (int, error) {
+	for _, rel := range []struct {
+		op     string
+		result int
+	}{{"lt", -1}, {"gt", 1}} {
+		err := exec.CommandContext(ctx, "dpkg", "--compare-versions", a, rel.op, b).Run()
+		if err == nil {
+			return rel.result, nil
+		}
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+			return 0, fmt.Errorf("dpkg --compare-versions failed: %v", err)
+		}
+	}
+	return 0, nil
+}
+
+// hold marks packages so upgrades leave them alone
+func (m *aptManager) hold(ctx context.Context, packages []string) (string, error) {
+	return runCommand(ctx, "apt-mark", append([]string{"hold"}, packages...)...)
+}
+
+// unhold clears the hold mark
+func (m *aptManager) unhold(ctx context.Context, packages []string) (string, error) {
+	return runCommand(ctx, "apt-mark", append([]string{"unhold"}, packages...)...)
+}
+
+// listHeld parses `apt-mark showhold`, which prints names only
+func (m *aptManager) listHeld(ctx context.Context) ([]Package, error) {
+	output, err := queryCommand(ctx, "apt-mark", "showhold")
+	if err != nil {
+		return nil, err
+	}
+	packages := make([]Package, 0)
+	for _, name := range strings.Fields(output) {
+		packages = append(packages, Package{Name: name})
+	}
+	return packages, nil
+}
+
+// aptRepo picks the archive a package came from; "now" alone means it is
+// installed but no configured repository offers that version
+func aptRepo(archives string) string {
+	for _, a := range strings.Split(archives, ",") {
+		if a != "now" && a != "" {
+			return a
+		}
+	}
+	return "local"
+}
@@ -0,0 +1,183 @@
+package package_executor
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"einfra/agent/internal/executor"
+)
+
+// Package is a normalized package record shared by every backend
+type Package struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Arch    string `json:"arch,omitempty"`
+	Repo    string `json:"repo,omitempty"`
+	Summary string `json:"summary,omitempty"`
+}
+
+// manager is implemented once per package manager
+type manager interface {
+	name() string
+	// lockFiles lists the locks other package manager processes may hold
+	lockFiles() []lockFile
+	listInstalled(ctx context.Context) ([]Package, error)
+	// install installs packages; version is only set for a single package
+	// and downgrade permits replacing a newer installed version
+	install(ctx context.Context, packages []string, version string, downgrade bool) (string, error)
+	// update upgrades the given packages, or everything when packages is empty
+	update(ctx context.Context, packages []string, securityOnly bool) (string, error)
+	remove(ctx context.Context, packages []string, purge bool) (string, error)
+	search(ctx context.Context, query string) ([]Package, error)
+	inventory(ctx context.Context) ([]InventoryRecord, error)
+	// checkUpdates returns pending updates and how many are security fixes,
+	// or -1 when the manager cannot tell
+	checkUpdates(ctx context.Context, refresh bool) ([]Update, int, error)
+	// compareVersions orders two versions by the manager's rules: -1, 0 or 1
+	compareVersions(ctx context.Context, a, b string) (int, error)
+	// hold keeps packages at their installed version during updates
+	hold(ctx context.Context, packages []string) (string, error)
+	unhold(ctx context.Context, packages []string) (string, error)
+	listHeld(ctx context.Context) ([]Package, error)
+}
+
+// unsupportedError reports an operation the package manager has no equivalent for
+type unsupportedError struct {
+	manager   string
+	operation string
+}
+
+func (e *unsupportedError) Error() string {
+	return fmt.Sprintf("unsupported: %s has no equivalent for %s", e.manager, e.operation)
+}
+
+// unsupported builds an unsupportedError
+func unsupported(manager, operation string) error {
+	return &unsupportedError{manager: manager, operation: operation}
+}
+
+// failResult reports err on result, flagging operations the manager cannot do
+func failResult(result *executor.Result, prefix string, err error) *executor.Result {
+	result.Success = false
+
+	var u *unsupportedError
+	if errors.As(err, &u) {
+		result.Error = err.Error()
+		result.Data["unsupported"] = true
+		return result
+	}
+
+	result.Error = fmt.Sprintf("%s: %v", prefix, err)
+	return result
+}
+
+// detectManager picks the package manager from /etc/os-release, preferring
+// the distribution's native tool, then falls back to whichever binary exists
+func detectManager() manager {
+	if runtime.GOOS == "windows" {
+		return &chocoManager{}
+	}
+
+	osRelease := readOSRelease()
+	ids := append([]string{osRelease["ID"]}, strings.Fields(osRelease["ID_LIKE"])...)
+	for _, id := range ids {
+		var m manager
+		switch {
+		case id == "debian" || id == "ubuntu":
+			m = &aptManager{}
+		case id == "fedora" || id == "rhel" || id == "centos" || id == "rocky" ||
+			id == "almalinux" || id == "ol" || id == "amzn":
+			m = newDNFManager()
+		case id == "suse" || id == "sles" || strings.HasPrefix(id, "opensuse"):
+			m = &zypperManager{}
+		case id == "alpine":
+			m = &apkManager{}
+		case id == "arch" || id == "manjaro":
+			m = &pacmanManager{}
+		default:
+			continue
+		}
+		if commandExists(m.name()) {
+			return m
+		}
+	}
+
+	candidates := []manager{&aptManager{}, newDNFManager(), &zypperManager{}, &apkManager{}, &pacmanManager{}}
+	for _, m := range candidates {
+		if commandExists(m.name()) {
+			return m
+		}
+	}
+	return nil
+}
+
+// readOSRelease parses /etc/os-release (or /usr/lib/os-release) into a map
+func readOSRelease() map[string]string {
+	values := make(map[string]string)
+	for _, path := range []string{"/etc/os-release", "/usr/lib/os-release"} {
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			k, v, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+			if !ok || strings.HasPrefix(k, "#") {
+				continue
+			}
+			values[k] = strings.Trim(v, `"'`)
+		}
+		f.Close()
+		break
+	}
+	return values
+}
+
+// runCommand runs a package manager command non-interactively and returns its
+// combined output, folding the output into the error on failure
+func runCommand(ctx context.Context, name string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = append(os.Environ(), "DEBIAN_FRONTEND=noninteractive", "LC_ALL=C")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return string(output), fmt.Errorf("%w: %s", err, lastLines(string(output), 5))
+	}
+	return string(output), nil
+}
+
+// queryCommand runs a read-only command and returns stdout only, so warnings
+// on stderr do not end up in parsed output
+func queryCommand(ctx context.Context, name string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	output, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return string(output), fmt.Errorf("%w: %s", err, lastLines(string(exitErr.Stderr), 5))
+		}
+		return string(output), err
+	}
+	return string(output), nil
+}
+
+// lastLines keeps the tail of command output for error messages
+func lastLines(output string, n int) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// commandExists reports whether a binary is on PATH
+func commandExists(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
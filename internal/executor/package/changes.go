@@ -0,0 +1,52 @@
+package package_executor
+
+import (
+	"sort"
+)
+
+// PackageChange is one package whose installed version changed during an operation
+type PackageChange struct {
+	Name   string `json:"name"`
+	Arch   string `json:"arch,omitempty"`
+	Action string `json:"action"` // "installed", "updated" or "removed"
+	From   string `json:"from,omitempty"`
+	To     string `json:"to,omitempty"`
+}
+
+// packageKey identifies a package; multiarch systems install one name per arch
+func packageKey(p Package) string {
+	return p.Name + "\x00" + p.Arch
+}
+
+// diffPackages compares inventories taken before and after an operation
+func diffPackages(before, after []Package) []PackageChange {
+	old := make(map[string]Package, len(before))
+	for _, p := range before {
+		old[packageKey(p)] = p
+	}
+
+	changes := make([]PackageChange, 0)
+	for _, p := range after {
+		key := packageKey(p)
+		prev, existed := old[key]
+		delete(old, key)
+
+		switch {
+		case !existed:
+			changes = append(changes, PackageChange{Name: p.Name, Arch: p.Arch, Action: "installed", To: p.Version})
+		case prev.Version != p.Version:
+			changes = append(changes, PackageChange{Name: p.Name, Arch: p.Arch, Action: "updated", From: prev.Version, To: p.Version})
+		}
+	}
+	for _, p := range old {
+		changes = append(changes, PackageChange{Name: p.Name, Arch: p.Arch, Action: "removed", From: p.Version})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Name != changes[j].Name {
+			return changes[i].Name < changes[j].Name
+		}
+		return changes[i].Arch < changes[j].Arch
+	})
+	return changes
+}
@@ -0,0 +1,215 @@
+package package_executor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// chocoManager installs with Chocolatey and lists through PackageManagement,
+// which also covers MSI and programs installed outside Chocolatey
+type chocoManager struct{}
+
+// name is the binary detection looks for
+func (m *chocoManager) name() string {
+	return "choco"
+}
+
+// lockFiles is empty; Chocolatey has no lock other processes could hold
+func (m *chocoManager) lockFiles() []lockFile {
+	return nil
+}
+
+// listInstalled parses Get-Package; ProviderName stands in for the repo
+func (m *chocoManager) listInstalled(ctx context.Context) ([]Package, error) {
+	output, err := queryCommand(ctx, "powershell", "-Command",
+		"Get-Package | Select-Object Name,Version,ProviderName | ConvertTo-Json")
+	if err != nil {
+		return nil, err
+	}
+
+	var records []struct {
+		Name         string `json:"Name"`
+		Version      string `json:"Version"`
+		ProviderName string `json:"ProviderName"`
+	}
+	output = strings.TrimSpace(output)
+	if !strings.HasPrefix(output, "[") {
+		// A single package is rendered as an object
+		output = "[" + output + "]"
+	}
+	if err := json.Unmarshal([]byte(output), &records); err != nil {
+		return nil, fmt.Errorf("failed to parse Get-Package output: %v", err)
+	}
+
+	packages := make([]Package, 0, len(records))
+	for _, r := range records {
+		packages = append(packages, Package{Name: r.Name, Version: r.Version, Repo: r.ProviderName})
+	}
+	return packages, nil
+}
+
+// install installs packages without prompting. An exact version goes
+// through upgrade, which also installs missing packages and can downgrade
+func (m *chocoManager) install(ctx context.Context, packages []string, version string, downgrade bool) (string, error) {
+	if version == "" {
+		args := append([]string{"install"}, packages...)
+		return runCommand(ctx, "choco", append(args, "-y", "--no-progress")...)
+	}
+	args := []string{"upgrade", packages[0], "--version", version, "-y", "--no-progress"}
+	if downgrade {
+		args = append(args, "--allow-downgrade")
+	}
+	return runCommand(ctx, "choco", args...)
+}
+
+// update upgrades the given packages, or everything Chocolatey manages
+func (m *chocoManager) update(ctx context.Context, packages []string, securityOnly bool) (string, error) {
+	if securityOnly {
+		return "", unsupported(m.name(), "security-only updates")
+	}
+	if len(packages) == 0 {
+		packages = []string{"all"}
+	}
+	args := append([]string{"upgrade"}, packages...)
+	return runCommand(ctx, "choco", append(args, "-y", "--no-progress")...)
+}
+
+// remove uninstalls packages; Chocolatey has no purge
+func (m *chocoManager) remove(ctx context.Context, packages []string, purge bool) (string, error) {
+	if purge {
+		return "", unsupported(m.name(), "purge")
+	}
+	args := append([]string{"uninstall"}, packages...)
+	return runCommand(ctx, "choco", append(args, "-y")...)
+}
+
+// search parses `choco search --limit-output`: "name|version"
+func (m *chocoManager) search(ctx context.Context, query string) ([]Package, error) {
+	output, err := queryCommand(ctx, "choco", "search", query, "--limit-output")
+	if err != nil {
+		return nil, err
+	}
+
+	packages := make([]Package, 0)
+	for _, line := range strings.Split(output, "\n") {
+		name, version, ok := strings.Cut(strings.TrimSpace(line), "|")
+		if !ok {
+			continue
+		}
+		packages = append(packages, Package{Name: name, Version: version, Repo: "chocolatey"})
+	}
+	return packages, nil
+}
+
+// inventory has no database to read on Windows, so it converts the package list
+func (m *chocoManager) inventory(ctx context.Context) ([]InventoryRecord, error) {
+	packages, err := m.listInstalled(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return inventoryFromList(packages), nil
+}
+
+// checkUpdates parses `choco outdated --limit-output`: "name|current|available|pinned".
+// Chocolatey has no security metadata
+func (m *chocoManager) checkUpdates(ctx context.Context, refresh bool) ([]Update, int, error) {
+	output, err := queryCommand(ctx, "choco", "outdated", "--limit-output")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	updates := make([]Update, 0)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(strings.TrimSpace(line), "|")
+		if len(fields) < 3 {
+			continue
+		}
+		updates = append(updates, Update{Name: fields[0], Current: fields[1], Available: fields[2], Repo: "chocolatey"})
+	}
+	return updates, -1, nil
+}
+
+// compareVersions compares dotted numeric versions the way NuGet does,
+// treating missing parts as zero; a pre-release suffix sorts first
+func (m *chocoManager) compareVersions(ctx context.Context, a, b string) (int, error) {
+	aRelease, aPre, _ := strings.Cut(a, "-")
+	bRelease, bPre, _ := strings.Cut(b, "-")
+	aParts, bParts := strings.Split(aRelease, "."), strings.Split(bRelease, ".")
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		x, y := 0, 0
+		var err error
+		if i < len(aParts) {
+			if x, err = strconv.Atoi(aParts[i]); err != nil {
+				return 0, fmt.Errorf("invalid version: %s", a)
+			}
+		}
+		if i < len(bParts) {
+			if y, err = strconv.Atoi(bParts[i]); err != nil {
+				return 0, fmt.Errorf("invalid version: %s", b)
+			}
+		}
+		if x != y {
+			if x < y {
+				return -1, nil
+			}
+			return 1, nil
+		}
+	}
+
+	switch {
+	case aPre == bPre:
+		return 0, nil
+	case aPre == "":
+		return 1, nil
+	case bPre == "":
+		return -1, nil
+	}
+	return strings.Compare(aPre, bPre), nil
+}
+
+// hold pins packages with `choco pin`
+func (m *chocoManager) hold(ctx context.Context, packages []string) (string, error) {
+	var output strings.Builder
+	for _, p := range packages {
+		out, err := runCommand(ctx, "choco", "pin", "add", "--name="+p)
+		output.WriteString(out)
+		if err != nil {
+			return output.String(), err
+		}
+	}
+	return output.String(), nil
+}
+
+// unhold removes Chocolatey pins
+func (m *chocoManager) unhold(ctx context.Context, packages []string) (string, error) {
+	var output strings.Builder
+	for _, p := range packages {
+		out, err := runCommand(ctx, "choco", "pin", "remove", "--name="+p)
+		output.WriteString(out)
+		if err != nil {
+			return output.String(), err
+		}
+	}
+	return output.String(), nil
+}
+
+// listHeld parses `choco pin list --limit-output`: "name|version"
+func (m *chocoManager) listHeld(ctx context.Context) ([]Package, error) {
+	output, err := queryCommand(ctx, "choco", "pin", "list", "--limit-output")
+	if err != nil {
+		return nil, err
+	}
+
+	packages := make([]Package, 0)
+	for _, line := range strings.Split(output, "\n") {
+		name, version, ok := strings.Cut(strings.TrimSpace(line), "|")
+		if !ok {
+			continue
+		}
+		packages = append(packages, Package{Name: name, Version: version})
+	}
+	return packages, nil
+}
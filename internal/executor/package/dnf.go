@@ -0,0 +1,282 @@
+package package_executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// dnfManager drives dnf, or yum on older RHEL-family releases
+type dnfManager struct {
+	binary string
+}
+
+// newDNFManager prefers dnf and falls back to yum
+func newDNFManager() *dnfManager {
+	if commandExists("dnf") || !commandExists("yum") {
+		return &dnfManager{binary: "dnf"}
+	}
+	return &dnfManager{binary: "yum"}
+}
+
+// name is the binary detection looks for
+func (m *dnfManager) name() string {
+	return m.binary
+}
+
+// lockFiles are the rpm database lock plus the PID files yum and dnf keep
+// while running
+func (m *dnfManager) lockFiles() []lockFile {
+	return []lockFile{
+		{path: rpmLockPath, kind: lockHeld},
+		{path: "/var/run/yum.pid", kind: lockPIDFile},
+		{path: "/var/lib/dnf/rpmdb_lock.pid", kind: lockPIDFile},
+		{path: "/var/cache/dnf/metadata_lock.pid", kind: lockPIDFile},
+	}
+}
+
+// listInstalled parses `dnf list installed`, whose third column is the source repo
+func (m *dnfManager) listInstalled(ctx context.Context) ([]Package, error) {
+	output, err := queryCommand(ctx, m.binary, "list", "installed", "--quiet")
+	if err != nil {
+		return nil, err
+	}
+	return parseDNFList(output), nil
+}
+
+// install installs packages without prompting; yum only moves to an older
+// version through the downgrade command
+func (m *dnfManager) install(ctx context.Context, packages []string, version string, downgrade bool) (string, error) {
+	op := "install"
+	if downgrade {
+		op = "downgrade"
+	}
+	if version != "" {
+		packages = []string{packages[0] + "-" + version}
+	}
+	return runCommand(ctx, m.binary, append([]string{op, "-y"}, packages...)...)
+}
+
+// update upgrades the given packages or everything, optionally limited to
+// packages with security advisories
+func (m *dnfManager) update(ctx context.Context, packages []string, securityOnly bool) (string, error) {
+	args := []string{"upgrade", "-y"}
+	if securityOnly {
+		args = append(args, "--security")
+	}
+	return runCommand(ctx, m.binary, append(args, packages...)...)
+}
+
+// remove removes packages; rpm has no separate purge, it always drops
+// unmodified config files and keeps modified ones as .rpmsave
+func (m *dnfManager) remove(ctx context.Context, packages []string, purge bool) (string, error) {
+	if purge {
+		return "", unsupported(m.name(), "purge")
+	}
+	return runCommand(ctx, m.binary, append([]string{"remove", "-y"}, packages...)...)
+}
+
+// search lists installed and available packages whose name contains query
+func (m *dnfManager) search(ctx context.Context, query string) ([]Package, error) {
+	output, err := queryCommand(ctx, m.binary, "list", "--quiet", "all", "*"+query+"*")
+	if err != nil {
+		// dnf exits non-zero when nothing matches
+		if strings.Contains(strings.ToLower(err.Error()), "no matching packages") {
+			return []Package{}, nil
+		}
+		return nil, err
+	}
+	return parseDNFList(output), nil
+}
+
+// inventory reads the rpm database
+func (m *dnfManager) inventory(ctx context.Context) ([]InventoryRecord, error) {
+	return readRPMDB(ctx)
+}
+
+// checkUpdates parses `check-update`, which exits 100 when updates are
+// available, and marks packages named in security advisories
+func (m *dnfManager) checkUpdates(ctx context.Context, refresh bool) ([]Update, int, error) {
+	args := []string{"check-update", "--quiet"}
+	if refresh {
+		if m.binary == "dnf" {
+			args = append(args, "--refresh")
+		} else {
+			// yum 3 has no --refresh; expiring the cache makes check-update fetch metadata
+			if _, err := runCommand(ctx, m.binary, "clean", "expire-cache"); err != nil {
+				return nil, 0, err
+			}
+		}
+	}
+	output, err := queryCommand(ctx, m.binary, args...)
+	var exitErr *exec.ExitError
+	if err != nil && !(errors.As(err, &exitErr) && exitErr.ExitCode() == 100) {
+		return nil, 0, err
+	}
+
+	installed := map[string]string{}
+	if packages, err := m.listInstalled(ctx); err == nil {
+		installed = installedVersions(packages)
+	}
+	advisories, secErr := m.securityPackages(ctx)
+
+	updates := make([]Update, 0)
+	for _, p := range parseDNFCheckUpdate(output) {
+		updates = append(updates, Update{
+			Name:      p.Name,
+			Arch:      p.Arch,
+			Current:   installed[packageKey(p)],
+			Available: p.Version,
+			Repo:      p.Repo,
+			Security:  advisories[p.Name],
+		})
+	}
+
+	if secErr != nil {
+		return updates, -1, nil
+	}
+	return updates, securityCount(updates), nil
+}
+
+// securityPackages names packages with an outstanding security advisory
+func (m *dnfManager) securityPackages(ctx context.Context) (map[string]bool, error) {
+	output, err := queryCommand(ctx, m.binary, "updateinfo", "list", "--security", "--quiet")
+	if err != nil {
+		return nil, err
+	}
+
+	return parseDNFSecurity(output), nil
+}
+
+// parseDNFCheckUpdate parses `check-update` output into the available versions
+func parseDNFCheckUpdate(output string) []Package {
+	// Obsoletes are listed separately and are not updates of their own
+	if i := strings.Index(output, "Obsoleting Packages"); i >= 0 {
+		output = output[:i]
+	}
+
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		// "Security: kernel-... is an installed security update" notices are not packages
+		if !strings.HasPrefix(line, "Security:") {
+			lines = append(lines, line)
+		}
+	}
+	return parseDNFList(strings.Join(lines, "\n"))
+}
+
+// parseDNFSecurity parses `updateinfo list --security` into package names
+func parseDNFSecurity(output string) map[string]bool {
+	names := make(map[string]bool)
+	for _, line := range strings.Split(output, "\n") {
+		// RHSA-2023:1234 Important/Sec. openssl-libs-1:1.1.1k-9.el8_7.x86_64
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		nevra := fields[len(fields)-1]
+		if i := strings.LastIndex(nevra, "."); i > 0 {
+			nevra = nevra[:i]
+		}
+		if name, _ := splitNameVersion(nevra); name != nevra {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// compareVersions uses rpm's own comparison
+func (m *dnfManager) compareVersions(ctx context.Context, a, b string) (int, error) {
+	return rpmVercmp(ctx, a, b)
+}
+
+// hold adds versionlock entries for the installed versions
+func (m *dnfManager) hold(ctx context.Context, packages []string) (string, error) {
+	return m.versionlock(ctx, append([]string{"add"}, packages...)...)
+}
+
+// unhold deletes versionlock entries
+func (m *dnfManager) unhold(ctx context.Context, packages []string) (string, error) {
+	return m.versionlock(ctx, append([]string{"delete"}, packages...)...)
+}
+
+// listHeld parses `versionlock list`: "name-epoch:version-release.*" on dnf,
+// "epoch:name-version-release.*" on yum
+func (m *dnfManager) listHeld(ctx context.Context) ([]Package, error) {
+	output, err := m.versionlock(ctx, "list", "--quiet")
+	if err != nil {
+		return nil, err
+	}
+
+	packages := make([]Package, 0)
+	for _, line := range strings.Split(output, "\n") {
+		entry := strings.TrimSuffix(strings.TrimSpace(line), ".*")
+		// Excludes ("!name-...") block versions rather than hold one
+		if entry == "" || strings.HasPrefix(entry, "!") || strings.Contains(entry, " ") {
+			continue
+		}
+		if epoch, rest, ok := strings.Cut(entry, ":"); ok && !strings.Contains(epoch, "-") {
+			entry = rest
+		}
+		if name, version := splitNameVersion(entry); version != "" {
+			packages = append(packages, Package{Name: name, Version: version})
+		}
+	}
+	return packages, nil
+}
+
+// versionlock runs the versionlock plugin, which is packaged separately
+func (m *dnfManager) versionlock(ctx context.Context, args ...string) (string, error) {
+	output, err := runCommand(ctx, m.binary, append([]string{"versionlock"}, args...)...)
+	if err != nil && strings.Contains(output, "No such command") {
+		return output, fmt.Errorf("the %s versionlock plugin is not installed", m.binary)
+	}
+	return output, err
+}
+
+// rpmVercmp compares versions with rpm's embedded Lua rpm.vercmp
+func rpmVercmp(ctx context.Context, a, b string) (int, error) {
+	output, err := queryCommand(ctx, "rpm", "--eval", fmt.Sprintf("%%{lua: print(rpm.vercmp(%q, %q))}", a, b))
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(output))
+	if err != nil {
+		return 0, fmt.Errorf("unexpected rpm.vercmp output: %q", strings.TrimSpace(output))
+	}
+	return n, nil
+}
+
+// parseDNFList parses `dnf list` output: "name.arch version @repo" rows
+func parseDNFList(output string) []Package {
+	packages := make([]Package, 0)
+	// yum wraps long names onto their own line, so gather three fields per record
+	var fields []string
+	for _, line := range strings.Split(output, "\n") {
+		lower := strings.ToLower(line)
+		if strings.HasPrefix(lower, "installed packages") || strings.HasPrefix(lower, "available packages") ||
+			strings.HasPrefix(lower, "last metadata") {
+			continue
+		}
+		fields = append(fields, strings.Fields(line)...)
+		if len(fields) < 3 {
+			continue
+		}
+
+		name, arch := fields[0], ""
+		if i := strings.LastIndex(name, "."); i > 0 {
+			name, arch = name[:i], name[i+1:]
+		}
+		packages = append(packages, Package{
+			Name:    name,
+			Version: fields[1],
+			Arch:    arch,
+			Repo:    strings.TrimPrefix(fields[2], "@"),
+		})
+		fields = fields[:0]
+	}
+	return packages
+}
@@ -0,0 +1,30 @@
+package package_executor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDNFList(t *testing.T) {
+	output := `Last metadata expiration check: 0:12:03 ago on Mon 01 Jan 2024 10:00:00 AM UTC.
+Installed Packages
+bash.x86_64                          5.1.8-6.el9_1            @anaconda
+python3-backports-ssl_match_hostname.noarch
+                                     3.5.0.1-1.el7            @base
+Available Packages
+nginx.x86_64                         1:1.20.1-14.el9_2.1      appstream
+gpg-pubkey                           3228467c-613798eb        @System
+`
+	want := []Package{
+		{Name: "bash", Arch: "x86_64", Version: "5.1.8-6.el9_1", Repo: "anaconda"},
+		{Name: "python3-backports-ssl_match_hostname", Arch: "noarch", Version: "3.5.0.1-1.el7", Repo: "base"},
+		{Name: "nginx", Arch: "x86_64", Version: "1:1.20.1-14.el9_2.1", Repo: "appstream"},
+		{Name: "gpg-pubkey", Version: "3228467c-613798eb", Repo: "System"},
+	}
+	if got := parseDNFList(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if got := parseDNFList(""); got == nil || len(got) != 0 {
+		t.Errorf("empty output: got %#v, want an empty list", got)
+	}
+}
@@ -0,0 +1,106 @@
+package package_executor
+
+import (
+	"context"
+	"fmt"
+
+	"einfra/agent/internal/executor"
+	"einfra/agent/internal/logger"
+)
+
+// holdPackages keeps packages at their installed version so updates skip them
+func (e *Executor) holdPackages(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	packages, err := packagesParam(action)
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+
+	logger.Info().
+		Strs("packages", packages).
+		Str("manager", e.manager.name()).
+		Msg("Holding packages")
+
+	output, err := e.manager.hold(ctx, packages)
+	result.Output = output
+	if err != nil {
+		return failResult(result, "failed to hold packages", err)
+	}
+
+	result.Data["packages"] = packages
+	result.Success = true
+	return result
+}
+
+// unholdPackages lets updates move held packages again
+func (e *Executor) unholdPackages(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	packages, err := packagesParam(action)
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+
+	logger.Info().
+		Strs("packages", packages).
+		Str("manager", e.manager.name()).
+		Msg("Releasing held packages")
+
+	output, err := e.manager.unhold(ctx, packages)
+	result.Output = output
+	if err != nil {
+		return failResult(result, "failed to unhold packages", err)
+	}
+
+	result.Data["packages"] = packages
+	result.Success = true
+	return result
+}
+
+// listHeld lists held packages. Managers that hold by name only get the
+// installed version filled in
+func (e *Executor) listHeld(ctx context.Context, result *executor.Result) *executor.Result {
+	held, err := e.manager.listHeld(ctx)
+	if err != nil {
+		return failResult(result, "failed to list held packages", err)
+	}
+
+	missing := false
+	for _, p := range held {
+		missing = missing || p.Version == ""
+	}
+	if missing {
+		if installed, err := e.manager.listInstalled(ctx); err == nil {
+			versions := installedVersions(installed)
+			for i := range held {
+				if held[i].Version == "" {
+					held[i].Version = versions[held[i].Name]
+				}
+			}
+		}
+	}
+
+	result.Data["packages"] = held
+	result.Data["count"] = len(held)
+	result.Success = true
+	return result
+}
+
+// checkHeld refuses to move held packages explicitly; the package manager
+// would otherwise fail with a less obvious error or silently change the hold.
+// Managers that cannot list holds are not checked
+func (e *Executor) checkHeld(ctx context.Context, packages []string) error {
+	held, err := e.manager.listHeld(ctx)
+	if err != nil {
+		return nil
+	}
+	for _, p := range held {
+		for _, name := range packages {
+			if p.Name == name {
+				return fmt.Errorf("%s is held; unhold it first", name)
+			}
+		}
+	}
+	return nil
+}
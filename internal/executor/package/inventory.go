@@ -0,0 +1,281 @@
+package package_executor
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"einfra/agent/internal/inventory"
+)
+
+const (
+	dpkgStatusPath   = "/var/lib/dpkg/status"
+	dpkgInfoDir      = "/var/lib/dpkg/info"
+	apkInstalledPath = "/lib/apk/db/installed"
+	pacmanLocalDir   = "/var/lib/pacman/local"
+)
+
+// InventoryRecord is one installed package as recorded by the package database
+type InventoryRecord struct {
+	Name        string    `json:"name"`
+	Version     string    `json:"version"`
+	Release     string    `json:"release,omitempty"`
+	Arch        string    `json:"arch,omitempty"`
+	InstallTime time.Time `json:"install_time,omitempty"`
+	Size        int64     `json:"size,omitempty"` // installed size in bytes
+	Origin      string    `json:"origin,omitempty"`
+}
+
+// InventoryDelta lists records added, removed or changed between two inventories
+type InventoryDelta = inventory.Delta[InventoryRecord]
+
+// key identifies a record; multiarch systems install one name per arch
+func (r InventoryRecord) key() string {
+	return r.Name + "\x00" + r.Arch
+}
+
+// same compares records field by field, using time equality rather than struct equality
+func (r InventoryRecord) same(o InventoryRecord) bool {
+	return r.Name == o.Name && r.Version == o.Version && r.Release == o.Release && r.Arch == o.Arch &&
+		r.InstallTime.Equal(o.InstallTime) && r.Size == o.Size && r.Origin == o.Origin
+}
+
+// DiffInventory compares a previously reported inventory with the current one
+func DiffInventory(previous, current []InventoryRecord) InventoryDelta {
+	return inventory.Diff(previous, current, InventoryRecord.key, InventoryRecord.same)
+}
+
+// InventoryChecksum hashes an inventory so both sides can confirm they agree
+func InventoryChecksum(records []InventoryRecord) string {
+	return inventory.Checksum(records)
+}
+
+// sortInventory orders records by name, then arch
+func sortInventory(records []InventoryRecord) {
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Name != records[j].Name {
+			return records[i].Name < records[j].Name
+		}
+		return records[i].Arch < records[j].Arch
+	})
+}
+
+// Inventory reads installed packages from the package database, sorted by name
+func (e *Executor) Inventory(ctx context.Context) ([]InventoryRecord, error) {
+	if e.manager == nil {
+		return nil, fmt.Errorf("no supported package manager found")
+	}
+	records, err := e.manager.inventory(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sortInventory(records)
+	return records, nil
+}
+
+// splitRelease splits "1:2.3-4ubuntu1" into version "1:2.3" and release "4ubuntu1"
+func splitRelease(full string) (string, string) {
+	if i := strings.LastIndex(full, "-"); i > 0 {
+		return full[:i], full[i+1:]
+	}
+	return full, ""
+}
+
+// readStanzas parses "Key: value" paragraphs separated by blank lines, as used
+// by the dpkg status file and apk database. Continuation lines are skipped
+func readStanzas(path string, fn func(map[string]string)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	stanza := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if len(stanza) > 0 {
+				fn(stanza)
+				stanza = make(map[string]string)
+			}
+			continue
+		}
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			continue
+		}
+		if k, v, ok := strings.Cut(line, ":"); ok {
+			if _, seen := stanza[k]; !seen {
+				stanza[k] = strings.TrimSpace(v)
+			}
+		}
+	}
+	if len(stanza) > 0 {
+		fn(stanza)
+	}
+	return scanner.Err()
+}
+
+// readDpkgStatus reads installed packages from the dpkg status file. dpkg
+// records no install time, so the mtime of the package's file list stands in
+func readDpkgStatus(path string) ([]InventoryRecord, error) {
+	records := make([]InventoryRecord, 0)
+	err := readStanzas(path, func(s map[string]string) {
+		// Held packages read "hold ok installed"
+		status := strings.Fields(s["Status"])
+		if len(status) != 3 || status[1] != "ok" || status[2] != "installed" {
+			return
+		}
+		version, release := splitRelease(s["Version"])
+		r := InventoryRecord{
+			Name:    s["Package"],
+			Version: version,
+			Release: release,
+			Arch:    s["Architecture"],
+			Origin:  s["Origin"],
+		}
+		if kib, err := strconv.ParseInt(s["Installed-Size"], 10, 64); err == nil {
+			r.Size = kib * 1024
+		}
+		for _, list := range []string{r.Name + ":" + r.Arch + ".list", r.Name + ".list"} {
+			if info, err := os.Stat(filepath.Join(dpkgInfoDir, list)); err == nil {
+				r.InstallTime = info.ModTime().UTC().Truncate(time.Second)
+				break
+			}
+		}
+		records = append(records, r)
+	})
+	return records, err
+}
+
+// rpmQueryFormat emits one tab-separated line per package
+const rpmQueryFormat = "%{NAME}\t%{EPOCH}\t%{VERSION}\t%{RELEASE}\t%{ARCH}\t%{INSTALLTIME}\t%{SIZE}\t%{VENDOR}\n"
+
+// readRPMDB queries the rpm database
+func readRPMDB(ctx context.Context) ([]InventoryRecord, error) {
+	output, err := queryCommand(ctx, "rpm", "-qa", "--queryformat", rpmQueryFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	none := func(s string) string {
+		if s == "(none)" {
+			return ""
+		}
+		return s
+	}
+
+	records := make([]InventoryRecord, 0)
+	for _, line := range strings.Split(output, "\n") {
+		f := strings.Split(line, "\t")
+		// gpg-pubkey entries are imported keys, not packages
+		if len(f) < 8 || f[0] == "gpg-pubkey" {
+			continue
+		}
+		r := InventoryRecord{
+			Name:    f[0],
+			Version: f[2],
+			Release: f[3],
+			Arch:    none(f[4]),
+			Origin:  none(f[7]),
+		}
+		if epoch := none(f[1]); epoch != "" && epoch != "0" {
+			r.Version = epoch + ":" + r.Version
+		}
+		if ts, err := strconv.ParseInt(f[5], 10, 64); err == nil {
+			r.InstallTime = time.Unix(ts, 0).UTC()
+		}
+		r.Size, _ = strconv.ParseInt(f[6], 10, 64)
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+// readAPKDB reads the apk installed database. apk records no install time;
+// Origin is the source package the record was built from
+func readAPKDB(path string) ([]InventoryRecord, error) {
+	records := make([]InventoryRecord, 0)
+	err := readStanzas(path, func(s map[string]string) {
+		if s["P"] == "" {
+			return
+		}
+		version, release := splitRelease(s["V"])
+		r := InventoryRecord{
+			Name:    s["P"],
+			Version: version,
+			Release: release,
+			Arch:    s["A"],
+			Origin:  s["o"],
+		}
+		r.Size, _ = strconv.ParseInt(s["I"], 10, 64)
+		records = append(records, r)
+	})
+	return records, err
+}
+
+// readPacmanDB reads the desc file of every package in the pacman local database
+func readPacmanDB(dir string, repos map[string]string) ([]InventoryRecord, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]InventoryRecord, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name(), "desc"))
+		if err != nil {
+			continue
+		}
+
+		// Sections are "%NAME%" headers followed by values, separated by blank lines
+		fields := make(map[string]string)
+		var section string
+		for _, line := range strings.Split(string(data), "\n") {
+			switch {
+			case strings.HasPrefix(line, "%") && strings.HasSuffix(line, "%"):
+				section = strings.Trim(line, "%")
+			case line != "" && section != "":
+				if _, seen := fields[section]; !seen {
+					fields[section] = line
+				}
+			}
+		}
+
+		version, release := splitRelease(fields["VERSION"])
+		r := InventoryRecord{
+			Name:    fields["NAME"],
+			Version: version,
+			Release: release,
+			Arch:    fields["ARCH"],
+			Origin:  repos[fields["NAME"]],
+		}
+		if ts, err := strconv.ParseInt(fields["INSTALLDATE"], 10, 64); err == nil {
+			r.InstallTime = time.Unix(ts, 0).UTC()
+		}
+		r.Size, _ = strconv.ParseInt(fields["SIZE"], 10, 64)
+		if r.Name != "" {
+			records = append(records, r)
+		}
+	}
+	return records, nil
+}
+
+// inventoryFromList converts a plain package list for managers without a
+// readable database
+func inventoryFromList(packages []Package) []InventoryRecord {
+	records := make([]InventoryRecord, 0, len(packages))
+	for _, p := range packages {
+		records = append(records, InventoryRecord{Name: p.Name, Version: p.Version, Arch: p.Arch, Origin: p.Repo})
+	}
+	return records
+}
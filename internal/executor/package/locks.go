@@ -0,0 +1,206 @@
+package package_executor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"einfra/agent/internal/logger"
+)
+
+const (
+	// rpmLockPath is taken by every rpm transaction, whichever frontend runs it
+	rpmLockPath = "/var/lib/rpm/.rpm.lock"
+	// lockPollInterval is how often a held lock is checked again
+	lockPollInterval = 2 * time.Second
+)
+
+// lockKind says how a package manager marks a lock file as held
+type lockKind int
+
+const (
+	// lockHeld files are locked with fcntl or flock; the holder shows up in /proc/locks
+	lockHeld lockKind = iota
+	// lockPIDFile files hold the PID of the running process
+	lockPIDFile
+	// lockExists files are held for as long as they exist
+	lockExists
+)
+
+// lockFile is one lock a package manager takes while it works
+type lockFile struct {
+	path string
+	kind lockKind
+}
+
+// LockHolder identifies the process holding a package manager lock
+type LockHolder struct {
+	Path string `json:"path"`
+	// PID is 0 when the lock does not record its holder
+	PID     int    `json:"pid,omitempty"`
+	Command string `json:"command,omitempty"`
+}
+
+// lockError reports a lock that was still held when the wait timed out
+type lockError struct {
+	holder  LockHolder
+	timeout time.Duration
+}
+
+func (e *lockError) Error() string {
+	if e.holder.PID == 0 {
+		return fmt.Sprintf("%s is still locked after %s", e.holder.Path, e.timeout)
+	}
+	return fmt.Sprintf("%s is still held by PID %d (%s) after %s", e.holder.Path, e.holder.PID, e.holder.Command, e.timeout)
+}
+
+// acquire serializes package operations within the agent and returns the
+// function that releases the slot
+func (e *Executor) acquire(ctx context.Context) (func(), error) {
+	select {
+	case e.ops <- struct{}{}:
+		return func() { <-e.ops }, nil
+	default:
+	}
+
+	logger.Info().Msg("Waiting for another package operation to finish")
+	select {
+	case e.ops <- struct{}{}:
+		return func() { <-e.ops }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for another package operation: %w", ctx.Err())
+	}
+}
+
+// waitForLocks waits until no other process holds the package manager's
+// locks, giving up after timeout, and returns how long it waited
+func (e *Executor) waitForLocks(ctx context.Context, timeout time.Duration) (time.Duration, error) {
+	start := time.Now()
+	logged := false
+	for {
+		holder := findLockHolder(e.manager.lockFiles())
+		if holder == nil {
+			return time.Since(start), nil
+		}
+		if time.Since(start) >= timeout {
+			return time.Since(start), &lockError{holder: *holder, timeout: timeout}
+		}
+		if !logged {
+			logger.Info().
+				Str("lock", holder.Path).
+				Int("pid", holder.PID).
+				Str("command", holder.Command).
+				Dur("timeout", timeout).
+				Msg("Waiting for package manager lock")
+			logged = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return time.Since(start), ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+// findLockHolder returns the first lock held by another process
+func findLockHolder(locks []lockFile) *LockHolder {
+	self := os.Getpid()
+	for _, lock := range locks {
+		info, err := os.Stat(lock.path)
+		if err != nil {
+			continue
+		}
+
+		pid := 0
+		switch lock.kind {
+		case lockHeld:
+			// Open file description locks are held with no PID
+			var held bool
+			if pid, held = kernelLockHolder(lock.path, info); !held {
+				continue
+			}
+		case lockPIDFile:
+			data, err := os.ReadFile(lock.path)
+			if err != nil {
+				continue
+			}
+			pid, _ = strconv.Atoi(strings.TrimSpace(string(data)))
+			// PID files outlive crashed processes
+			if pid <= 0 || !dirExists(fmt.Sprintf("/proc/%d", pid)) {
+				continue
+			}
+		}
+		if pid == self {
+			continue
+		}
+
+		holder := &LockHolder{Path: lock.path, PID: pid}
+		if pid > 0 {
+			if comm, err := os.ReadFile(fmt.Sprintf("/proc/%d/comm", pid)); err == nil {
+				holder.Command = strings.TrimSpace(string(comm))
+			}
+		}
+		return holder
+	}
+	return nil
+}
+
+// procLock is one lock from /proc/locks. PID is -1 for open file
+// description locks, which have no owning process
+type procLock struct {
+	major, minor uint32
+	inode        uint64
+	pid          int
+}
+
+// parseProcLocks reads /proc/locks lines such as
+// "1: POSIX  ADVISORY  WRITE 1234 08:01:1310738 0 EOF", where the device
+// numbers are hexadecimal
+func parseProcLocks(data string) []procLock {
+	var locks []procLock
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		// "1: -> POSIX ..." lines are processes blocked waiting for the lock
+		if len(fields) < 6 || fields[1] == "->" {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[4])
+		if err != nil {
+			continue
+		}
+		id := strings.Split(fields[5], ":")
+		if len(id) != 3 {
+			continue
+		}
+		major, errMajor := strconv.ParseUint(id[0], 16, 32)
+		minor, errMinor := strconv.ParseUint(id[1], 16, 32)
+		inode, errInode := strconv.ParseUint(id[2], 10, 64)
+		if errMajor != nil || errMinor != nil || errInode != nil {
+			continue
+		}
+		locks = append(locks, procLock{major: uint32(major), minor: uint32(minor), inode: inode, pid: pid})
+	}
+	return locks
+}
+
+// matchLock reports whether a lock is held on the file and by which PID, 0
+// when the holder is an open file description lock. anyDevice matches on
+// the inode alone, for overlay filesystems, where stat reports a different
+// device than the one the kernel records for the lock
+func matchLock(locks []procLock, major, minor uint32, inode uint64, anyDevice bool) (int, bool) {
+	held := false
+	for _, l := range locks {
+		if l.inode != inode || (!anyDevice && (l.major != major || l.minor != minor)) {
+			continue
+		}
+		if l.pid > 0 {
+			return l.pid, true
+		}
+		held = true
+	}
+	return 0, held
+}
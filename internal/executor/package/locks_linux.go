@@ -0,0 +1,27 @@
+//go:build linux
+
+package package_executor
+
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// kernelLockHolder reports whether an fcntl or flock lock is held on the file
+// and the PID holding it, 0 when the holder is an open file description lock
+func kernelLockHolder(path string, info os.FileInfo) (int, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	data, err := os.ReadFile("/proc/locks")
+	if err != nil {
+		return 0, false
+	}
+
+	var fs unix.Statfs_t
+	overlay := unix.Statfs(path, &fs) == nil && fs.Type == unix.OVERLAYFS_SUPER_MAGIC
+	return matchLock(parseProcLocks(string(data)), unix.Major(uint64(stat.Dev)), unix.Minor(uint64(stat.Dev)), stat.Ino, overlay)
+}
@@ -2,19 +2,55 @@ package package_executor
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os/exec"
-	"runtime"
+	"regexp"
+	"time"
 
 	"einfra/agent/internal/executor"
+	"einfra/agent/internal/logger"
 )
 
+// validPackageName rejects names that a package manager could read as an option
+var validPackageName = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9+._:@~-]*$`)
+
+// validVersion covers epochs, Debian tildes and rpm releases
+var validVersion = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9+._:~-]*$`)
+
+const (
+	// defaultSearchResults is how many matches package_search returns by default
+	defaultSearchResults = 100
+	// maxSearchResults bounds a single package_search response
+	maxSearchResults = 1000
+)
+
+// readOnlyActions never take package manager locks, so they need not wait for them
+var readOnlyActions = map[string]bool{
+	"package_list":      true,
+	"package_search":    true,
+	"package_inventory": true,
+	"package_list_held": true,
+	"package_repo_list": true,
+}
+
 // Executor handles package management
-type Executor struct{}
+type Executor struct {
+	manager manager
+	// lockTimeout bounds the wait for locks held by other package manager processes
+	lockTimeout time.Duration
+	// ops holds a token while a package action runs, so actions never overlap
+	ops chan struct{}
+}
 
-// NewExecutor creates a package executor
-func NewExecutor() *Executor {
-	return &Executor{}
+// NewExecutor creates a package executor for the detected package manager
+func NewExecutor(lockTimeout time.Duration) *Executor {
+	m := detectManager()
+	if m == nil {
+		logger.Warn().Msg("No supported package manager found")
+	} else {
+		logger.Info().Str("manager", m.name()).Msg("Detected package manager")
+	}
+	return &Executor{manager: m, lockTimeout: lockTimeout, ops: make(chan struct{}, 1)}
 }
 
 // SupportedActions returns supported actions
@@ -23,6 +59,18 @@ func (e *Executor) SupportedActions() []string {
 		"package_list",
 		"package_install",
 		"package_update",
+		"package_remove",
+		"package_search",
+		"package_inventory",
+		"package_check_updates",
+		"package_repo_list",
+		"package_repo_add",
+		"package_repo_remove",
+		"package_key_import",
+		"package_key_remove",
+		"package_hold",
+		"package_unhold",
+		"package_list_held",
 	}
 }
 
@@ -32,12 +80,70 @@ func (e *Executor) Execute(ctx context.Context, action *executor.Action) *execut
 		ActionID: action.ID,
 		Data:     make(map[string]interface{}),
 	}
-	
+
+	if e.manager == nil {
+		result.Success = false
+		result.Error = "no supported package manager found"
+		return result
+	}
+	result.Data["manager"] = e.manager.name()
+
+	release, err := e.acquire(ctx)
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+	defer release()
+
+	if !readOnlyActions[action.Type] {
+		timeout := time.Duration(action.IntParam("lock_timeout", int(e.lockTimeout.Seconds()))) * time.Second
+		waited, err := e.waitForLocks(ctx, timeout)
+		if waited >= lockPollInterval {
+			result.Data["lock_waited"] = waited.Round(100 * time.Millisecond).Seconds()
+		}
+		if err != nil {
+			var lockErr *lockError
+			if errors.As(err, &lockErr) {
+				result.Data["lock"] = lockErr.holder
+			}
+			result.Success = false
+			result.Error = fmt.Sprintf("package manager is busy: %v", err)
+			return result
+		}
+	}
+
 	switch action.Type {
 	case "package_list":
 		return e.listPackages(ctx, result)
 	case "package_install":
 		return e.installPackage(ctx, action, result)
+	case "package_update":
+		return e.updatePackages(ctx, action, result)
+	case "package_remove":
+		return e.removePackage(ctx, action, result)
+	case "package_search":
+		return e.searchPackages(ctx, action, result)
+	case "package_inventory":
+		return e.inventory(ctx, result)
+	case "package_check_updates":
+		return e.checkUpdates(ctx, action, result)
+	case "package_repo_list":
+		return e.listRepos(ctx, result)
+	case "package_repo_add":
+		return e.addRepo(ctx, action, result)
+	case "package_repo_remove":
+		return e.removeRepo(ctx, action, result)
+	case "package_key_import":
+		return e.importKey(ctx, action, result)
+	case "package_key_remove":
+		return e.removeKey(ctx, action, result)
+	case "package_hold":
+		return e.holdPackages(ctx, action, result)
+	case "package_unhold":
+		return e.unholdPackages(ctx, action, result)
+	case "package_list_held":
+		return e.listHeld(ctx, result)
 	default:
 		result.Success = false
 		result.Error = "unknown package action"
@@ -47,56 +153,257 @@ func (e *Executor) Execute(ctx context.Context, action *executor.Action) *execut
 
 // listPackages lists installed packages
 func (e *Executor) listPackages(ctx context.Context, result *executor.Result) *executor.Result {
-	var cmd *exec.Cmd
-	
-	if runtime.GOOS == "linux" {
-		// Try dpkg first (Debian/Ubuntu)
-		cmd = exec.CommandContext(ctx, "dpkg", "-l")
-	} else if runtime.GOOS == "windows" {
-		cmd = exec.CommandContext(ctx, "powershell", "-Command",
-			"Get-Package | Select-Object Name,Version | ConvertTo-Json")
-	}
-	
-	output, err := cmd.CombinedOutput()
+	packages, err := e.manager.listInstalled(ctx)
 	if err != nil {
 		result.Success = false
 		result.Error = fmt.Sprintf("failed to list packages: %v", err)
 		return result
 	}
-	
-	result.Output = string(output)
+
+	result.Data["packages"] = packages
+	result.Data["count"] = len(packages)
+	result.Success = true
+	return result
+}
+
+// inventory returns installed packages read from the package database
+func (e *Executor) inventory(ctx context.Context, result *executor.Result) *executor.Result {
+	records, err := e.Inventory(ctx)
+	if err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to read package inventory: %v", err)
+		return result
+	}
+
+	result.Data["packages"] = records
+	result.Data["count"] = len(records)
+	result.Data["checksum"] = InventoryChecksum(records)
 	result.Success = true
 	return result
 }
 
-// installPackage installs a package
+// checkUpdates reports pending updates and whether a reboot is required
+func (e *Executor) checkUpdates(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	status, err := e.updateStatus(ctx, action.BoolParam("refresh", true))
+	if err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to check updates: %v", err)
+		return result
+	}
+
+	result.Data["count"] = status.Count
+	result.Data["security_count"] = status.SecurityCount
+	result.Data["updates"] = status.Updates
+	result.Data["reboot"] = status.Reboot
+	result.Data["checked_at"] = status.CheckedAt
+	result.Success = true
+	return result
+}
+
+// installPackage installs one or more packages, or one package at an exact
+// version. Moving to an older version requires allow_downgrade
 func (e *Executor) installPackage(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
-	packageName, ok := action.Params["package"].(string)
+	packages, err := packagesParam(action)
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+
+	version := action.StringParam("version", "")
+	downgrade := false
+	if version != "" {
+		if len(packages) != 1 {
+			result.Success = false
+			result.Error = "'version' requires exactly one package"
+			return result
+		}
+		if !validVersion.MatchString(version) {
+			result.Success = false
+			result.Error = fmt.Sprintf("invalid version: %s", version)
+			return result
+		}
+
+		installed, older, err := e.isDowngrade(ctx, packages[0], version)
+		if err != nil {
+			return failResult(result, "failed to compare versions", err)
+		}
+		if older && !action.BoolParam("allow_downgrade", false) {
+			result.Success = false
+			result.Error = fmt.Sprintf("%s %s is older than the installed %s; set allow_downgrade to downgrade", packages[0], version, installed)
+			return result
+		}
+		if err := e.checkHeld(ctx, packages); err != nil {
+			result.Success = false
+			result.Error = err.Error()
+			return result
+		}
+		downgrade = older
+		result.Data["version"] = version
+		result.Data["downgrade"] = downgrade
+	}
+
+	logger.Info().
+		Strs("packages", packages).
+		Str("version", version).
+		Bool("downgrade", downgrade).
+		Str("manager", e.manager.name()).
+		Msg("Installing packages")
+
+	return e.applyChange(ctx, result, "install", func() (string, error) {
+		return e.manager.install(ctx, packages, version, downgrade)
+	})
+}
+
+// isDowngrade reports whether version is older than the installed version of
+// name, which is empty when the package is not installed
+func (e *Executor) isDowngrade(ctx context.Context, name, version string) (string, bool, error) {
+	packages, err := e.manager.listInstalled(ctx)
+	if err != nil {
+		return "", false, err
+	}
+	for _, p := range packages {
+		if p.Name != name {
+			continue
+		}
+		cmp, err := e.manager.compareVersions(ctx, version, p.Version)
+		if err != nil {
+			return p.Version, false, err
+		}
+		return p.Version, cmp < 0, nil
+	}
+	return "", false, nil
+}
+
+// updatePackages upgrades the given packages, or all of them when none are given
+func (e *Executor) updatePackages(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	var packages []string
+	if action.HasParam("package") || action.HasParam("packages") {
+		var err error
+		if packages, err = packagesParam(action); err != nil {
+			result.Success = false
+			result.Error = err.Error()
+			return result
+		}
+		if err = e.checkHeld(ctx, packages); err != nil {
+			result.Success = false
+			result.Error = err.Error()
+			return result
+		}
+	}
+	securityOnly := action.BoolParam("security_only", false)
+
+	logger.Info().
+		Strs("packages", packages).
+		Bool("security_only", securityOnly).
+		Str("manager", e.manager.name()).
+		Msg("Updating packages")
+
+	return e.applyChange(ctx, result, "update", func() (string, error) {
+		return e.manager.update(ctx, packages, securityOnly)
+	})
+}
+
+// removePackage removes one or more packages, optionally purging their configuration
+func (e *Executor) removePackage(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	packages, err := packagesParam(action)
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+	purge := action.BoolParam("purge", false)
+
+	logger.Info().
+		Strs("packages", packages).
+		Bool("purge", purge).
+		Str("manager", e.manager.name()).
+		Msg("Removing packages")
+
+	return e.applyChange(ctx, result, "remove", func() (string, error) {
+		return e.manager.remove(ctx, packages, purge)
+	})
+}
+
+// searchPackages finds packages available from the configured repositories
+func (e *Executor) searchPackages(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	query, ok := action.Params["query"].(string)
 	if !ok {
 		result.Success = false
-		result.Error = "missing 'package' parameter"
+		result.Error = "missing 'query' parameter"
 		return result
 	}
-	
-	var cmd *exec.Cmd
-	
-	if runtime.GOOS == "linux" {
-		// Use apt-get (requires root)
-		cmd = exec.CommandContext(ctx, "apt-get", "install", "-y", packageName)
-	} else if runtime.GOOS == "windows" {
-		// Use choco if available
-		cmd = exec.CommandContext(ctx, "choco", "install", packageName, "-y")
-	}
-	
-	output, err := cmd.CombinedOutput()
-	result.Output = string(output)
-	
+	if !validPackageName.MatchString(query) {
+		result.Success = false
+		result.Error = fmt.Sprintf("invalid search query: %s", query)
+		return result
+	}
+
+	limit := action.IntParam("limit", defaultSearchResults)
+	if limit <= 0 || limit > maxSearchResults {
+		result.Success = false
+		result.Error = fmt.Sprintf("'limit' must be between 1 and %d", maxSearchResults)
+		return result
+	}
+
+	packages, err := e.manager.search(ctx, query)
+	if err != nil {
+		return failResult(result, "failed to search packages", err)
+	}
+
+	result.Data["total"] = len(packages)
+	result.Data["truncated"] = len(packages) > limit
+	if len(packages) > limit {
+		packages = packages[:limit]
+	}
+	result.Data["packages"] = packages
+	result.Data["count"] = len(packages)
+	result.Success = true
+	return result
+}
+
+// applyChange runs a modifying operation and reports what it changed by
+// comparing the package inventory before and after. Changes are reported even
+// when the operation fails part-way
+func (e *Executor) applyChange(ctx context.Context, result *executor.Result, verb string, op func() (string, error)) *executor.Result {
+	before, err := e.manager.listInstalled(ctx)
 	if err != nil {
 		result.Success = false
-		result.Error = fmt.Sprintf("failed to install package: %v", err)
-	} else {
-		result.Success = true
+		result.Error = fmt.Sprintf("failed to read package inventory: %v", err)
+		return result
 	}
-	
+
+	output, opErr := op()
+	result.Output = output
+
+	if after, err := e.manager.listInstalled(ctx); err == nil {
+		changes := diffPackages(before, after)
+		result.Data["changes"] = changes
+		result.Data["changed"] = len(changes) > 0
+	}
+
+	if opErr != nil {
+		return failResult(result, fmt.Sprintf("failed to %s package", verb), opErr)
+	}
+
+	result.Success = true
 	return result
 }
+
+// packagesParam reads 'package' (one name, or a comma-separated list) or
+// 'packages' (a list) and validates every name
+func packagesParam(action *executor.Action) ([]string, error) {
+	packages := action.StringsParam("packages")
+	if len(packages) == 0 {
+		packages = action.StringsParam("package")
+	}
+	if len(packages) == 0 {
+		return nil, fmt.Errorf("missing 'package' parameter")
+	}
+	for _, p := range packages {
+		if !validPackageName.MatchString(p) {
+			return nil, fmt.Errorf("invalid package name: %s", p)
+		}
+	}
+	return packages, nil
+}
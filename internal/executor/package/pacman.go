@@ -0,0 +1,239 @@
+package package_executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// pacmanConfPath holds IgnorePkg, pacman's list of packages upgrades skip
+const pacmanConfPath = "/etc/pacman.conf"
+
+// pacmanManager drives pacman for Arch and derivatives
+type pacmanManager struct{}
+
+// name is the binary detection looks for
+func (m *pacmanManager) name() string {
+	return "pacman"
+}
+
+// lockFiles is pacman's database lock, which is held by existing
+func (m *pacmanManager) lockFiles() []lockFile {
+	return []lockFile{{path: "/var/lib/pacman/db.lck", kind: lockExists}}
+}
+
+// listInstalled parses `pacman -Qi` and maps each package to the sync
+// repository that offers it
+func (m *pacmanManager) listInstalled(ctx context.Context) ([]Package, error) {
+	output, err := queryCommand(ctx, "pacman", "-Qi")
+	if err != nil {
+		return nil, err
+	}
+
+	repos := m.syncRepos(ctx)
+	packages := make([]Package, 0)
+	var current Package
+	flush := func() {
+		if current.Name != "" {
+			current.Repo = repos[current.Name]
+			if current.Repo == "" {
+				current.Repo = "local"
+			}
+			packages = append(packages, current)
+		}
+		current = Package{}
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "Name":
+			current.Name = strings.TrimSpace(value)
+		case "Version":
+			current.Version = strings.TrimSpace(value)
+		case "Architecture":
+			current.Arch = strings.TrimSpace(value)
+		}
+	}
+	flush()
+	return packages, nil
+}
+
+// install installs packages without prompting. Sync repositories only carry
+// the latest build, so exact versions cannot be installed from them
+func (m *pacmanManager) install(ctx context.Context, packages []string, version string, downgrade bool) (string, error) {
+	if version != "" {
+		return "", unsupported(m.name(), "installing a specific version")
+	}
+	args := append([]string{"-S", "--noconfirm", "--needed"}, packages...)
+	return runCommand(ctx, "pacman", args...)
+}
+
+// update upgrades the whole system. Arch does not support partial upgrades,
+// so individual packages are refused rather than risking a broken system
+func (m *pacmanManager) update(ctx context.Context, packages []string, securityOnly bool) (string, error) {
+	if securityOnly {
+		return "", unsupported(m.name(), "security-only updates")
+	}
+	if len(packages) > 0 {
+		return "", unsupported(m.name(), "updating individual packages (partial upgrades)")
+	}
+	return runCommand(ctx, "pacman", "-Syu", "--noconfirm")
+}
+
+// remove removes packages; purging also drops the .pacsave backups of their config files
+func (m *pacmanManager) remove(ctx context.Context, packages []string, purge bool) (string, error) {
+	op := "-R"
+	if purge {
+		op = "-Rn"
+	}
+	return runCommand(ctx, "pacman", append([]string{op, "--noconfirm"}, packages...)...)
+}
+
+// search parses `pacman -Ss`: "repo/name version [installed]" followed by an
+// indented description line
+func (m *pacmanManager) search(ctx context.Context, query string) ([]Package, error) {
+	output, err := queryCommand(ctx, "pacman", "-Ss", query)
+	if err != nil {
+		// pacman exits 1 when nothing matches
+		if strings.TrimSpace(output) == "" {
+			return []Package{}, nil
+		}
+		return nil, err
+	}
+
+	packages := make([]Package, 0)
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, " ") {
+			if len(packages) > 0 {
+				packages[len(packages)-1].Summary = strings.TrimSpace(line)
+			}
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		repo, name, ok := strings.Cut(fields[0], "/")
+		if !ok {
+			continue
+		}
+		packages = append(packages, Package{Name: name, Version: fields[1], Repo: repo})
+	}
+	return packages, nil
+}
+
+// inventory reads the pacman local database; Origin is the sync repository
+func (m *pacmanManager) inventory(ctx context.Context) ([]InventoryRecord, error) {
+	return readPacmanDB(pacmanLocalDir, m.syncRepos(ctx))
+}
+
+// checkUpdates prefers checkupdates from pacman-contrib, which syncs into a
+// temporary database; `pacman -Qu` only sees the last sync. pacman has no
+// security metadata
+func (m *pacmanManager) checkUpdates(ctx context.Context, refresh bool) ([]Update, int, error) {
+	var output string
+	var err error
+	var exitErr *exec.ExitError
+	if commandExists("checkupdates") && refresh {
+		output, err = queryCommand(ctx, "checkupdates")
+		// checkupdates exits 2 when there is nothing to update
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 2 {
+			err = nil
+		}
+	} else {
+		output, err = queryCommand(ctx, "pacman", "-Qu")
+		// pacman exits 1 when there is nothing to update
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 && strings.TrimSpace(output) == "" {
+			err = nil
+		}
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	updates := make([]Update, 0)
+	for _, line := range strings.Split(output, "\n") {
+		// linux 6.6.1.arch1-1 -> 6.6.2.arch1-1
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[2] != "->" {
+			continue
+		}
+		updates = append(updates, Update{Name: fields[0], Current: fields[1], Available: fields[3]})
+	}
+	return updates, -1, nil
+}
+
+// syncRepos maps package names to their sync repository from `pacman -Sl`
+func (m *pacmanManager) syncRepos(ctx context.Context) map[string]string {
+	repos := make(map[string]string)
+	output, err := queryCommand(ctx, "pacman", "-Sl")
+	if err != nil {
+		return repos
+	}
+	for _, line := range strings.Split(output, "\n") {
+		// core linux 6.6.1.arch1-1 [installed]
+		fields := strings.Fields(line)
+		if len(fields) >= 2 {
+			if _, seen := repos[fields[1]]; !seen {
+				repos[fields[1]] = fields[0]
+			}
+		}
+	}
+	return repos
+}
+
+// compareVersions asks vercmp, which prints -1, 0 or 1
+func (m *pacmanManager) compareVersions(ctx context.Context, a, b string) (int, error) {
+	output, err := queryCommand(ctx, "vercmp", a, b)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(output))
+	if err != nil {
+		return 0, fmt.Errorf("unexpected vercmp output: %q", strings.TrimSpace(output))
+	}
+	// vercmp may print any negative or positive number
+	switch {
+	case n < 0:
+		return -1, nil
+	case n > 0:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// hold would mean editing IgnorePkg in pacman.conf, which is left to configuration management
+func (m *pacmanManager) hold(ctx context.Context, packages []string) (string, error) {
+	return "", unsupported(m.name(), "holding packages")
+}
+
+// unhold is not supported, see hold
+func (m *pacmanManager) unhold(ctx context.Context, packages []string) (string, error) {
+	return "", unsupported(m.name(), "holding packages")
+}
+
+// listHeld reports the IgnorePkg entries of pacman.conf
+func (m *pacmanManager) listHeld(ctx context.Context) ([]Package, error) {
+	packages := make([]Package, 0)
+	for _, line := range readLines(pacmanConfPath) {
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || strings.TrimSpace(key) != "IgnorePkg" {
+			continue
+		}
+		for _, name := range strings.Fields(value) {
+			packages = append(packages, Package{Name: name})
+		}
+	}
+	return packages, nil
+}
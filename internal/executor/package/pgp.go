@@ -0,0 +1,193 @@
+package package_executor
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const (
+	pgpArmorBegin = "-----BEGIN PGP PUBLIC KEY BLOCK-----"
+	pgpArmorEnd   = "-----END PGP PUBLIC KEY BLOCK-----"
+)
+
+// pgpKey is an OpenPGP public key as supplied by the caller
+type pgpKey struct {
+	// Binary is the de-armored key material
+	Binary []byte
+	// Fingerprint identifies the primary key, upper-case hex
+	Fingerprint string
+	// KeyID is the 64-bit key ID, lower-case hex
+	KeyID string
+}
+
+// parsePGPKey accepts an ASCII-armored key block or base64 of a binary key
+// and reads the primary public key packet to validate it and derive its
+// fingerprint
+func parsePGPKey(text string) (*pgpKey, error) {
+	var data []byte
+	var err error
+	if strings.Contains(text, pgpArmorBegin) {
+		data, err = dearmor(text)
+	} else {
+		data, err = base64.StdEncoding.DecodeString(strings.Join(strings.Fields(text), ""))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid key encoding: %v", err)
+	}
+
+	tag, body, err := firstPacket(data)
+	if err != nil {
+		return nil, err
+	}
+	if tag != 6 {
+		return nil, fmt.Errorf("not a public key: first packet has tag %d", tag)
+	}
+	if len(body) == 0 {
+		return nil, fmt.Errorf("empty public key packet")
+	}
+
+	key := &pgpKey{Binary: data}
+	switch body[0] {
+	case 4:
+		h := sha1.New()
+		h.Write([]byte{0x99, byte(len(body) >> 8), byte(len(body))})
+		h.Write(body)
+		fpr := h.Sum(nil)
+		key.Fingerprint = strings.ToUpper(hex.EncodeToString(fpr))
+		key.KeyID = hex.EncodeToString(fpr[len(fpr)-8:])
+	case 5, 6:
+		prefix := byte(0x9A)
+		if body[0] == 6 {
+			prefix = 0x9B
+		}
+		h := sha256.New()
+		h.Write([]byte{prefix})
+		binary.Write(h, binary.BigEndian, uint32(len(body)))
+		h.Write(body)
+		fpr := h.Sum(nil)
+		key.Fingerprint = strings.ToUpper(hex.EncodeToString(fpr))
+		key.KeyID = hex.EncodeToString(fpr[:8])
+	default:
+		return nil, fmt.Errorf("unsupported public key version %d", body[0])
+	}
+	return key, nil
+}
+
+// Armored renders the key as an ASCII-armored block
+func (k *pgpKey) Armored() string {
+	var b strings.Builder
+	b.WriteString(pgpArmorBegin + "\n\n")
+	encoded := base64.StdEncoding.EncodeToString(k.Binary)
+	for len(encoded) > 64 {
+		b.WriteString(encoded[:64] + "\n")
+		encoded = encoded[64:]
+	}
+	b.WriteString(encoded + "\n")
+
+	crc := crc24(k.Binary)
+	b.WriteString("=" + base64.StdEncoding.EncodeToString([]byte{byte(crc >> 16), byte(crc >> 8), byte(crc)}) + "\n")
+	b.WriteString(pgpArmorEnd + "\n")
+	return b.String()
+}
+
+// dearmor decodes the base64 body of an armored block, skipping armor headers
+// and the CRC line
+func dearmor(text string) ([]byte, error) {
+	start := strings.Index(text, pgpArmorBegin)
+	end := strings.Index(text, pgpArmorEnd)
+	if end < start {
+		return nil, fmt.Errorf("missing armor end line")
+	}
+	lines := strings.Split(text[start+len(pgpArmorBegin):end], "\n")
+
+	var body bytes.Buffer
+	inHeaders := true
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		// Headers ("Version: ...") run until the first blank line, which may be missing
+		if inHeaders && (line == "" || strings.Contains(line, ": ")) {
+			inHeaders = line != ""
+			continue
+		}
+		inHeaders = false
+		if strings.HasPrefix(line, "=") {
+			break
+		}
+		body.WriteString(line)
+	}
+	return base64.StdEncoding.DecodeString(body.String())
+}
+
+// firstPacket returns the tag and body of the first OpenPGP packet
+func firstPacket(data []byte) (int, []byte, error) {
+	if len(data) < 2 || data[0]&0x80 == 0 {
+		return 0, nil, fmt.Errorf("not an OpenPGP packet")
+	}
+
+	var tag, length, offset int
+	if data[0]&0x40 != 0 {
+		// New format: tag in the low six bits, variable-length size
+		tag = int(data[0] & 0x3f)
+		switch l0 := int(data[1]); {
+		case l0 < 192:
+			length, offset = l0, 2
+		case l0 < 224:
+			if len(data) < 3 {
+				return 0, nil, fmt.Errorf("truncated packet header")
+			}
+			length, offset = (l0-192)<<8+int(data[2])+192, 3
+		case l0 == 255:
+			if len(data) < 6 {
+				return 0, nil, fmt.Errorf("truncated packet header")
+			}
+			length, offset = int(binary.BigEndian.Uint32(data[2:6])), 6
+		default:
+			return 0, nil, fmt.Errorf("partial-length key packets are not allowed")
+		}
+	} else {
+		// Old format: tag in bits 2-5, length type in the low two bits
+		tag = int(data[0]>>2) & 0x0f
+		switch data[0] & 0x03 {
+		case 0:
+			length, offset = int(data[1]), 2
+		case 1:
+			if len(data) < 3 {
+				return 0, nil, fmt.Errorf("truncated packet header")
+			}
+			length, offset = int(binary.BigEndian.Uint16(data[1:3])), 3
+		case 2:
+			if len(data) < 5 {
+				return 0, nil, fmt.Errorf("truncated packet header")
+			}
+			length, offset = int(binary.BigEndian.Uint32(data[1:5])), 5
+		default:
+			return 0, nil, fmt.Errorf("indeterminate-length key packets are not allowed")
+		}
+	}
+
+	if length < 0 || offset+length > len(data) {
+		return 0, nil, fmt.Errorf("truncated packet")
+	}
+	return tag, data[offset : offset+length], nil
+}
+
+// crc24 is the OpenPGP armor checksum
+func crc24(data []byte) uint32 {
+	crc := uint32(0xB704CE)
+	for _, b := range data {
+		crc ^= uint32(b) << 16
+		for i := 0; i < 8; i++ {
+			crc <<= 1
+			if crc&0x1000000 != 0 {
+				crc ^= 0x1864CFB
+			}
+		}
+	}
+	return crc & 0xFFFFFF
+}
@@ -0,0 +1,151 @@
+package package_executor
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const (
+	rebootRequiredPath = "/var/run/reboot-required"
+	rebootPackagesPath = "/var/run/reboot-required.pkgs"
+)
+
+// RebootStatus reports whether installed updates only take effect after a reboot
+type RebootStatus struct {
+	Required      bool     `json:"required"`
+	Reasons       []string `json:"reasons"`
+	Packages      []string `json:"packages,omitempty"`
+	RunningKernel string   `json:"running_kernel,omitempty"`
+	LatestKernel  string   `json:"latest_kernel,omitempty"`
+}
+
+// checkReboot combines the distribution's reboot flag with a comparison of
+// the running kernel against the newest installed one
+func checkReboot(ctx context.Context) RebootStatus {
+	status := RebootStatus{Reasons: make([]string, 0)}
+	if runtime.GOOS == "windows" {
+		checkWindowsReboot(ctx, &status)
+		return status
+	}
+
+	// Debian and Ubuntu packages drop this flag from their postinst scripts
+	if _, err := os.Stat(rebootRequiredPath); err == nil {
+		status.Required = true
+		status.Reasons = append(status.Reasons, rebootRequiredPath+" exists")
+		status.Packages = readLines(rebootPackagesPath)
+	}
+
+	// RHEL family: needs-restarting -r exits 1 when a reboot is needed
+	var cmd *exec.Cmd
+	if commandExists("needs-restarting") {
+		cmd = exec.CommandContext(ctx, "needs-restarting", "-r")
+	} else if commandExists("dnf") {
+		cmd = exec.CommandContext(ctx, "dnf", "needs-restarting", "-r")
+	}
+	if cmd != nil {
+		output, err := cmd.CombinedOutput()
+		// A missing dnf plugin also exits 1, so insist on the verdict in the output
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 &&
+			strings.Contains(strings.ToLower(string(output)), "reboot is required") {
+			status.Required = true
+			status.Reasons = append(status.Reasons, "needs-restarting -r reports a reboot is required")
+		}
+	}
+
+	running, latest, stale := kernelStatus()
+	status.RunningKernel = running
+	status.LatestKernel = latest
+	if stale {
+		status.Required = true
+		if latest != "" {
+			status.Reasons = append(status.Reasons, "running kernel "+running+" is not the newest installed kernel "+latest)
+		} else {
+			status.Reasons = append(status.Reasons, "modules for running kernel "+running+" were removed")
+		}
+	}
+
+	return status
+}
+
+// kernelStatus compares the running kernel with the newest kernel image in
+// /boot. Distributions that install an unversioned image (Alpine's
+// vmlinuz-lts) are detected by the running kernel's modules disappearing
+func kernelStatus() (running, latest string, stale bool) {
+	data, err := os.ReadFile("/proc/sys/kernel/osrelease")
+	if err != nil {
+		return "", "", false
+	}
+	running = strings.TrimSpace(string(data))
+
+	images, _ := filepath.Glob("/boot/vmlinuz-*")
+	var newest os.FileInfo
+	for _, image := range images {
+		info, err := os.Stat(image)
+		if err != nil {
+			continue
+		}
+		if newest == nil || info.ModTime().After(newest.ModTime()) {
+			newest = info
+			latest = strings.TrimPrefix(filepath.Base(image), "vmlinuz-")
+		}
+	}
+
+	// Only versioned image names can be compared with the running release
+	if latest != "" && dirExists(filepath.Join("/lib/modules", latest)) {
+		return running, latest, latest != running
+	}
+
+	if dirExists("/lib/modules") && !dirExists(filepath.Join("/lib/modules", running)) {
+		return running, "", true
+	}
+	return running, "", false
+}
+
+// checkWindowsReboot reads the pending-reboot flags Windows Update and CBS set
+func checkWindowsReboot(ctx context.Context, status *RebootStatus) {
+	flags := []struct{ key, source string }{
+		{`HKLM:\SOFTWARE\Microsoft\Windows\CurrentVersion\WindowsUpdate\Auto Update\RebootRequired`, "Windows Update"},
+		{`HKLM:\SOFTWARE\Microsoft\Windows\CurrentVersion\Component Based Servicing\RebootPending`, "Component Based Servicing"},
+	}
+	for _, flag := range flags {
+		output, err := exec.CommandContext(ctx, "powershell", "-Command", "Test-Path '"+flag.key+"'").Output()
+		if err == nil && strings.TrimSpace(string(output)) == "True" {
+			status.Required = true
+			status.Reasons = append(status.Reasons, flag.source+" reports a pending reboot")
+		}
+	}
+}
+
+// readLines returns the unique non-empty lines of a file
+func readLines(path string) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var lines []string
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !seen[line] {
+			seen[line] = true
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// dirExists reports whether path is a directory
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,392 @@
+package package_executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"einfra/agent/internal/executor"
+	"einfra/agent/internal/fsutil"
+	"einfra/agent/internal/logger"
+)
+
+var (
+	// validRepoName is used verbatim as a file name and INI section
+	validRepoName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+	// validKeyName also allows apk signer names such as alpine-devel@lists.alpinelinux.org-6165ee59
+	validKeyName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.@+-]*$`)
+	// validRepoToken covers apt suites, components and architectures; a
+	// trailing slash marks a flat repository suite
+	validRepoToken = regexp.MustCompile(`^[A-Za-z0-9._/-]+$`)
+)
+
+// Repository is one configured package source
+type Repository struct {
+	Name string `json:"name"`
+	// Type is the source kind: deb or deb-src for apt, rpm-md for yum and zypper, apk
+	Type        string   `json:"type,omitempty"`
+	URL         string   `json:"url"`
+	Suites      []string `json:"suites,omitempty"`
+	Components  []string `json:"components,omitempty"`
+	Arch        []string `json:"arch,omitempty"`
+	Enabled     bool     `json:"enabled"`
+	GPGCheck    bool     `json:"gpg_check"`
+	Key         string   `json:"key,omitempty"`
+	Description string   `json:"description,omitempty"`
+	File        string   `json:"file"`
+}
+
+// KeyInfo describes an imported repository signing key
+type KeyInfo struct {
+	Name        string `json:"name"`
+	File        string `json:"file"`
+	Fingerprint string `json:"fingerprint"`
+	KeyID       string `json:"key_id,omitempty"`
+}
+
+// repoStore edits the repository configuration of one package manager
+type repoStore interface {
+	list() ([]Repository, error)
+	// path is the file add rewrites for the named repository
+	path(name string) string
+	// add writes the definition and returns it with defaults filled in
+	add(repo Repository) (Repository, error)
+	remove(name string) error
+	// importKey installs a key and returns a function that undoes the import
+	importKey(ctx context.Context, name, data string) (*KeyInfo, func(ctx context.Context) error, string, error)
+	removeKey(ctx context.Context, name string) (string, error)
+	// refresh downloads repository metadata so configuration errors surface
+	refresh(ctx context.Context) (string, error)
+}
+
+// repoConfigurer is implemented by managers whose repositories are plain files
+type repoConfigurer interface {
+	repos() repoStore
+}
+
+// repos returns the manager's repository store, or an unsupported error
+func (e *Executor) repos() (repoStore, error) {
+	if c, ok := e.manager.(repoConfigurer); ok {
+		return c.repos(), nil
+	}
+	return nil, unsupported(e.manager.name(), "repository management")
+}
+
+// listRepos lists configured repositories
+func (e *Executor) listRepos(ctx context.Context, result *executor.Result) *executor.Result {
+	store, err := e.repos()
+	if err != nil {
+		return failResult(result, "", err)
+	}
+
+	repos, err := store.list()
+	if err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to list repositories: %v", err)
+		return result
+	}
+
+	result.Data["repositories"] = repos
+	result.Data["count"] = len(repos)
+	result.Success = true
+	return result
+}
+
+// addRepo writes a repository definition, optionally importing its key first,
+// and refreshes metadata. A definition that breaks the refresh is rolled back,
+// along with the key imported for it
+func (e *Executor) addRepo(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	store, err := e.repos()
+	if err != nil {
+		return failResult(result, "", err)
+	}
+
+	repo, err := repoParams(action)
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+	keyData := action.StringParam("key_data", "")
+	if keyData != "" && repo.Key == "" {
+		repo.Key = repo.Name
+	}
+
+	path := store.path(repo.Name)
+	previous, readErr := os.ReadFile(path)
+	existed := readErr == nil
+	if readErr != nil && !errors.Is(readErr, os.ErrNotExist) {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to read %s: %v", path, readErr)
+		return result
+	}
+
+	logger.Info().
+		Str("repository", repo.Name).
+		Str("url", repo.URL).
+		Str("manager", e.manager.name()).
+		Msg("Adding package repository")
+
+	var output strings.Builder
+	// undoKey removes an imported key again when the repository cannot be added
+	var undoKey func(ctx context.Context) error
+	if keyData != "" {
+		key, undo, out, err := store.importKey(ctx, repo.Key, keyData)
+		output.WriteString(out)
+		if err != nil {
+			result.Output = output.String()
+			return failResult(result, "failed to import key", err)
+		}
+		result.Data["key"] = key
+		undoKey = undo
+	}
+
+	repo, err = store.add(repo)
+	if err != nil {
+		result.Output = output.String()
+		if undoKey != nil {
+			result.Data["key_rolled_back"] = undoKey(ctx) == nil
+		}
+		return failResult(result, "failed to add repository", err)
+	}
+	result.Data["file"] = path
+	result.Data["replaced"] = existed
+
+	if action.BoolParam("refresh", true) {
+		out, err := store.refresh(ctx)
+		output.WriteString(out)
+		result.Output = output.String()
+		if err != nil {
+			result.Data["rolled_back"] = restoreFile(path, previous, existed) == nil
+			if undoKey != nil {
+				result.Data["key_rolled_back"] = undoKey(ctx) == nil
+			}
+			return failResult(result, "repository refresh failed", err)
+		}
+		result.Data["refreshed"] = true
+	}
+
+	result.Output = output.String()
+	result.Data["repository"] = repo
+	result.Success = true
+	return result
+}
+
+// removeRepo deletes a repository definition and refreshes metadata
+func (e *Executor) removeRepo(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	store, err := e.repos()
+	if err != nil {
+		return failResult(result, "", err)
+	}
+
+	name, ok := action.Params["name"].(string)
+	if !ok {
+		result.Success = false
+		result.Error = "missing 'name' parameter"
+		return result
+	}
+	if !validRepoName.MatchString(name) {
+		result.Success = false
+		result.Error = fmt.Sprintf("invalid repository name: %s", name)
+		return result
+	}
+
+	logger.Info().
+		Str("repository", name).
+		Str("manager", e.manager.name()).
+		Msg("Removing package repository")
+
+	if err := store.remove(name); err != nil {
+		return failResult(result, "failed to remove repository", err)
+	}
+
+	if action.BoolParam("refresh", true) {
+		output, err := store.refresh(ctx)
+		result.Output = output
+		if err != nil {
+			return failResult(result, "repository refresh failed", err)
+		}
+		result.Data["refreshed"] = true
+	}
+
+	result.Data["name"] = name
+	result.Success = true
+	return result
+}
+
+// importKey installs a repository signing key under the given name
+func (e *Executor) importKey(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	store, err := e.repos()
+	if err != nil {
+		return failResult(result, "", err)
+	}
+
+	name, err := keyNameParam(action)
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+	data, ok := action.Params["key_data"].(string)
+	if !ok {
+		result.Success = false
+		result.Error = "missing 'key_data' parameter"
+		return result
+	}
+
+	key, _, output, err := store.importKey(ctx, name, data)
+	result.Output = output
+	if err != nil {
+		return failResult(result, "failed to import key", err)
+	}
+
+	logger.Info().
+		Str("key", name).
+		Str("fingerprint", key.Fingerprint).
+		Msg("Imported repository signing key")
+
+	result.Data["key"] = key
+	result.Success = true
+	return result
+}
+
+// removeKey deletes a repository signing key
+func (e *Executor) removeKey(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	store, err := e.repos()
+	if err != nil {
+		return failResult(result, "", err)
+	}
+
+	name, err := keyNameParam(action)
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+
+	output, err := store.removeKey(ctx, name)
+	result.Output = output
+	if err != nil {
+		return failResult(result, "failed to remove key", err)
+	}
+
+	logger.Info().Str("key", name).Msg("Removed repository signing key")
+
+	result.Data["name"] = name
+	result.Success = true
+	return result
+}
+
+// repoParams reads and validates the repository definition of package_repo_add
+func repoParams(action *executor.Action) (Repository, error) {
+	repo := Repository{
+		Enabled:     action.BoolParam("enabled", true),
+		GPGCheck:    action.BoolParam("gpg_check", true),
+		Suites:      action.StringsParam("suites"),
+		Components:  action.StringsParam("components"),
+		Arch:        action.StringsParam("arch"),
+		Key:         action.StringParam("key", ""),
+		Description: action.StringParam("description", ""),
+	}
+
+	var ok bool
+	if repo.Name, ok = action.Params["name"].(string); !ok {
+		return repo, fmt.Errorf("missing 'name' parameter")
+	}
+	if !validRepoName.MatchString(repo.Name) {
+		return repo, fmt.Errorf("invalid repository name: %s", repo.Name)
+	}
+	if repo.URL, ok = action.Params["url"].(string); !ok {
+		return repo, fmt.Errorf("missing 'url' parameter")
+	}
+	if err := validateRepoURL(repo.URL); err != nil {
+		return repo, err
+	}
+	if repo.Key != "" && !validKeyName.MatchString(repo.Key) {
+		return repo, fmt.Errorf("invalid key name: %s", repo.Key)
+	}
+	if strings.ContainsAny(repo.Description, "\r\n") {
+		return repo, fmt.Errorf("'description' must be a single line")
+	}
+	for _, tokens := range [][]string{repo.Suites, repo.Components, repo.Arch} {
+		for _, t := range tokens {
+			if !validRepoToken.MatchString(t) {
+				return repo, fmt.Errorf("invalid repository field: %s", t)
+			}
+		}
+	}
+	return repo, nil
+}
+
+// keyNameParam reads and validates the 'name' of a signing key
+func keyNameParam(action *executor.Action) (string, error) {
+	name, ok := action.Params["name"].(string)
+	if !ok {
+		return "", fmt.Errorf("missing 'name' parameter")
+	}
+	if !validKeyName.MatchString(name) {
+		return "", fmt.Errorf("invalid key name: %s", name)
+	}
+	return name, nil
+}
+
+// validateRepoURL accepts absolute http, https, ftp and file URLs. Package
+// manager variables such as $releasever are allowed, quoting and whitespace
+// that could break out of a config line are not
+func validateRepoURL(raw string) error {
+	if strings.ContainsAny(raw, " \t\r\n\"'[]#") {
+		return fmt.Errorf("invalid repository URL: %q", raw)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid repository URL: %v", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "ftp":
+		if u.Host == "" {
+			return fmt.Errorf("invalid repository URL: missing host")
+		}
+	case "file":
+		if u.Path == "" {
+			return fmt.Errorf("invalid repository URL: missing path")
+		}
+	default:
+		return fmt.Errorf("unsupported repository URL scheme: %q", u.Scheme)
+	}
+	return nil
+}
+
+// restoreFile puts back a file's previous content, or deletes it when it did
+// not exist before
+func restoreFile(path string, previous []byte, existed bool) error {
+	if !existed {
+		return os.Remove(path)
+	}
+	return writeConfig(path, previous)
+}
+
+// readExisting returns a file's content, or nil when it does not exist
+func readExisting(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if data == nil && err == nil {
+		// An empty file still exists
+		data = []byte{}
+	}
+	return data, err
+}
+
+// writeConfig atomically writes a configuration file, creating its directory
+func writeConfig(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(path, data, 0)
+}
@@ -0,0 +1,209 @@
+package package_executor
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	apkRepositoriesPath = "/etc/apk/repositories"
+	apkKeysDir          = "/etc/apk/keys"
+	// apkRepoMarker names the repository on the line below it, since
+	// /etc/apk/repositories has no names of its own
+	apkRepoMarker = "# einfra-repo: "
+)
+
+// apkRepoStore manages /etc/apk/repositories and the RSA keys in /etc/apk/keys
+type apkRepoStore struct{}
+
+// repos returns the apk repository store
+func (m *apkManager) repos() repoStore {
+	return &apkRepoStore{}
+}
+
+// path is the single file every apk repository lives in
+func (s *apkRepoStore) path(name string) string {
+	return apkRepositoriesPath
+}
+
+// list reads /etc/apk/repositories; a commented-out URL is a disabled
+// repository. Unnamed entries are named after their tag or last path element
+func (s *apkRepoStore) list() ([]Repository, error) {
+	lines, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+
+	repos := make([]Repository, 0)
+	var marked string
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if name, ok := strings.CutPrefix(line, apkRepoMarker); ok {
+			marked = strings.TrimSpace(name)
+			continue
+		}
+
+		repo := Repository{Type: "apk", Enabled: true, GPGCheck: true, File: apkRepositoriesPath}
+		if rest, ok := strings.CutPrefix(line, "#"); ok {
+			line, repo.Enabled = strings.TrimSpace(rest), false
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 || !strings.Contains(fields[len(fields)-1], "/") {
+			marked = ""
+			continue
+		}
+
+		// "@testing https://..." tags a repository for pinning
+		repo.URL = fields[len(fields)-1]
+		repo.Name = marked
+		if repo.Name == "" && len(fields) > 1 {
+			repo.Name = strings.TrimPrefix(fields[0], "@")
+		}
+		if repo.Name == "" {
+			repo.Name = path.Base(repo.URL)
+		}
+		marked = ""
+		repos = append(repos, repo)
+	}
+	return repos, nil
+}
+
+// add appends a named entry, or replaces the entry added earlier under the same name
+func (s *apkRepoStore) add(repo Repository) (Repository, error) {
+	if len(repo.Suites) > 0 || len(repo.Components) > 0 || len(repo.Arch) > 0 {
+		return repo, unsupported("apk", "repository suites, components and architectures")
+	}
+	// apk checks every index against all keys in /etc/apk/keys
+	if !repo.GPGCheck {
+		return repo, unsupported("apk", "disabling signature checks per repository")
+	}
+	if repo.Key != "" {
+		keyPath := apkKeyPath(repo.Key)
+		if _, err := os.Stat(keyPath); err != nil {
+			return repo, fmt.Errorf("key %s not found in %s; import it first", repo.Key, apkKeysDir)
+		}
+		repo.Key = keyPath
+	}
+
+	lines, err := s.read()
+	if err != nil {
+		return repo, err
+	}
+	lines, _ = removeAPKEntry(lines, repo.Name)
+	for _, line := range lines {
+		if strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "#")) == repo.URL {
+			return repo, fmt.Errorf("%s is already listed in %s", repo.URL, apkRepositoriesPath)
+		}
+	}
+
+	entry := repo.URL
+	if !repo.Enabled {
+		entry = "#" + entry
+	}
+	lines = append(lines, apkRepoMarker+repo.Name, entry)
+
+	repo.Type = "apk"
+	repo.File = apkRepositoriesPath
+	return repo, writeConfig(apkRepositoriesPath, []byte(strings.Join(lines, "\n")+"\n"))
+}
+
+// remove deletes an entry the agent added
+func (s *apkRepoStore) remove(name string) error {
+	lines, err := s.read()
+	if err != nil {
+		return err
+	}
+	lines, found := removeAPKEntry(lines, name)
+	if !found {
+		return fmt.Errorf("repository %s not found in %s", name, apkRepositoriesPath)
+	}
+	return writeConfig(apkRepositoriesPath, []byte(strings.Join(lines, "\n")+"\n"))
+}
+
+// importKey stores an RSA public key in PEM form. The name must match the
+// key name the repository index is signed with
+func (s *apkRepoStore) importKey(ctx context.Context, name, data string) (*KeyInfo, func(ctx context.Context) error, string, error) {
+	block, _ := pem.Decode([]byte(strings.TrimSpace(data)))
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, nil, "", fmt.Errorf("key is not a PEM encoded public key")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("invalid public key: %v", err)
+	}
+	if _, ok := pub.(*rsa.PublicKey); !ok {
+		return nil, nil, "", fmt.Errorf("apk signing keys must be RSA keys")
+	}
+
+	keyPath := apkKeyPath(name)
+	previous, err := readExisting(keyPath)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if err := writeConfig(keyPath, pem.EncodeToMemory(block)); err != nil {
+		return nil, nil, "", err
+	}
+
+	undo := func(ctx context.Context) error { return restoreFile(keyPath, previous, previous != nil) }
+	sum := sha256.Sum256(block.Bytes)
+	return &KeyInfo{Name: name, File: keyPath, Fingerprint: strings.ToUpper(hex.EncodeToString(sum[:]))}, undo, "", nil
+}
+
+// removeKey deletes a key from /etc/apk/keys
+func (s *apkRepoStore) removeKey(ctx context.Context, name string) (string, error) {
+	err := os.Remove(apkKeyPath(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("key %s not found in %s", name, apkKeysDir)
+	}
+	return "", err
+}
+
+// refresh runs apk update, which fails on unreachable or untrusted indexes
+func (s *apkRepoStore) refresh(ctx context.Context) (string, error) {
+	return runCommand(ctx, "apk", "update")
+}
+
+// read returns the lines of /etc/apk/repositories, without the final newline
+func (s *apkRepoStore) read() ([]string, error) {
+	data, err := os.ReadFile(apkRepositoriesPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimRight(string(data), "\n"), "\n"), nil
+}
+
+// removeAPKEntry drops a marker line and the entry below it
+func removeAPKEntry(lines []string, name string) ([]string, bool) {
+	kept := make([]string, 0, len(lines))
+	found := false
+	for i := 0; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == apkRepoMarker+name {
+			found = true
+			i++
+			continue
+		}
+		kept = append(kept, lines[i])
+	}
+	return kept, found
+}
+
+// apkKeyPath maps a key name to its file; apk expects the .rsa.pub suffix
+func apkKeyPath(name string) string {
+	if !strings.HasSuffix(name, ".rsa.pub") {
+		name += ".rsa.pub"
+	}
+	return filepath.Join(apkKeysDir, name)
+}
@@ -0,0 +1,302 @@
+package package_executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	aptSourcesList = "/etc/apt/sources.list"
+	aptSourcesDir  = "/etc/apt/sources.list.d"
+	aptKeyringDir  = "/etc/apt/keyrings"
+)
+
+// aptRepoStore manages sources.list.d entries and the keyrings they are signed-by
+type aptRepoStore struct{}
+
+// repos returns the apt repository store
+func (m *aptManager) repos() repoStore {
+	return &aptRepoStore{}
+}
+
+// path is the one-line-style list file the agent writes for a repository
+func (s *aptRepoStore) path(name string) string {
+	return filepath.Join(aptSourcesDir, name+".list")
+}
+
+// list reads sources.list, one-line .list files and deb822 .sources files
+func (s *aptRepoStore) list() ([]Repository, error) {
+	repos := make([]Repository, 0)
+
+	lists, _ := filepath.Glob(filepath.Join(aptSourcesDir, "*.list"))
+	for _, path := range append([]string{aptSourcesList}, lists...) {
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		repos = append(repos, parseAptList(path, string(data))...)
+	}
+
+	sources, _ := filepath.Glob(filepath.Join(aptSourcesDir, "*.sources"))
+	for _, path := range sources {
+		err := readStanzas(path, func(stanza map[string]string) {
+			repos = append(repos, aptSourcesEntries(path, stanza)...)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return repos, nil
+}
+
+// add writes a one-line-style list file, one line per suite
+func (s *aptRepoStore) add(repo Repository) (Repository, error) {
+	if _, err := os.Stat(filepath.Join(aptSourcesDir, repo.Name+".sources")); err == nil {
+		return repo, fmt.Errorf("repository %s is already defined in %s.sources", repo.Name, repo.Name)
+	}
+
+	if len(repo.Suites) == 0 {
+		codename := readOSRelease()["VERSION_CODENAME"]
+		if codename == "" {
+			return repo, fmt.Errorf("missing 'suites' parameter")
+		}
+		repo.Suites = []string{codename}
+	}
+	flat := false
+	for _, suite := range repo.Suites {
+		flat = flat || strings.HasSuffix(suite, "/")
+	}
+	if flat && len(repo.Components) > 0 {
+		return repo, fmt.Errorf("flat repository suites take no components")
+	}
+	if !flat && len(repo.Components) == 0 {
+		repo.Components = []string{"main"}
+	}
+
+	var options []string
+	if len(repo.Arch) > 0 {
+		options = append(options, "arch="+strings.Join(repo.Arch, ","))
+	}
+	if repo.GPGCheck && repo.Key != "" {
+		keyring := s.keyring(repo.Key)
+		if keyring == "" {
+			return repo, fmt.Errorf("key %s not found in %s; import it first", repo.Key, aptKeyringDir)
+		}
+		repo.Key = keyring
+		options = append(options, "signed-by="+keyring)
+	}
+	if !repo.GPGCheck {
+		repo.Key = ""
+		options = append(options, "trusted=yes")
+	}
+
+	var b strings.Builder
+	if repo.Description != "" {
+		b.WriteString("# " + repo.Description + "\n")
+	}
+	for _, suite := range repo.Suites {
+		if !repo.Enabled {
+			b.WriteString("# ")
+		}
+		b.WriteString("deb ")
+		if len(options) > 0 {
+			b.WriteString("[" + strings.Join(options, " ") + "] ")
+		}
+		b.WriteString(strings.Join(append([]string{repo.URL, suite}, repo.Components...), " ") + "\n")
+	}
+
+	repo.Type = "deb"
+	repo.File = s.path(repo.Name)
+	return repo, writeConfig(repo.File, []byte(b.String()))
+}
+
+// remove deletes the repository's .list or .sources file
+func (s *aptRepoStore) remove(name string) error {
+	removed := false
+	for _, path := range []string{s.path(name), filepath.Join(aptSourcesDir, name+".sources")} {
+		err := os.Remove(path)
+		if err == nil {
+			removed = true
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	if !removed {
+		return fmt.Errorf("repository %s not found in %s", name, aptSourcesDir)
+	}
+	return nil
+}
+
+// importKey stores an armored keyring for signed-by; apt reads .asc files
+// directly, so gpg is not needed on the node. Undoing restores the keyrings
+// that were replaced
+func (s *aptRepoStore) importKey(ctx context.Context, name, data string) (*KeyInfo, func(ctx context.Context) error, string, error) {
+	key, err := parsePGPKey(data)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	path := filepath.Join(aptKeyringDir, name+".asc")
+	binary := filepath.Join(aptKeyringDir, name+".gpg")
+	previous, readErr := readExisting(path)
+	if readErr != nil {
+		return nil, nil, "", readErr
+	}
+	previousBinary, readErr := readExisting(binary)
+	if readErr != nil {
+		return nil, nil, "", readErr
+	}
+
+	if err := writeConfig(path, []byte(key.Armored())); err != nil {
+		return nil, nil, "", err
+	}
+	// A binary keyring of the same name would shadow the new key
+	os.Remove(binary)
+
+	undo := func(ctx context.Context) error {
+		if previousBinary != nil {
+			if err := writeConfig(binary, previousBinary); err != nil {
+				return err
+			}
+		}
+		return restoreFile(path, previous, previous != nil)
+	}
+	return &KeyInfo{Name: name, File: path, Fingerprint: key.Fingerprint, KeyID: key.KeyID}, undo, "", nil
+}
+
+// removeKey deletes a keyring no repository is signed-by any more
+func (s *aptRepoStore) removeKey(ctx context.Context, name string) (string, error) {
+	keyring := s.keyring(name)
+	if keyring == "" {
+		return "", fmt.Errorf("key %s not found in %s", name, aptKeyringDir)
+	}
+
+	repos, err := s.list()
+	if err != nil {
+		return "", err
+	}
+	for _, repo := range repos {
+		if repo.Key == keyring {
+			return "", fmt.Errorf("key %s is still used by repository %s (%s)", name, repo.Name, repo.File)
+		}
+	}
+	return "", os.Remove(keyring)
+}
+
+// refresh runs apt-get update. apt only warns about repositories it cannot
+// fetch, so failed downloads are turned into errors here
+func (s *aptRepoStore) refresh(ctx context.Context) (string, error) {
+	output, err := runCommand(ctx, "apt-get", "update", "-o", "APT::Update::Error-Mode=any")
+	if err == nil && strings.Contains(output, "Failed to fetch") {
+		err = fmt.Errorf("failed to fetch repository metadata: %s", lastLines(output, 5))
+	}
+	return output, err
+}
+
+// keyring finds an imported key by name, armored or binary
+func (s *aptRepoStore) keyring(name string) string {
+	for _, ext := range []string{".asc", ".gpg"} {
+		path := filepath.Join(aptKeyringDir, name+ext)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// parseAptList parses one-line-style entries; commented-out entries are
+// reported as disabled and a comment directly above an entry describes it
+func parseAptList(path, data string) []Repository {
+	name := strings.TrimSuffix(filepath.Base(path), ".list")
+	repos := make([]Repository, 0)
+
+	var comment string
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		enabled := true
+		if rest, ok := strings.CutPrefix(line, "#"); ok {
+			rest = strings.TrimSpace(rest)
+			if !strings.HasPrefix(rest, "deb ") && !strings.HasPrefix(rest, "deb-src ") {
+				comment = rest
+				continue
+			}
+			line, enabled = rest, false
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 3 || (fields[0] != "deb" && fields[0] != "deb-src") {
+			comment = ""
+			continue
+		}
+
+		repo := Repository{Name: name, Type: fields[0], Enabled: enabled, GPGCheck: true, Description: comment, File: path}
+		comment = ""
+
+		rest := fields[1:]
+		if strings.HasPrefix(rest[0], "[") {
+			// Options run to the closing bracket: [arch=amd64 signed-by=/path]
+			for len(rest) > 0 {
+				field := rest[0]
+				rest = rest[1:]
+				applyAptOption(&repo, strings.Trim(field, "[]"))
+				if strings.HasSuffix(field, "]") {
+					break
+				}
+			}
+		}
+		if len(rest) < 2 {
+			continue
+		}
+		repo.URL = rest[0]
+		repo.Suites = rest[1:2]
+		repo.Components = rest[2:]
+		repos = append(repos, repo)
+	}
+	return repos
+}
+
+// applyAptOption applies one key=value option of a one-line-style entry
+func applyAptOption(repo *Repository, option string) {
+	k, v, _ := strings.Cut(option, "=")
+	switch k {
+	case "arch":
+		repo.Arch = strings.Split(v, ",")
+	case "signed-by":
+		repo.Key = v
+	case "trusted":
+		repo.GPGCheck = v != "yes"
+	}
+}
+
+// aptSourcesEntries expands a deb822 stanza into one entry per type and URI
+func aptSourcesEntries(path string, stanza map[string]string) []Repository {
+	repos := make([]Repository, 0)
+	for _, typ := range strings.Fields(stanza["Types"]) {
+		for _, uri := range strings.Fields(stanza["URIs"]) {
+			repo := Repository{
+				Name:       strings.TrimSuffix(filepath.Base(path), ".sources"),
+				Type:       typ,
+				URL:        uri,
+				Suites:     strings.Fields(stanza["Suites"]),
+				Components: strings.Fields(stanza["Components"]),
+				Arch:       strings.Fields(stanza["Architectures"]),
+				Enabled:    stanza["Enabled"] != "no",
+				GPGCheck:   stanza["Trusted"] != "yes",
+				File:       path,
+			}
+			// Signed-By may also hold an inline key block, which spans several lines
+			if key := stanza["Signed-By"]; strings.HasPrefix(key, "/") {
+				repo.Key = key
+			}
+			repos = append(repos, repo)
+		}
+	}
+	return repos
+}
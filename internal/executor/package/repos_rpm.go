@@ -0,0 +1,313 @@
+package package_executor
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	yumReposDir  = "/etc/yum.repos.d"
+	zyppReposDir = "/etc/zypp/repos.d"
+	rpmKeyDir    = "/etc/pki/rpm-gpg"
+)
+
+// rpmRepoStore manages the INI .repo files yum, dnf and zypper share, and
+// the signing keys imported into the rpm database
+type rpmRepoStore struct {
+	dir     string
+	manager string
+	// zypper wants the repository type and autorefresh spelled out
+	zypper      bool
+	refreshArgs []string
+}
+
+// repos returns the yum/dnf repository store
+func (m *dnfManager) repos() repoStore {
+	return &rpmRepoStore{dir: yumReposDir, manager: m.binary, refreshArgs: []string{"-y", "makecache"}}
+}
+
+// repos returns the zypper repository store
+func (m *zypperManager) repos() repoStore {
+	return &rpmRepoStore{dir: zyppReposDir, manager: "zypper", zypper: true, refreshArgs: []string{"--non-interactive", "refresh"}}
+}
+
+// iniSection is one [id] block of a .repo file
+type iniSection struct {
+	name   string
+	values map[string]string
+}
+
+// path is the .repo file the agent writes for a repository
+func (s *rpmRepoStore) path(name string) string {
+	return filepath.Join(s.dir, name+".repo")
+}
+
+// list reads every section of every .repo file
+func (s *rpmRepoStore) list() ([]Repository, error) {
+	files, _ := filepath.Glob(filepath.Join(s.dir, "*.repo"))
+	repos := make([]Repository, 0)
+	for _, path := range files {
+		sections, err := readINI(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, section := range sections {
+			v := section.values
+			repo := Repository{
+				Name:        section.name,
+				Type:        v["type"],
+				Enabled:     v["enabled"] == "" || iniBool(v["enabled"]),
+				GPGCheck:    iniBool(v["gpgcheck"]),
+				Key:         strings.TrimPrefix(firstField(v["gpgkey"]), "file://"),
+				Description: v["name"],
+				File:        path,
+			}
+			if repo.Type == "" {
+				repo.Type = "rpm-md"
+			}
+			for _, k := range []string{"baseurl", "mirrorlist", "metalink"} {
+				if repo.URL = firstField(v[k]); repo.URL != "" {
+					break
+				}
+			}
+			repos = append(repos, repo)
+		}
+	}
+	return repos, nil
+}
+
+// add writes a .repo file holding a single section
+func (s *rpmRepoStore) add(repo Repository) (Repository, error) {
+	if len(repo.Suites) > 0 || len(repo.Components) > 0 || len(repo.Arch) > 0 {
+		return repo, unsupported(s.manager, "repository suites, components and architectures")
+	}
+
+	repos, err := s.list()
+	if err != nil {
+		return repo, err
+	}
+	for _, r := range repos {
+		if r.Name == repo.Name && r.File != s.path(repo.Name) {
+			return repo, fmt.Errorf("repository %s is already defined in %s", repo.Name, r.File)
+		}
+	}
+
+	description := repo.Description
+	if description == "" {
+		description = repo.Name
+	}
+	lines := []string{
+		"[" + repo.Name + "]",
+		"name=" + description,
+		"baseurl=" + repo.URL,
+		"enabled=" + iniFlag(repo.Enabled),
+		"gpgcheck=" + iniFlag(repo.GPGCheck),
+	}
+	if repo.Key != "" {
+		path := filepath.Join(rpmKeyDir, "RPM-GPG-KEY-"+repo.Key)
+		if _, err := os.Stat(path); err != nil {
+			return repo, fmt.Errorf("key %s not found in %s; import it first", repo.Key, rpmKeyDir)
+		}
+		repo.Key = path
+		lines = append(lines, "gpgkey=file://"+path)
+	}
+	if s.zypper {
+		lines = append(lines, "autorefresh=1", "type=rpm-md")
+	}
+
+	repo.Type = "rpm-md"
+	repo.File = s.path(repo.Name)
+	return repo, writeConfig(repo.File, []byte(strings.Join(lines, "\n")+"\n"))
+}
+
+// remove deletes the repository's file, or just its section when the file
+// defines other repositories too
+func (s *rpmRepoStore) remove(name string) error {
+	repos, err := s.list()
+	if err != nil {
+		return err
+	}
+
+	var file string
+	others := 0
+	for _, r := range repos {
+		if r.Name == name {
+			file = r.File
+		}
+	}
+	if file == "" {
+		return fmt.Errorf("repository %s not found in %s", name, s.dir)
+	}
+	for _, r := range repos {
+		if r.File == file && r.Name != name {
+			others++
+		}
+	}
+	if others == 0 {
+		return os.Remove(file)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	return writeConfig(file, []byte(removeINISection(string(data), name)))
+}
+
+// importKey stores an armored key under /etc/pki/rpm-gpg and imports it
+// into the rpm database. Undoing erases the key from the database again
+// unless it was trusted before
+func (s *rpmRepoStore) importKey(ctx context.Context, name, data string) (*KeyInfo, func(ctx context.Context) error, string, error) {
+	key, err := parsePGPKey(data)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	path := filepath.Join(rpmKeyDir, "RPM-GPG-KEY-"+name)
+	previous, err := readExisting(path)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	// rpm names imported keys gpg-pubkey-<short key ID>
+	pubkey := "gpg-pubkey-" + key.KeyID[len(key.KeyID)-8:]
+	_, queryErr := queryCommand(ctx, "rpm", "-q", pubkey)
+	trusted := queryErr == nil
+
+	if err := writeConfig(path, []byte(key.Armored())); err != nil {
+		return nil, nil, "", err
+	}
+	output, err := runCommand(ctx, "rpm", "--import", path)
+	if err != nil {
+		restoreFile(path, previous, previous != nil)
+		return nil, nil, output, err
+	}
+
+	undo := func(ctx context.Context) error {
+		if !trusted {
+			if _, err := runCommand(ctx, "rpm", "-e", "--allmatches", pubkey); err != nil {
+				return err
+			}
+		}
+		return restoreFile(path, previous, previous != nil)
+	}
+	return &KeyInfo{Name: name, File: path, Fingerprint: key.Fingerprint, KeyID: key.KeyID}, undo, output, nil
+}
+
+// removeKey erases the key from the rpm database and deletes its file
+func (s *rpmRepoStore) removeKey(ctx context.Context, name string) (string, error) {
+	path := filepath.Join(rpmKeyDir, "RPM-GPG-KEY-"+name)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("key %s not found in %s", name, rpmKeyDir)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	repos, err := s.list()
+	if err != nil {
+		return "", err
+	}
+	for _, repo := range repos {
+		if repo.Key == path {
+			return "", fmt.Errorf("key %s is still used by repository %s (%s)", name, repo.Name, repo.File)
+		}
+	}
+
+	var output string
+	if key, err := parsePGPKey(string(data)); err == nil {
+		// rpm names imported keys gpg-pubkey-<short key ID>
+		output, err = runCommand(ctx, "rpm", "-e", "--allmatches", "gpg-pubkey-"+key.KeyID[len(key.KeyID)-8:])
+		if err != nil && !strings.Contains(output, "is not installed") {
+			return output, err
+		}
+	}
+	return output, os.Remove(path)
+}
+
+// refresh rebuilds the metadata cache, which fails on unreachable repositories
+func (s *rpmRepoStore) refresh(ctx context.Context) (string, error) {
+	return runCommand(ctx, s.manager, s.refreshArgs...)
+}
+
+// readINI parses a .repo file; indented lines continue the previous value
+func readINI(path string) ([]iniSection, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var sections []iniSection
+	var current *iniSection
+	var lastKey string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		raw := scanner.Text()
+		line := strings.TrimSpace(raw)
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+			continue
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			sections = append(sections, iniSection{name: strings.Trim(line, "[]"), values: make(map[string]string)})
+			current = &sections[len(sections)-1]
+			lastKey = ""
+		case current == nil:
+			continue
+		case (raw[0] == ' ' || raw[0] == '\t') && lastKey != "":
+			current.values[lastKey] += " " + line
+		default:
+			if k, v, ok := strings.Cut(line, "="); ok {
+				lastKey = strings.TrimSpace(k)
+				current.values[lastKey] = strings.TrimSpace(v)
+			}
+		}
+	}
+	return sections, scanner.Err()
+}
+
+// removeINISection drops one section, up to the next section header
+func removeINISection(data, name string) string {
+	var kept []string
+	skipping := false
+	for _, line := range strings.Split(data, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			skipping = strings.Trim(trimmed, "[]") == name
+		}
+		if !skipping {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// iniBool reads the boolean spellings yum and zypper accept
+func iniBool(v string) bool {
+	switch strings.ToLower(v) {
+	case "1", "yes", "true", "on":
+		return true
+	}
+	return false
+}
+
+// iniFlag writes a boolean as 1 or 0
+func iniFlag(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+// firstField returns the first whitespace-separated value of a list option
+func firstField(v string) string {
+	if fields := strings.Fields(v); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
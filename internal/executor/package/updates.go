@@ -0,0 +1,90 @@
+package package_executor
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Update is one package with a newer version available
+type Update struct {
+	Name      string `json:"name"`
+	Arch      string `json:"arch,omitempty"`
+	Current   string `json:"current,omitempty"`
+	Available string `json:"available"`
+	Repo      string `json:"repo,omitempty"`
+	Security  bool   `json:"security"`
+}
+
+// UpdateStatus summarizes pending updates and whether the node needs a reboot
+type UpdateStatus struct {
+	Manager string   `json:"manager"`
+	Count   int      `json:"count"`
+	Updates []Update `json:"updates"`
+	// SecurityCount is nil when the package manager cannot tell security updates apart
+	SecurityCount *int         `json:"security_count"`
+	Reboot        RebootStatus `json:"reboot"`
+	CheckedAt     time.Time    `json:"checked_at"`
+}
+
+// CheckUpdates lists pending updates, refreshing repository metadata first
+// when refresh is set, and checks whether a reboot is pending. It waits for
+// running package operations like an action would
+func (e *Executor) CheckUpdates(ctx context.Context, refresh bool) (*UpdateStatus, error) {
+	if e.manager == nil {
+		return nil, fmt.Errorf("no supported package manager found")
+	}
+
+	release, err := e.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if refresh {
+		if _, err := e.waitForLocks(ctx, e.lockTimeout); err != nil {
+			return nil, fmt.Errorf("package manager is busy: %w", err)
+		}
+	}
+	return e.updateStatus(ctx, refresh)
+}
+
+// updateStatus builds the update status; the caller holds the operation slot
+func (e *Executor) updateStatus(ctx context.Context, refresh bool) (*UpdateStatus, error) {
+	updates, security, err := e.manager.checkUpdates(ctx, refresh)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &UpdateStatus{
+		Manager:   e.manager.name(),
+		Count:     len(updates),
+		Updates:   updates,
+		Reboot:    checkReboot(ctx),
+		CheckedAt: time.Now().UTC(),
+	}
+	if security >= 0 {
+		status.SecurityCount = &security
+	}
+	return status, nil
+}
+
+// securityCount counts updates flagged as security fixes
+func securityCount(updates []Update) int {
+	n := 0
+	for _, u := range updates {
+		if u.Security {
+			n++
+		}
+	}
+	return n
+}
+
+// installedVersions maps name and name+arch to the installed version
+func installedVersions(packages []Package) map[string]string {
+	versions := make(map[string]string, len(packages)*2)
+	for _, p := range packages {
+		versions[p.Name] = p.Version
+		versions[packageKey(p)] = p.Version
+	}
+	return versions
+}
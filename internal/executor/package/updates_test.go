@@ -0,0 +1,98 @@
+package package_executor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDNFCheckUpdate(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []Package
+	}{
+		{name: "empty", output: "", want: []Package{}},
+		{
+			name: "dnf",
+			output: `
+kernel.x86_64                   4.18.0-513.el8          baseos
+openssl-libs.x86_64             1:1.1.1k-12.el8_9       baseos
+`,
+			want: []Package{
+				{Name: "kernel", Arch: "x86_64", Version: "4.18.0-513.el8", Repo: "baseos"},
+				{Name: "openssl-libs", Arch: "x86_64", Version: "1:1.1.1k-12.el8_9", Repo: "baseos"},
+			},
+		},
+		{
+			name: "yum wraps long names",
+			output: `python-backports-ssl_match_hostname.noarch
+                                 3.5.0.1-1.el7          base
+bash.x86_64                     4.2.46-35.el7_9         updates
+`,
+			want: []Package{
+				{Name: "python-backports-ssl_match_hostname", Arch: "noarch", Version: "3.5.0.1-1.el7", Repo: "base"},
+				{Name: "bash", Arch: "x86_64", Version: "4.2.46-35.el7_9", Repo: "updates"},
+			},
+		},
+		{
+			name: "security notices and obsoletes",
+			output: `Security: kernel-core-4.18.0-513.el8.x86_64 is an installed security update
+curl.x86_64                     7.61.1-33.el8           baseos
+Obsoleting Packages
+grub2-tools.x86_64              1:2.02-150.el8          baseos
+    grub2-tools.x86_64          1:2.02-148.el8          @baseos
+`,
+			want: []Package{{Name: "curl", Arch: "x86_64", Version: "7.61.1-33.el8", Repo: "baseos"}},
+		},
+	}
+	for _, tt := range tests {
+		if got := parseDNFCheckUpdate(tt.output); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseDNFSecurity(t *testing.T) {
+	output := `RHSA-2023:7549 Important/Sec. kernel-4.18.0-513.9.1.el8_9.x86_64
+RHSA-2023:7877 Moderate/Sec.  openssl-libs-1:1.1.1k-12.el8_9.x86_64
+FEDORA-2024-1 security        python3-urllib3-1.26.18-1.fc39.noarch
+short line
+`
+	want := map[string]bool{"kernel": true, "openssl-libs": true, "python3-urllib3": true}
+	if got := parseDNFSecurity(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestParseZypperUpdates(t *testing.T) {
+	output := `S | Repository           | Name          | Current Version | Available Version | Arch
+--+----------------------+---------------+-----------------+-------------------+-------
+v | Main Update Repository | libopenssl3 | 3.0.8-1.1       | 3.0.8-1.2         | x86_64
+v | Main Update Repository | vim         | 9.0.1-1.1       | 9.0.2-1.1         | x86_64
+`
+	want := []Update{
+		{Repo: "Main Update Repository", Name: "libopenssl3", Current: "3.0.8-1.1", Available: "3.0.8-1.2", Arch: "x86_64"},
+		{Repo: "Main Update Repository", Name: "vim", Current: "9.0.1-1.1", Available: "9.0.2-1.1", Arch: "x86_64"},
+	}
+	if got := parseZypperUpdates(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if got := parseZypperUpdates("No updates found.\n"); len(got) != 0 {
+		t.Errorf("no updates: got %+v", got)
+	}
+}
+
+func TestParseAPKVersion(t *testing.T) {
+	output := `Installed:                                Available:
+musl-1.2.3-r0                           < 1.2.4-r0
+py3-cryptography-41.0.3-r0              < 41.0.7-r0
+busybox-1.36.1-r2                       = 1.36.1-r2
+`
+	want := []Update{
+		{Name: "musl", Current: "1.2.3-r0", Available: "1.2.4-r0"},
+		{Name: "py3-cryptography", Current: "41.0.3-r0", Available: "41.0.7-r0"},
+	}
+	if got := parseAPKVersion(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
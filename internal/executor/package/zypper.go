@@ -0,0 +1,209 @@
+package package_executor
+
+import (
+	"context"
+	"strings"
+)
+
+// zypperManager drives zypper for SUSE and openSUSE
+type zypperManager struct{}
+
+// name is the binary detection looks for
+func (m *zypperManager) name() string {
+	return "zypper"
+}
+
+// lockFiles are the rpm database lock and libzypp's PID file
+func (m *zypperManager) lockFiles() []lockFile {
+	return []lockFile{
+		{path: rpmLockPath, kind: lockHeld},
+		{path: "/run/zypp.pid", kind: lockPIDFile},
+	}
+}
+
+// listInstalled parses the `zypper search --installed-only --details` table
+func (m *zypperManager) listInstalled(ctx context.Context) ([]Package, error) {
+	output, err := queryCommand(ctx, "zypper", "--non-interactive", "--quiet",
+		"search", "--installed-only", "--details", "--type", "package")
+	if err != nil {
+		return nil, err
+	}
+
+	packages := make([]Package, 0)
+	for _, cols := range zypperTable(output) {
+		// S | Name | Type | Version | Arch | Repository
+		if len(cols) < 6 {
+			continue
+		}
+		packages = append(packages, Package{
+			Name:    cols[1],
+			Version: cols[3],
+			Arch:    cols[4],
+			Repo:    cols[5],
+		})
+	}
+	return packages, nil
+}
+
+// install installs packages without prompting; --oldpackage lets an exact
+// version replace a newer one
+func (m *zypperManager) install(ctx context.Context, packages []string, version string, downgrade bool) (string, error) {
+	args := []string{"--non-interactive", "install", "--auto-agree-with-licenses"}
+	if downgrade {
+		args = append(args, "--oldpackage")
+	}
+	if version != "" {
+		packages = []string{packages[0] + "=" + version}
+	}
+	return runCommand(ctx, "zypper", append(args, packages...)...)
+}
+
+// update updates the given packages or everything; security-only applies
+// security patches, which zypper tracks separately from packages
+func (m *zypperManager) update(ctx context.Context, packages []string, securityOnly bool) (string, error) {
+	if securityOnly {
+		if len(packages) > 0 {
+			return "", unsupported(m.name(), "security-only updates of individual packages")
+		}
+		return runCommand(ctx, "zypper", "--non-interactive", "patch", "--category", "security", "--auto-agree-with-licenses")
+	}
+	args := append([]string{"--non-interactive", "update", "--auto-agree-with-licenses"}, packages...)
+	return runCommand(ctx, "zypper", args...)
+}
+
+// remove removes packages; rpm has no separate purge
+func (m *zypperManager) remove(ctx context.Context, packages []string, purge bool) (string, error) {
+	if purge {
+		return "", unsupported(m.name(), "purge")
+	}
+	return runCommand(ctx, "zypper", append([]string{"--non-interactive", "remove"}, packages...)...)
+}
+
+// search parses the `zypper search --details` table
+func (m *zypperManager) search(ctx context.Context, query string) ([]Package, error) {
+	output, err := queryCommand(ctx, "zypper", "--non-interactive", "--quiet",
+		"search", "--details", "--type", "package", query)
+	if err != nil {
+		// Exit code 104 means nothing matched
+		if strings.Contains(err.Error(), "exit status 104") {
+			return []Package{}, nil
+		}
+		return nil, err
+	}
+
+	packages := make([]Package, 0)
+	for _, cols := range zypperTable(output) {
+		if len(cols) < 6 {
+			continue
+		}
+		packages = append(packages, Package{Name: cols[1], Version: cols[3], Arch: cols[4], Repo: cols[5]})
+	}
+	return packages, nil
+}
+
+// inventory reads the rpm database
+func (m *zypperManager) inventory(ctx context.Context) ([]InventoryRecord, error) {
+	return readRPMDB(ctx)
+}
+
+// checkUpdates parses `zypper list-updates`. zypper tracks security fixes as
+// patches rather than per package, so the security count is the number of
+// needed security patches
+func (m *zypperManager) checkUpdates(ctx context.Context, refresh bool) ([]Update, int, error) {
+	if refresh {
+		if _, err := runCommand(ctx, "zypper", "--non-interactive", "refresh"); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	output, err := queryCommand(ctx, "zypper", "--non-interactive", "--quiet", "list-updates")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	updates := parseZypperUpdates(output)
+
+	patches, err := queryCommand(ctx, "zypper", "--non-interactive", "--quiet",
+		"list-patches", "--category", "security")
+	if err != nil {
+		return updates, -1, nil
+	}
+	return updates, len(zypperTable(patches)), nil
+}
+
+// compareVersions uses rpm's own comparison
+func (m *zypperManager) compareVersions(ctx context.Context, a, b string) (int, error) {
+	return rpmVercmp(ctx, a, b)
+}
+
+// hold adds package locks
+func (m *zypperManager) hold(ctx context.Context, packages []string) (string, error) {
+	return runCommand(ctx, "zypper", append([]string{"--non-interactive", "addlock"}, packages...)...)
+}
+
+// unhold removes package locks
+func (m *zypperManager) unhold(ctx context.Context, packages []string) (string, error) {
+	return runCommand(ctx, "zypper", append([]string{"--non-interactive", "removelock"}, packages...)...)
+}
+
+// listHeld parses the `zypper locks` table. Locks apply to a name, not a version
+func (m *zypperManager) listHeld(ctx context.Context) ([]Package, error) {
+	output, err := queryCommand(ctx, "zypper", "--non-interactive", "--quiet", "locks")
+	if err != nil {
+		return nil, err
+	}
+
+	packages := make([]Package, 0)
+	for _, cols := range zypperTable(output) {
+		// # | Name | Type | Repository
+		if len(cols) < 3 || (cols[2] != "package" && cols[2] != "") {
+			continue
+		}
+		packages = append(packages, Package{Name: cols[1]})
+	}
+	return packages, nil
+}
+
+// parseZypperUpdates parses the `zypper list-updates` table
+func parseZypperUpdates(output string) []Update {
+	updates := make([]Update, 0)
+	for _, cols := range zypperTable(output) {
+		// S | Repository | Name | Current Version | Available Version | Arch
+		if len(cols) < 6 {
+			continue
+		}
+		updates = append(updates, Update{
+			Repo:      cols[1],
+			Name:      cols[2],
+			Current:   cols[3],
+			Available: cols[4],
+			Arch:      cols[5],
+		})
+	}
+	return updates
+}
+
+// zypperTable splits zypper's "|"-separated table rows, skipping the header
+// and separator lines
+func zypperTable(output string) [][]string {
+	var rows [][]string
+	header := true
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.Contains(line, "|") {
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "--") {
+			continue
+		}
+		cols := strings.Split(line, "|")
+		for i := range cols {
+			cols[i] = strings.TrimSpace(cols[i])
+		}
+		if header {
+			header = false
+			continue
+		}
+		rows = append(rows, cols)
+	}
+	return rows
+}
@@ -0,0 +1,27 @@
+package package_executor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestZypperTable(t *testing.T) {
+	output := `Loading repository data...
+Reading installed packages...
+
+S  | Name       | Summary                | Type
+---+------------+------------------------+--------
+i+ | nginx      | A HTTP server and proxy | package
+   | nginx-docs |                        | package
+`
+	want := [][]string{
+		{"i+", "nginx", "A HTTP server and proxy", "package"},
+		{"", "nginx-docs", "", "package"},
+	}
+	if got := zypperTable(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := zypperTable("No matching items found.\n"); len(got) != 0 {
+		t.Errorf("no matches: got %q", got)
+	}
+}
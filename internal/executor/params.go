@@ -0,0 +1,90 @@
+package executor
+
+import "strings"
+
+// StringParam returns a string parameter or def if missing
+func (a *Action) StringParam(key, def string) string {
+	if v, ok := a.Params[key].(string); ok {
+		return v
+	}
+	return def
+}
+
+// IntParam returns a numeric parameter or def if missing
+// JSON numbers decode as float64, so both forms are accepted
+func (a *Action) IntParam(key string, def int) int {
+	switch v := a.Params[key].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	case int64:
+		return int(v)
+	}
+	return def
+}
+
+// Int64Param returns a numeric parameter as int64 or def if missing
+func (a *Action) Int64Param(key string, def int64) int64 {
+	switch v := a.Params[key].(type) {
+	case float64:
+		return int64(v)
+	case int:
+		return int64(v)
+	case int64:
+		return v
+	}
+	return def
+}
+
+// BoolParam returns a boolean parameter or def if missing
+func (a *Action) BoolParam(key string, def bool) bool {
+	if v, ok := a.Params[key].(bool); ok {
+		return v
+	}
+	return def
+}
+
+// StringsParam returns a list parameter, accepting a JSON array or a comma-separated string
+func (a *Action) StringsParam(key string) []string {
+	switch v := a.Params[key].(type) {
+	case []string:
+		return v
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok && s != "" {
+				out = append(out, s)
+			}
+		}
+		return out
+	case string:
+		var out []string
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// HasParam reports whether a parameter was supplied
+func (a *Action) HasParam(key string) bool {
+	_, ok := a.Params[key]
+	return ok
+}
+
+// FloatParam returns a numeric parameter as float64 or def if missing
+func (a *Action) FloatParam(key string, def float64) float64 {
+	switch v := a.Params[key].(type) {
+	case float64:
+		return v
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	}
+	return def
+}
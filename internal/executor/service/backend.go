@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"einfra/agent/internal/executor"
+)
+
+// initBackend is implemented once per init system. Methods take the service
+// name as the caller gave it and normalize it themselves
+type initBackend interface {
+	name() string
+	listServices(ctx context.Context) ([]UnitStatus, error)
+	controlService(ctx context.Context, service, op string) (string, error)
+	setBoot(ctx context.Context, service string, enable bool) ([]string, error)
+	serviceStatus(ctx context.Context, service string) (*UnitStatus, error)
+}
+
+// unsupportedError reports an operation the init system has no equivalent for
+type unsupportedError struct {
+	initSystem string
+	operation  string
+}
+
+func (e *unsupportedError) Error() string {
+	return fmt.Sprintf("unsupported: %s has no equivalent for %s", e.initSystem, e.operation)
+}
+
+// unsupported builds an unsupportedError
+func unsupported(initSystem, operation string) error {
+	return &unsupportedError{initSystem: initSystem, operation: operation}
+}
+
+// serviceNamePattern starts with an alphanumeric so a name is never read as an option
+var serviceNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9@._:\\-]*$`)
+
+// validServiceName rejects names that could be interpreted as options, paths
+// or shell syntax
+func validServiceName(name string) bool {
+	return serviceNamePattern.MatchString(name) && !strings.Contains(name, "..")
+}
+
+// detectInit picks the init system backend for this host
+func detectInit() initBackend {
+	if runtime.GOOS == "windows" {
+		return &windowsBackend{}
+	}
+
+	comm, _ := os.ReadFile("/proc/1/comm")
+	pid1 := strings.TrimSpace(string(comm))
+
+	switch {
+	case dirExists("/run/systemd/system"):
+		return &systemdBackend{}
+	case dirExists("/run/openrc") || pid1 == "openrc-init":
+		return &openrcBackend{}
+	case pid1 == "runit" || dirExists("/run/runit"):
+		return newRunitBackend()
+	case dirExists("/etc/init.d") && !commandExists("systemctl"):
+		return &sysvBackend{}
+	}
+
+	// Keep the historical default when nothing else is recognizable
+	return &systemdBackend{}
+}
+
+// failResult reports err on result, flagging operations the init system cannot do
+func failResult(result *executor.Result, prefix string, err error) *executor.Result {
+	result.Success = false
+
+	var u *unsupportedError
+	if errors.As(err, &u) {
+		result.Error = err.Error()
+		result.Data["unsupported"] = true
+		return result
+	}
+
+	result.Error = fmt.Sprintf("%s: %v", prefix, err)
+	return result
+}
+
+// runOutput runs a command and returns its trimmed combined output
+func runOutput(ctx context.Context, name string, args ...string) (string, error) {
+	output, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	out := strings.TrimSpace(string(output))
+	if err != nil && out != "" {
+		return out, fmt.Errorf("%w: %s", err, out)
+	}
+	return out, err
+}
+
+// exitCode returns the exit status of a finished command, or -1
+func exitCode(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	if err == nil {
+		return 0
+	}
+	return -1
+}
+
+// dirExists reports whether path is a directory
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// commandExists reports whether a binary is on PATH
+func commandExists(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
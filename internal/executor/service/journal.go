@@ -0,0 +1,239 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"einfra/agent/internal/executor"
+)
+
+const (
+	// defaultLogEntries is how many entries service_logs returns by default
+	defaultLogEntries = 200
+	// maxLogEntries bounds a single service_logs page
+	maxLogEntries = 5000
+)
+
+// JournalEntry is one structured journal record
+type JournalEntry struct {
+	Timestamp  time.Time `json:"timestamp"`
+	Priority   int       `json:"priority"`
+	Message    string    `json:"message"`
+	Identifier string    `json:"identifier,omitempty"`
+	PID        int       `json:"pid,omitempty"`
+	Unit       string    `json:"unit,omitempty"`
+	Hostname   string    `json:"hostname,omitempty"`
+	BootID     string    `json:"boot_id,omitempty"`
+	Cursor     string    `json:"cursor"`
+}
+
+// syslogPriorities maps names accepted in 'priority' to journal levels
+var syslogPriorities = map[string]int{
+	"emerg": 0, "alert": 1, "crit": 2, "err": 3, "error": 3,
+	"warning": 4, "warn": 4, "notice": 5, "info": 6, "debug": 7,
+}
+
+// getLogs returns journal entries for a unit. Without a cursor or start time it
+// returns the newest entries; otherwise it reads forward and reports a cursor
+// to resume from
+func (e *Executor) getLogs(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	if e.systemd == nil {
+		return failResult(result, "", unsupported(e.backend.name(), "journal logs"))
+	}
+
+	serviceName, ok := action.Params["service"].(string)
+	if !ok {
+		result.Success = false
+		result.Error = "missing 'service' parameter"
+		return result
+	}
+
+	maxEntries := action.IntParam("max_entries", defaultLogEntries)
+	if maxEntries <= 0 || maxEntries > maxLogEntries {
+		result.Success = false
+		result.Error = fmt.Sprintf("'max_entries' must be between 1 and %d", maxLogEntries)
+		return result
+	}
+
+	args := []string{"-u", unitName(serviceName), "--output=json", "--no-pager", "--quiet"}
+
+	since, err := journalTime(action, "since")
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+	until, err := journalTime(action, "until")
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+	if since != "" {
+		args = append(args, "--since", since)
+	}
+	if until != "" {
+		args = append(args, "--until", until)
+	}
+
+	if p := action.StringParam("priority", ""); p != "" {
+		level, ok := syslogPriorities[p]
+		if !ok {
+			n, err := strconv.Atoi(p)
+			if err != nil || n < 0 || n > 7 {
+				result.Success = false
+				result.Error = "'priority' must be 0-7 or a syslog level name"
+				return result
+			}
+			level = n
+		}
+		// journalctl treats a single level as "this level or more important"
+		args = append(args, "--priority", strconv.Itoa(level))
+	}
+
+	cursor := action.StringParam("cursor", "")
+	forward := cursor != "" || since != ""
+	if cursor != "" {
+		args = append(args, "--after-cursor", cursor)
+	}
+	if !forward {
+		args = append(args, "--lines", strconv.Itoa(maxEntries))
+	}
+
+	entries, hasMore, err := readJournal(ctx, args, maxEntries)
+	if err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to read journal: %v", err)
+		return result
+	}
+
+	// Hand back the position to resume from; with no new entries that is
+	// the cursor we were given
+	next := cursor
+	if len(entries) > 0 {
+		next = entries[len(entries)-1].Cursor
+	}
+
+	result.Data["entries"] = entries
+	result.Data["count"] = len(entries)
+	result.Data["cursor"] = next
+	result.Data["has_more"] = hasMore
+	result.Success = true
+	return result
+}
+
+// readJournal streams journalctl JSON output, stopping after max entries
+func readJournal(ctx context.Context, args []string, max int) ([]JournalEntry, bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "journalctl", args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, false, err
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+
+	if err := cmd.Start(); err != nil {
+		return nil, false, err
+	}
+
+	entries := make([]JournalEntry, 0)
+	hasMore := false
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		if len(entries) == max {
+			hasMore = true
+			break
+		}
+		var raw map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &raw); err != nil {
+			continue
+		}
+		entries = append(entries, parseJournalEntry(raw))
+	}
+
+	if hasMore {
+		// We have what we need; stop journalctl rather than draining it
+		cancel()
+		cmd.Wait()
+		return entries, true, nil
+	}
+
+	if err := cmd.Wait(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return nil, false, fmt.Errorf("%s", msg)
+	}
+
+	return entries, false, nil
+}
+
+// parseJournalEntry converts journalctl's JSON fields into a JournalEntry
+func parseJournalEntry(raw map[string]interface{}) JournalEntry {
+	field := func(key string) string {
+		switch v := raw[key].(type) {
+		case string:
+			return v
+		case []interface{}:
+			// Binary-safe fields are emitted as byte arrays
+			b := make([]byte, 0, len(v))
+			for _, c := range v {
+				if n, ok := c.(float64); ok {
+					b = append(b, byte(n))
+				}
+			}
+			return string(b)
+		}
+		return ""
+	}
+
+	entry := JournalEntry{
+		Message:    field("MESSAGE"),
+		Identifier: field("SYSLOG_IDENTIFIER"),
+		Unit:       field("_SYSTEMD_UNIT"),
+		Hostname:   field("_HOSTNAME"),
+		BootID:     field("_BOOT_ID"),
+		Cursor:     field("__CURSOR"),
+		Priority:   6,
+	}
+	if usec, err := strconv.ParseInt(field("__REALTIME_TIMESTAMP"), 10, 64); err == nil {
+		entry.Timestamp = time.UnixMicro(usec).UTC()
+	}
+	if p, err := strconv.Atoi(field("PRIORITY")); err == nil {
+		entry.Priority = p
+	}
+	if pid, err := strconv.Atoi(field("_PID")); err == nil {
+		entry.PID = pid
+	}
+
+	return entry
+}
+
+// journalTime converts an RFC3339 or unix-seconds parameter into journalctl's format
+func journalTime(action *executor.Action, key string) (string, error) {
+	switch v := action.Params[key].(type) {
+	case nil:
+		return "", nil
+	case float64:
+		return "@" + strconv.FormatInt(int64(v), 10), nil
+	case string:
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return "", fmt.Errorf("invalid '%s' parameter: %v", key, err)
+		}
+		return "@" + strconv.FormatInt(t.Unix(), 10), nil
+	}
+	return "", fmt.Errorf("invalid '%s' parameter", key)
+}
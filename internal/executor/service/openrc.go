@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// openrcRunlevel is the runlevel service_enable/disable manage
+const openrcRunlevel = "default"
+
+// openrcStatusLine matches " sshd    [  started  ]" lines from rc-status
+var openrcStatusLine = regexp.MustCompile(`^\s*(\S+)\s+\[\s*([^\]]*?)\s*\]`)
+
+// openrcBackend controls services with rc-service, rc-status and rc-update
+type openrcBackend struct{}
+
+// name identifies the init system in logs and unsupported errors
+func (b *openrcBackend) name() string {
+	return "openrc"
+}
+
+// listServices lists every init script with its state and runlevels
+func (b *openrcBackend) listServices(ctx context.Context) ([]UnitStatus, error) {
+	names, err := runOutput(ctx, "rc-service", "--list")
+	if err != nil {
+		return nil, fmt.Errorf("rc-service --list failed: %v", err)
+	}
+
+	// rc-status only shows services that are in a runlevel or were started
+	// manually; anything else is stopped
+	states := make(map[string]string)
+	if output, err := runOutput(ctx, "rc-status", "--all", "--nocolor"); err == nil {
+		for _, line := range strings.Split(output, "\n") {
+			if m := openrcStatusLine.FindStringSubmatch(line); m != nil {
+				states[m[1]] = m[2]
+			}
+		}
+	}
+	runlevels := openrcRunlevels(ctx)
+
+	services := make([]UnitStatus, 0)
+	for _, svc := range strings.Fields(names) {
+		state, ok := states[svc]
+		if !ok {
+			state = "stopped"
+		}
+		st := openrcStatus(svc, state)
+		st.UnitFileState = bootState(len(runlevels[svc]) > 0)
+		services = append(services, *st)
+	}
+	return services, nil
+}
+
+// controlService runs rc-service <name> start|stop|restart|reload
+func (b *openrcBackend) controlService(ctx context.Context, service, op string) (string, error) {
+	switch op {
+	case "start", "stop", "restart", "reload":
+	default:
+		return "", unsupported(b.name(), op)
+	}
+	return runOutput(ctx, "rc-service", service, op)
+}
+
+// setBoot adds or removes the service from the default runlevel
+func (b *openrcBackend) setBoot(ctx context.Context, service string, enable bool) ([]string, error) {
+	op := "del"
+	if enable {
+		op = "add"
+	}
+	output, err := runOutput(ctx, "rc-update", op, service, openrcRunlevel)
+	if err != nil {
+		return nil, err
+	}
+	return nonEmptyLines(output), nil
+}
+
+// serviceStatus parses `rc-service <name> status`, which exits non-zero for
+// anything but a started service
+func (b *openrcBackend) serviceStatus(ctx context.Context, service string) (*UnitStatus, error) {
+	output, err := exec.CommandContext(ctx, "rc-service", service, "status").CombinedOutput()
+	text := strings.TrimSpace(string(output))
+
+	_, state, ok := strings.Cut(text, "status:")
+	if !ok {
+		if err == nil {
+			err = fmt.Errorf("unexpected output")
+		}
+		return nil, fmt.Errorf("rc-service status failed: %v: %s", err, text)
+	}
+
+	st := openrcStatus(service, strings.TrimSpace(state))
+	st.UnitFileState = bootState(len(openrcRunlevels(ctx)[service]) > 0)
+	return st, nil
+}
+
+// openrcRunlevels maps each service to the runlevels it is added to
+func openrcRunlevels(ctx context.Context) map[string][]string {
+	levels := make(map[string][]string)
+	output, err := runOutput(ctx, "rc-update", "show", "--all")
+	if err != nil {
+		return levels
+	}
+	// Lines look like "   sshd | default boot"
+	for _, line := range strings.Split(output, "\n") {
+		svc, rest, ok := strings.Cut(line, "|")
+		if !ok {
+			continue
+		}
+		levels[strings.TrimSpace(svc)] = strings.Fields(rest)
+	}
+	return levels
+}
+
+// openrcStatus maps an OpenRC state word onto systemd-style states
+func openrcStatus(service, state string) *UnitStatus {
+	st := &UnitStatus{Name: service, LoadState: "loaded", SubState: state}
+	switch state {
+	case "started":
+		st.ActiveState = "active"
+		st.SubState = "running"
+	case "starting":
+		st.ActiveState = "activating"
+	case "stopping":
+		st.ActiveState = "deactivating"
+	case "crashed":
+		st.ActiveState = "failed"
+	case "stopped", "inactive":
+		st.ActiveState = "inactive"
+		st.SubState = "dead"
+	default:
+		st.ActiveState = "unknown"
+	}
+	return st
+}
+
+// bootState renders boot membership the way systemd's UnitFileState does
+func bootState(enabled bool) string {
+	if enabled {
+		return "enabled"
+	}
+	return "disabled"
+}
+
+// nonEmptyLines splits command output into trimmed, non-blank lines
+func nonEmptyLines(output string) []string {
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
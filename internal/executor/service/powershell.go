@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// windowsServiceQuery selects Get-Service fields with enums rendered as names
+const windowsServiceQuery = "Select-Object Name,DisplayName," +
+	"@{n='Status';e={$_.Status.ToString()}},@{n='StartType';e={$_.StartType.ToString()}} | ConvertTo-Json"
+
+// windowsService is one Get-Service record
+type windowsService struct {
+	Name        string `json:"Name"`
+	DisplayName string `json:"DisplayName"`
+	Status      string `json:"Status"`
+	StartType   string `json:"StartType"`
+}
+
+// windowsBackend controls services through the service control manager via PowerShell
+type windowsBackend struct{}
+
+// name identifies the init system in logs and unsupported errors
+func (b *windowsBackend) name() string {
+	return "windows"
+}
+
+// listServices lists all services
+func (b *windowsBackend) listServices(ctx context.Context) ([]UnitStatus, error) {
+	records, err := b.query(ctx, "Get-Service | "+windowsServiceQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	services := make([]UnitStatus, 0, len(records))
+	for _, r := range records {
+		services = append(services, *r.status())
+	}
+	return services, nil
+}
+
+// controlService runs Start-Service, Stop-Service or Restart-Service
+func (b *windowsBackend) controlService(ctx context.Context, service, op string) (string, error) {
+	var cmdlet string
+	switch op {
+	case "start":
+		cmdlet = "Start-Service"
+	case "stop":
+		cmdlet = "Stop-Service"
+	case "restart":
+		cmdlet = "Restart-Service"
+	default:
+		return "", unsupported(b.name(), op)
+	}
+	return runOutput(ctx, "powershell", "-Command", fmt.Sprintf("%s -Name '%s'", cmdlet, service))
+}
+
+// setBoot sets the startup type to Automatic or Disabled
+func (b *windowsBackend) setBoot(ctx context.Context, service string, enable bool) ([]string, error) {
+	startType := "Disabled"
+	if enable {
+		startType = "Automatic"
+	}
+	_, err := runOutput(ctx, "powershell", "-Command",
+		fmt.Sprintf("Set-Service -Name '%s' -StartupType %s", service, startType))
+	if err != nil {
+		return nil, err
+	}
+	return []string{"StartupType " + startType}, nil
+}
+
+// serviceStatus reads one service
+func (b *windowsBackend) serviceStatus(ctx context.Context, service string) (*UnitStatus, error) {
+	records, err := b.query(ctx, fmt.Sprintf("Get-Service -Name '%s' | %s", service, windowsServiceQuery))
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("service %s not found", service)
+	}
+	return records[0].status(), nil
+}
+
+// query runs a Get-Service pipeline and decodes its JSON, which is an object
+// for a single service and an array otherwise
+func (b *windowsBackend) query(ctx context.Context, command string) ([]windowsService, error) {
+	output, err := runOutput(ctx, "powershell", "-Command", command)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []windowsService
+	if strings.HasPrefix(output, "[") {
+		err = json.Unmarshal([]byte(output), &records)
+	} else if output != "" {
+		var one windowsService
+		err = json.Unmarshal([]byte(output), &one)
+		records = append(records, one)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Get-Service output: %v", err)
+	}
+	return records, nil
+}
+
+// status maps service controller states onto systemd-style states
+func (r windowsService) status() *UnitStatus {
+	st := &UnitStatus{
+		Name:        r.Name,
+		Description: r.DisplayName,
+		LoadState:   "loaded",
+		SubState:    strings.ToLower(r.Status),
+	}
+	switch r.Status {
+	case "Running":
+		st.ActiveState = "active"
+	case "Stopped":
+		st.ActiveState = "inactive"
+	case "StartPending", "ContinuePending":
+		st.ActiveState = "activating"
+	case "StopPending", "PausePending":
+		st.ActiveState = "deactivating"
+	case "Paused":
+		st.ActiveState = "inactive"
+	default:
+		st.ActiveState = "unknown"
+	}
+	switch r.StartType {
+	case "Automatic", "Boot", "System":
+		st.UnitFileState = "enabled"
+	case "Manual":
+		st.UnitFileState = "manual"
+	case "Disabled":
+		st.UnitFileState = "disabled"
+	}
+	return st
+}
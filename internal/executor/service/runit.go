@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// runitAvailableDir holds service definitions that can be enabled
+const runitAvailableDir = "/etc/sv"
+
+// runitServiceDirs are the runsvdir directories used by common distributions
+var runitServiceDirs = []string{"/var/service", "/etc/service", "/service", "/etc/runit/runsvdir/default"}
+
+// runitPID extracts the pid from "run: sshd: (pid 123) 45s"
+var runitPID = regexp.MustCompile(`\(pid (\d+)\)`)
+
+// runitBackend controls supervised services with sv; enabling links a
+// definition from /etc/sv into the directory runsvdir scans
+type runitBackend struct {
+	serviceDir string
+}
+
+// newRunitBackend locates the active runsvdir directory
+func newRunitBackend() *runitBackend {
+	b := &runitBackend{serviceDir: runitServiceDirs[0]}
+	for _, dir := range runitServiceDirs {
+		if dirExists(dir) {
+			b.serviceDir = dir
+			break
+		}
+	}
+	return b
+}
+
+// name identifies the init system in logs and unsupported errors
+func (b *runitBackend) name() string {
+	return "runit"
+}
+
+// listServices lists available and enabled services
+func (b *runitBackend) listServices(ctx context.Context) ([]UnitStatus, error) {
+	names := make(map[string]bool)
+	for _, dir := range []string{runitAvailableDir, b.serviceDir} {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			names[entry.Name()] = true
+		}
+	}
+
+	services := make([]UnitStatus, 0, len(names))
+	for svc := range names {
+		st, err := b.serviceStatus(ctx, svc)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue
+		}
+		services = append(services, *st)
+	}
+	return services, nil
+}
+
+// controlService runs sv up/down/restart/reload against the supervised service
+func (b *runitBackend) controlService(ctx context.Context, service, op string) (string, error) {
+	var cmd string
+	switch op {
+	case "start":
+		cmd = "up"
+	case "stop":
+		cmd = "down"
+	case "restart":
+		cmd = "restart"
+	case "reload":
+		// sv reload sends SIGHUP, which is the runit convention for reloading
+		cmd = "reload"
+	default:
+		return "", unsupported(b.name(), op)
+	}
+
+	if !b.enabled(service) {
+		return "", fmt.Errorf("%s is not enabled in %s; runit only supervises enabled services", service, b.serviceDir)
+	}
+	return runOutput(ctx, "sv", cmd, filepath.Join(b.serviceDir, service))
+}
+
+// setBoot links or unlinks the service definition; runsvdir starts or stops
+// it within a few seconds
+func (b *runitBackend) setBoot(ctx context.Context, service string, enable bool) ([]string, error) {
+	link := filepath.Join(b.serviceDir, service)
+
+	if !enable {
+		info, err := os.Lstat(link)
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			return nil, fmt.Errorf("%s is not a symlink, refusing to remove it", link)
+		}
+		if err := os.Remove(link); err != nil {
+			return nil, err
+		}
+		return []string{"unlink " + link}, nil
+	}
+
+	target := filepath.Join(runitAvailableDir, service)
+	if _, err := os.Stat(filepath.Join(target, "run")); err != nil {
+		return nil, fmt.Errorf("no runit service definition at %s", target)
+	}
+	if b.enabled(service) {
+		return nil, nil
+	}
+	if err := os.Symlink(target, link); err != nil {
+		return nil, err
+	}
+	return []string{fmt.Sprintf("symlink %s -> %s", link, target)}, nil
+}
+
+// serviceStatus parses `sv status`; services that are not enabled are
+// reported as inactive rather than asking sv about them
+func (b *runitBackend) serviceStatus(ctx context.Context, service string) (*UnitStatus, error) {
+	enabled := b.enabled(service)
+	st := &UnitStatus{Name: service, LoadState: "loaded", UnitFileState: bootState(enabled)}
+
+	if !enabled {
+		if _, err := os.Stat(filepath.Join(runitAvailableDir, service)); err != nil {
+			return nil, fmt.Errorf("no runit service named %s", service)
+		}
+		st.ActiveState, st.SubState = "inactive", "dead"
+		return st, nil
+	}
+
+	output, err := runOutput(ctx, "sv", "status", filepath.Join(b.serviceDir, service))
+	// The log service is reported after a semicolon; only the main service matters
+	main, _, _ := strings.Cut(output, ";")
+	state, _, _ := strings.Cut(main, ":")
+
+	switch state {
+	case "run":
+		st.ActiveState, st.SubState = "active", "running"
+		if m := runitPID.FindStringSubmatch(main); m != nil {
+			pid, _ := strconv.ParseUint(m[1], 10, 32)
+			st.MainPID = uint32(pid)
+		}
+	case "down":
+		st.ActiveState, st.SubState = "inactive", "dead"
+		// A service that should be up but is down has crashed or been stopped
+		if strings.Contains(main, "normally up") {
+			st.SubState = "down"
+		}
+	case "finish":
+		st.ActiveState, st.SubState = "deactivating", "finish"
+	case "warning":
+		// "warning: sshd: unable to open supervise/ok" means runsv is not running it
+		st.ActiveState, st.SubState = "failed", "unsupervised"
+	default:
+		if err == nil {
+			err = fmt.Errorf("unexpected output: %s", output)
+		}
+		return nil, fmt.Errorf("sv status failed: %v", err)
+	}
+	return st, nil
+}
+
+// enabled reports whether the service is linked into the runsvdir directory
+func (b *runitBackend) enabled(service string) bool {
+	_, err := os.Lstat(filepath.Join(b.serviceDir, service))
+	return err == nil
+}
@@ -2,10 +2,7 @@ package service
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"os/exec"
-	"runtime"
 	"strings"
 
 	"einfra/agent/internal/executor"
@@ -13,11 +10,28 @@ import (
 )
 
 // Executor handles service management actions
-type Executor struct{}
+type Executor struct {
+	backend initBackend
+	// systemd is set only when systemd is the init system; unit files,
+	// masking and journal logs depend on it
+	systemd *systemdBackend
+}
 
-// NewExecutor creates a service executor
+// NewExecutor creates a service executor for the detected init system
 func NewExecutor() *Executor {
-	return &Executor{}
+	backend := detectInit()
+	logger.Info().Str("init", backend.name()).Msg("Detected init system")
+
+	e := &Executor{backend: backend}
+	if sd, ok := backend.(*systemdBackend); ok {
+		e.systemd = sd
+	}
+	return e
+}
+
+// InitSystem returns the name of the init system services are managed through
+func (e *Executor) InitSystem() string {
+	return e.backend.name()
 }
 
 // SupportedActions returns list of supported action types
@@ -31,6 +45,12 @@ func (e *Executor) SupportedActions() []string {
 		"service_enable",
 		"service_disable",
 		"service_status",
+		"service_logs",
+		"service_unit_install",
+		"service_unit_remove",
+		"service_unit_cat",
+		"service_mask",
+		"service_unmask",
 	}
 }
 
@@ -50,6 +70,16 @@ func (e *Executor) Execute(ctx context.Context, action *executor.Action) *execut
 		return e.bootControl(ctx, action, result)
 	case "service_status":
 		return e.getStatus(ctx, action, result)
+	case "service_logs":
+		return e.getLogs(ctx, action, result)
+	case "service_unit_install":
+		return e.installUnit(ctx, action, result)
+	case "service_unit_remove":
+		return e.removeUnit(ctx, action, result)
+	case "service_unit_cat":
+		return e.catUnit(ctx, action, result)
+	case "service_mask", "service_unmask":
+		return e.maskControl(ctx, action, result)
 	default:
 		result.Success = false
 		result.Error = "unknown service action"
@@ -59,160 +89,108 @@ func (e *Executor) Execute(ctx context.Context, action *executor.Action) *execut
 
 // listServices lists all services
 func (e *Executor) listServices(ctx context.Context, result *executor.Result) *executor.Result {
-	var cmd *exec.Cmd
-
-	if runtime.GOOS == "linux" {
-		cmd = exec.CommandContext(ctx, "systemctl", "list-units", "--type=service", "--all", "--no-pager", "--output=json")
-	} else if runtime.GOOS == "windows" {
-		cmd = exec.CommandContext(ctx, "powershell", "-Command", "Get-Service | ConvertTo-Json")
-	} else {
-		result.Success = false
-		result.Error = "unsupported platform"
-		return result
-	}
-
-	output, err := cmd.CombinedOutput()
+	services, err := e.backend.listServices(ctx)
 	if err != nil {
-		result.Success = false
-		result.Error = fmt.Sprintf("failed to list services: %v", err)
-		return result
-	}
-
-	// Parse output
-	var services []map[string]interface{}
-	if err := json.Unmarshal(output, &services); err != nil {
-		// Fallback to text parsing if JSON fails
-		result.Output = string(output)
-	} else {
-		result.Data["services"] = services
+		return failResult(result, "failed to list services", err)
 	}
 
+	result.Data["init_system"] = e.backend.name()
+	result.Data["services"] = services
 	result.Success = true
 	return result
 }
 
-// controlService starts/stops/restarts a service
-func (e *Executor) controlService(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+// serviceParam reads and validates the 'service' parameter
+func serviceParam(action *executor.Action) (string, error) {
 	serviceName, ok := action.Params["service"].(string)
 	if !ok {
+		return "", fmt.Errorf("missing 'service' parameter")
+	}
+	if !validServiceName(serviceName) {
+		return "", fmt.Errorf("invalid service name: %s", serviceName)
+	}
+	return serviceName, nil
+}
+
+// controlService starts/stops/restarts/reloads a service
+func (e *Executor) controlService(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	serviceName, err := serviceParam(action)
+	if err != nil {
 		result.Success = false
-		result.Error = "missing 'service' parameter"
+		result.Error = err.Error()
 		return result
 	}
 
-	var cmd *exec.Cmd
 	actionType := strings.TrimPrefix(action.Type, "service_")
 
-	if runtime.GOOS == "linux" {
-		cmd = exec.CommandContext(ctx, "systemctl", actionType, serviceName)
-	} else if runtime.GOOS == "windows" {
-		var psAction string
-		switch actionType {
-		case "start":
-			psAction = "Start-Service"
-		case "stop":
-			psAction = "Stop-Service"
-		case "restart":
-			psAction = "Restart-Service"
-		default:
-			result.Success = false
-			result.Error = "unsupported action on Windows"
-			return result
-		}
-		cmd = exec.CommandContext(ctx, "powershell", "-Command", fmt.Sprintf("%s -Name %s", psAction, serviceName))
-	}
-
 	logger.Info().
 		Str("action", action.Type).
 		Str("service", serviceName).
+		Str("init", e.backend.name()).
 		Msg("Executing service action")
 
-	output, err := cmd.CombinedOutput()
-	result.Output = string(output)
+	output, err := e.backend.controlService(ctx, serviceName, actionType)
+	if e.systemd != nil {
+		result.Data["job_result"] = output
+	} else {
+		result.Output = output
+	}
+	if st, statusErr := e.backend.serviceStatus(ctx, serviceName); statusErr == nil {
+		result.Data["status"] = st
+	}
 
 	if err != nil {
-		result.Success = false
-		result.Error = fmt.Sprintf("command failed: %v", err)
 		logger.Error().
 			Err(err).
 			Str("service", serviceName).
-			Str("output", result.Output).
 			Msg("Service action failed")
-	} else {
-		result.Success = true
-		logger.Info().
-			Str("service", serviceName).
-			Msg("Service action completed successfully")
+		return failResult(result, "command failed", err)
 	}
 
+	result.Success = true
+	logger.Info().
+		Str("service", serviceName).
+		Msg("Service action completed successfully")
 	return result
 }
 
 // bootControl enables/disables service at boot
 func (e *Executor) bootControl(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
-	serviceName, ok := action.Params["service"].(string)
-	if !ok {
+	serviceName, err := serviceParam(action)
+	if err != nil {
 		result.Success = false
-		result.Error = "missing 'service' parameter"
+		result.Error = err.Error()
 		return result
 	}
 
-	var cmd *exec.Cmd
 	actionType := strings.TrimPrefix(action.Type, "service_")
 
-	if runtime.GOOS == "linux" {
-		cmd = exec.CommandContext(ctx, "systemctl", actionType, serviceName)
-	} else if runtime.GOOS == "windows" {
-		var startType string
-		if actionType == "enable" {
-			startType = "Automatic"
-		} else {
-			startType = "Disabled"
-		}
-		cmd = exec.CommandContext(ctx, "powershell", "-Command",
-			fmt.Sprintf("Set-Service -Name %s -StartupType %s", serviceName, startType))
-	}
-
-	output, err := cmd.CombinedOutput()
-	result.Output = string(output)
-
+	changes, err := e.backend.setBoot(ctx, serviceName, actionType == "enable")
+	result.Data["changes"] = changes
 	if err != nil {
-		result.Success = false
-		result.Error = fmt.Sprintf("command failed: %v", err)
-	} else {
-		result.Success = true
+		return failResult(result, fmt.Sprintf("failed to %s service", actionType), err)
 	}
 
+	result.Success = true
 	return result
 }
 
 // getStatus gets service status
 func (e *Executor) getStatus(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
-	serviceName, ok := action.Params["service"].(string)
-	if !ok {
+	serviceName, err := serviceParam(action)
+	if err != nil {
 		result.Success = false
-		result.Error = "missing 'service' parameter"
+		result.Error = err.Error()
 		return result
 	}
 
-	var cmd *exec.Cmd
-
-	if runtime.GOOS == "linux" {
-		cmd = exec.CommandContext(ctx, "systemctl", "status", serviceName, "--no-pager")
-	} else if runtime.GOOS == "windows" {
-		cmd = exec.CommandContext(ctx, "powershell", "-Command",
-			fmt.Sprintf("Get-Service -Name %s | ConvertTo-Json", serviceName))
-	}
-
-	output, err := cmd.CombinedOutput()
-	result.Output = string(output)
-
+	st, err := e.backend.serviceStatus(ctx, serviceName)
 	if err != nil {
-		// Status command returns non-zero for inactive services, which is OK
-		result.Success = true
-	} else {
-		result.Success = true
+		return failResult(result, "failed to get service status", err)
 	}
 
+	result.Data["init_system"] = e.backend.name()
+	result.Data["status"] = st
+	result.Success = true
 	return result
 }
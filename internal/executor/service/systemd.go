@@ -0,0 +1,456 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"einfra/agent/internal/logger"
+
+	sddbus "github.com/coreos/go-systemd/v22/dbus"
+)
+
+// errBusUnavailable means the system D-Bus socket could not be reached, so
+// callers should fall back to systemctl
+var errBusUnavailable = errors.New("systemd D-Bus unavailable")
+
+// UnitStatus is the structured state of a service. Backends other than
+// systemd fill the fields their init system can report
+type UnitStatus struct {
+	Name                 string    `json:"name"`
+	Description          string    `json:"description"`
+	LoadState            string    `json:"load_state"`
+	ActiveState          string    `json:"active_state"`
+	SubState             string    `json:"sub_state"`
+	UnitFileState        string    `json:"unit_file_state,omitempty"`
+	FragmentPath         string    `json:"fragment_path,omitempty"`
+	MainPID              uint32    `json:"main_pid"`
+	MemoryCurrent        uint64    `json:"memory_current,omitempty"`
+	CPUUsageNSec         uint64    `json:"cpu_usage_nsec,omitempty"`
+	TasksCurrent         uint64    `json:"tasks_current,omitempty"`
+	NRestarts            uint32    `json:"restart_count"`
+	Result               string    `json:"result,omitempty"`
+	ExecMainCode         int32     `json:"exec_main_code"`
+	ExecMainStatus       int32     `json:"exec_main_status"`
+	ActiveEnterTimestamp time.Time `json:"active_enter_timestamp,omitempty"`
+	StateChangeTimestamp time.Time `json:"state_change_timestamp,omitempty"`
+}
+
+// unitProperties lists the properties read for UnitStatus, used by the systemctl fallback
+var unitProperties = []string{
+	"Id", "Description", "LoadState", "ActiveState", "SubState", "UnitFileState",
+	"FragmentPath", "MainPID", "MemoryCurrent", "CPUUsageNSec", "TasksCurrent",
+	"NRestarts", "Result", "ExecMainCode", "ExecMainStatus",
+	"ActiveEnterTimestamp", "StateChangeTimestamp",
+}
+
+// systemdBackend controls units through org.freedesktop.systemd1 on the system bus
+type systemdBackend struct {
+	mu   sync.Mutex
+	conn *sddbus.Conn
+}
+
+// connection returns a live bus connection, reconnecting if the previous one dropped
+func (b *systemdBackend) connection(ctx context.Context) (*sddbus.Conn, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.conn != nil && b.conn.Connected() {
+		return b.conn, nil
+	}
+	if b.conn != nil {
+		b.conn.Close()
+		b.conn = nil
+	}
+
+	conn, err := sddbus.NewSystemConnectionContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errBusUnavailable, err)
+	}
+	b.conn = conn
+	return conn, nil
+}
+
+// listUnits returns all loaded service units
+func (b *systemdBackend) listUnits(ctx context.Context) ([]UnitStatus, error) {
+	conn, err := b.connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	units, err := conn.ListUnitsByPatternsContext(ctx, nil, []string{"*.service"})
+	if err != nil {
+		return nil, err
+	}
+
+	services := make([]UnitStatus, 0, len(units))
+	for _, u := range units {
+		services = append(services, UnitStatus{
+			Name:        u.Name,
+			Description: u.Description,
+			LoadState:   u.LoadState,
+			ActiveState: u.ActiveState,
+			SubState:    u.SubState,
+		})
+	}
+	return services, nil
+}
+
+// control runs start/stop/restart/reload and waits for the job to finish
+func (b *systemdBackend) control(ctx context.Context, unit, op string) (string, error) {
+	conn, err := b.connection(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	ch := make(chan string, 1)
+	switch op {
+	case "start":
+		_, err = conn.StartUnitContext(ctx, unit, "replace", ch)
+	case "stop":
+		_, err = conn.StopUnitContext(ctx, unit, "replace", ch)
+	case "restart":
+		_, err = conn.RestartUnitContext(ctx, unit, "replace", ch)
+	case "reload":
+		_, err = conn.ReloadUnitContext(ctx, unit, "replace", ch)
+	default:
+		return "", fmt.Errorf("unsupported operation: %s", op)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case res := <-ch:
+		// "done" is the only successful job result; others are failed, timeout, canceled, dependency, skipped
+		if res != "done" {
+			return res, fmt.Errorf("%s job for %s finished with result %q", op, unit, res)
+		}
+		return res, nil
+	}
+}
+
+// setEnabled enables or disables a unit at boot and reloads the manager
+func (b *systemdBackend) setEnabled(ctx context.Context, unit string, enable bool) ([]string, error) {
+	conn, err := b.connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []string
+	if enable {
+		_, res, err := conn.EnableUnitFilesContext(ctx, []string{unit}, false, true)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range res {
+			changes = append(changes, fmt.Sprintf("%s %s -> %s", c.Type, c.Filename, c.Destination))
+		}
+	} else {
+		res, err := conn.DisableUnitFilesContext(ctx, []string{unit}, false)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range res {
+			changes = append(changes, fmt.Sprintf("%s %s", c.Type, c.Filename))
+		}
+	}
+
+	return changes, conn.ReloadContext(ctx)
+}
+
+// status reads unit and service properties for one unit
+func (b *systemdBackend) status(ctx context.Context, unit string) (*UnitStatus, error) {
+	conn, err := b.connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	props, err := conn.GetUnitPropertiesContext(ctx, unit)
+	if err != nil {
+		return nil, err
+	}
+
+	st := &UnitStatus{
+		Name:                 propString(props, "Id"),
+		Description:          propString(props, "Description"),
+		LoadState:            propString(props, "LoadState"),
+		ActiveState:          propString(props, "ActiveState"),
+		SubState:             propString(props, "SubState"),
+		UnitFileState:        propString(props, "UnitFileState"),
+		FragmentPath:         propString(props, "FragmentPath"),
+		ActiveEnterTimestamp: propTime(props, "ActiveEnterTimestamp"),
+		StateChangeTimestamp: propTime(props, "StateChangeTimestamp"),
+	}
+
+	// Service properties are absent for units that are not loaded
+	if strings.HasSuffix(unit, ".service") && st.LoadState == "loaded" {
+		svc, err := conn.GetUnitTypePropertiesContext(ctx, unit, "Service")
+		if err == nil {
+			st.MainPID = uint32(propUint(svc, "MainPID"))
+			st.MemoryCurrent = propUint(svc, "MemoryCurrent")
+			st.CPUUsageNSec = propUint(svc, "CPUUsageNSec")
+			st.TasksCurrent = propUint(svc, "TasksCurrent")
+			st.NRestarts = uint32(propUint(svc, "NRestarts"))
+			st.Result = propString(svc, "Result")
+			st.ExecMainCode = int32(propInt(svc, "ExecMainCode"))
+			st.ExecMainStatus = int32(propInt(svc, "ExecMainStatus"))
+		}
+	}
+
+	return st, nil
+}
+
+// reload asks the manager to re-read unit files (daemon-reload)
+func (b *systemdBackend) reload(ctx context.Context) error {
+	conn, err := b.connection(ctx)
+	if err != nil {
+		return err
+	}
+	return conn.ReloadContext(ctx)
+}
+
+// setMasked masks or unmasks a unit and reloads the manager
+func (b *systemdBackend) setMasked(ctx context.Context, unit string, mask bool) ([]string, error) {
+	conn, err := b.connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []string
+	if mask {
+		res, err := conn.MaskUnitFilesContext(ctx, []string{unit}, false, true)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range res {
+			changes = append(changes, fmt.Sprintf("%s %s -> %s", c.Type, c.Filename, c.Destination))
+		}
+	} else {
+		res, err := conn.UnmaskUnitFilesContext(ctx, []string{unit}, false)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range res {
+			changes = append(changes, fmt.Sprintf("%s %s", c.Type, c.Filename))
+		}
+	}
+
+	return changes, conn.ReloadContext(ctx)
+}
+
+// unitFiles returns the fragment path and drop-in paths systemd loaded for a unit
+func (b *systemdBackend) unitFiles(ctx context.Context, unit string) (string, []string, error) {
+	conn, err := b.connection(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+
+	props, err := conn.GetUnitPropertiesContext(ctx, unit)
+	if err != nil {
+		return "", nil, err
+	}
+	dropIns, _ := props["DropInPaths"].([]string)
+	return propString(props, "FragmentPath"), dropIns, nil
+}
+
+// name identifies the init system in logs and unsupported errors
+func (b *systemdBackend) name() string {
+	return "systemd"
+}
+
+// listServices lists service units, falling back to systemctl without a bus
+func (b *systemdBackend) listServices(ctx context.Context) ([]UnitStatus, error) {
+	services, err := b.listUnits(ctx)
+	if errors.Is(err, errBusUnavailable) {
+		logger.Debug().Err(err).Msg("Falling back to systemctl")
+		return systemctlList(ctx)
+	}
+	return services, err
+}
+
+// controlService runs a unit job, falling back to systemctl without a bus
+func (b *systemdBackend) controlService(ctx context.Context, service, op string) (string, error) {
+	unit := unitName(service)
+	jobResult, err := b.control(ctx, unit, op)
+	if errors.Is(err, errBusUnavailable) {
+		return runOutput(ctx, "systemctl", op, unit)
+	}
+	return jobResult, err
+}
+
+// setBoot enables or disables a unit, falling back to systemctl without a bus
+func (b *systemdBackend) setBoot(ctx context.Context, service string, enable bool) ([]string, error) {
+	unit := unitName(service)
+	changes, err := b.setEnabled(ctx, unit, enable)
+	if errors.Is(err, errBusUnavailable) {
+		op := "disable"
+		if enable {
+			op = "enable"
+		}
+		_, err = runOutput(ctx, "systemctl", op, unit)
+		return nil, err
+	}
+	return changes, err
+}
+
+// serviceStatus reads unit status, falling back to systemctl show without a bus
+func (b *systemdBackend) serviceStatus(ctx context.Context, service string) (*UnitStatus, error) {
+	unit := unitName(service)
+	st, err := b.status(ctx, unit)
+	if errors.Is(err, errBusUnavailable) {
+		return systemctlStatus(ctx, unit)
+	}
+	return st, err
+}
+
+// unitName adds the .service suffix when a bare name is given
+func unitName(name string) string {
+	for _, suffix := range []string{".service", ".socket", ".timer", ".target", ".mount", ".path", ".slice", ".scope"} {
+		if strings.HasSuffix(name, suffix) {
+			return name
+		}
+	}
+	return name + ".service"
+}
+
+// propString reads a string property
+func propString(props map[string]interface{}, key string) string {
+	s, _ := props[key].(string)
+	return s
+}
+
+// propUint reads an unsigned property, treating systemd's "unset" (max uint64) as zero
+func propUint(props map[string]interface{}, key string) uint64 {
+	switch v := props[key].(type) {
+	case uint64:
+		if v == math.MaxUint64 {
+			return 0
+		}
+		return v
+	case uint32:
+		return uint64(v)
+	}
+	return 0
+}
+
+// propInt reads a signed property
+func propInt(props map[string]interface{}, key string) int64 {
+	switch v := props[key].(type) {
+	case int32:
+		return int64(v)
+	case int64:
+		return v
+	}
+	return 0
+}
+
+// propTime converts a realtime microsecond timestamp property
+func propTime(props map[string]interface{}, key string) time.Time {
+	usec := propUint(props, key)
+	if usec == 0 {
+		return time.Time{}
+	}
+	return time.UnixMicro(int64(usec)).UTC()
+}
+
+// systemctlStatus reads unit properties with `systemctl show` when D-Bus is unavailable
+func systemctlStatus(ctx context.Context, unit string) (*UnitStatus, error) {
+	cmd := exec.CommandContext(ctx, "systemctl", "show", unit, "--no-pager",
+		"--timestamp=unix", "--property="+strings.Join(unitProperties, ","))
+	output, err := cmd.Output()
+	if err != nil {
+		// Older systemctl lacks --timestamp; retry without it
+		cmd = exec.CommandContext(ctx, "systemctl", "show", unit, "--no-pager",
+			"--property="+strings.Join(unitProperties, ","))
+		if output, err = cmd.Output(); err != nil {
+			return nil, fmt.Errorf("systemctl show failed: %v", err)
+		}
+	}
+
+	props := make(map[string]string)
+	for _, line := range strings.Split(string(output), "\n") {
+		if k, v, ok := strings.Cut(line, "="); ok {
+			props[k] = v
+		}
+	}
+
+	num := func(key string) uint64 {
+		n, _ := strconv.ParseUint(props[key], 10, 64)
+		if n == math.MaxUint64 {
+			return 0
+		}
+		return n
+	}
+	signed := func(key string) int32 {
+		n, _ := strconv.ParseInt(props[key], 10, 32)
+		return int32(n)
+	}
+	stamp := func(key string) time.Time {
+		// --timestamp=unix renders "@<seconds>"
+		if s := strings.TrimPrefix(props[key], "@"); s != props[key] {
+			if n, err := strconv.ParseInt(s, 10, 64); err == nil && n > 0 {
+				return time.Unix(n, 0).UTC()
+			}
+		}
+		return time.Time{}
+	}
+
+	return &UnitStatus{
+		Name:                 props["Id"],
+		Description:          props["Description"],
+		LoadState:            props["LoadState"],
+		ActiveState:          props["ActiveState"],
+		SubState:             props["SubState"],
+		UnitFileState:        props["UnitFileState"],
+		FragmentPath:         props["FragmentPath"],
+		MainPID:              uint32(num("MainPID")),
+		MemoryCurrent:        num("MemoryCurrent"),
+		CPUUsageNSec:         num("CPUUsageNSec"),
+		TasksCurrent:         num("TasksCurrent"),
+		NRestarts:            uint32(num("NRestarts")),
+		Result:               props["Result"],
+		ExecMainCode:         signed("ExecMainCode"),
+		ExecMainStatus:       signed("ExecMainStatus"),
+		ActiveEnterTimestamp: stamp("ActiveEnterTimestamp"),
+		StateChangeTimestamp: stamp("StateChangeTimestamp"),
+	}, nil
+}
+
+// systemctlList parses `systemctl list-units` plain output when D-Bus is unavailable
+func systemctlList(ctx context.Context) ([]UnitStatus, error) {
+	cmd := exec.CommandContext(ctx, "systemctl", "list-units", "--type=service", "--all",
+		"--no-pager", "--no-legend", "--plain")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list services: %v", err)
+	}
+
+	var services []UnitStatus
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		// Failed units may be prefixed with a status marker
+		if len(fields) > 0 && !strings.Contains(fields[0], ".") {
+			fields = fields[1:]
+		}
+		if len(fields) < 4 {
+			continue
+		}
+		services = append(services, UnitStatus{
+			Name:        fields[0],
+			LoadState:   fields[1],
+			ActiveState: fields[2],
+			SubState:    fields[3],
+			Description: strings.Join(fields[4:], " "),
+		})
+	}
+	return services, nil
+}
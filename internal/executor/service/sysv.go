@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sysvInitDir holds the init scripts
+const sysvInitDir = "/etc/init.d"
+
+// sysvIgnored are files in /etc/init.d that are not services
+var sysvIgnored = map[string]bool{
+	"README": true, "skeleton": true, "rc": true, "rcS": true, "rc.local": true,
+	"functions": true, "halt": true, "killall": true, "single": true,
+}
+
+// sysvBackend controls LSB init scripts with service and update-rc.d or chkconfig
+type sysvBackend struct{}
+
+// name identifies the init system in logs and unsupported errors
+func (b *sysvBackend) name() string {
+	return "sysv"
+}
+
+// listServices runs the status action of every init script
+func (b *sysvBackend) listServices(ctx context.Context) ([]UnitStatus, error) {
+	entries, err := os.ReadDir(sysvInitDir)
+	if err != nil {
+		return nil, err
+	}
+
+	services := make([]UnitStatus, 0, len(entries))
+	for _, entry := range entries {
+		svc := entry.Name()
+		if entry.IsDir() || sysvIgnored[svc] || strings.Contains(svc, ".dpkg-") || strings.HasPrefix(svc, ".") {
+			continue
+		}
+		st, err := b.serviceStatus(ctx, svc)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue
+		}
+		services = append(services, *st)
+	}
+	return services, nil
+}
+
+// controlService runs the init script action through service(8)
+func (b *sysvBackend) controlService(ctx context.Context, service, op string) (string, error) {
+	switch op {
+	case "start", "stop", "restart", "reload":
+	default:
+		return "", unsupported(b.name(), op)
+	}
+
+	output, err := b.run(ctx, service, op)
+	// LSB reserves exit status 3 for "unimplemented feature", which reload is allowed to be
+	if op == "reload" && exitCode(err) == 3 {
+		return output, unsupported(b.name(), "reload of "+service)
+	}
+	return output, err
+}
+
+// setBoot manages rc.d links with update-rc.d (Debian) or chkconfig (Red Hat)
+func (b *sysvBackend) setBoot(ctx context.Context, service string, enable bool) ([]string, error) {
+	if _, err := os.Stat(filepath.Join(sysvInitDir, service)); err != nil {
+		return nil, fmt.Errorf("no init script for %s", service)
+	}
+
+	var outputs []string
+	run := func(name string, args ...string) error {
+		output, err := runOutput(ctx, name, args...)
+		outputs = append(outputs, nonEmptyLines(output)...)
+		return err
+	}
+
+	switch {
+	case commandExists("update-rc.d"):
+		if enable {
+			// defaults creates the links if missing; enable flips existing K links to S
+			if err := run("update-rc.d", service, "defaults"); err != nil {
+				return outputs, err
+			}
+			return outputs, run("update-rc.d", service, "enable")
+		}
+		return outputs, run("update-rc.d", service, "disable")
+	case commandExists("chkconfig"):
+		if enable {
+			if err := run("chkconfig", "--add", service); err != nil {
+				return outputs, err
+			}
+			return outputs, run("chkconfig", service, "on")
+		}
+		return outputs, run("chkconfig", service, "off")
+	}
+
+	return nil, unsupported(b.name(), "boot configuration without update-rc.d or chkconfig")
+}
+
+// serviceStatus maps the LSB status exit code onto systemd-style states
+func (b *sysvBackend) serviceStatus(ctx context.Context, service string) (*UnitStatus, error) {
+	if _, err := os.Stat(filepath.Join(sysvInitDir, service)); err != nil {
+		return nil, fmt.Errorf("no init script for %s", service)
+	}
+
+	_, err := b.run(ctx, service, "status")
+	st := &UnitStatus{Name: service, LoadState: "loaded", UnitFileState: bootState(sysvEnabled(service))}
+
+	code := exitCode(err)
+	switch code {
+	case 0:
+		st.ActiveState, st.SubState = "active", "running"
+	case 1, 2:
+		// Dead but a pid or lock file remains
+		st.ActiveState, st.SubState = "failed", "dead"
+	case 3:
+		st.ActiveState, st.SubState = "inactive", "dead"
+	case -1:
+		return nil, err
+	default:
+		st.ActiveState, st.SubState = "unknown", "unknown"
+	}
+	st.ExecMainStatus = int32(code)
+	st.MainPID = sysvPID(service)
+	return st, nil
+}
+
+// run invokes an init script action, preferring service(8) for a clean environment
+func (b *sysvBackend) run(ctx context.Context, service, op string) (string, error) {
+	if commandExists("service") {
+		return runOutput(ctx, "service", service, op)
+	}
+	return runOutput(ctx, filepath.Join(sysvInitDir, service), op)
+}
+
+// sysvEnabled reports whether any multi-user runlevel starts the service
+func sysvEnabled(service string) bool {
+	for _, level := range []string{"2", "3", "4", "5"} {
+		for _, dir := range []string{"/etc/rc" + level + ".d", "/etc/rc.d/rc" + level + ".d"} {
+			if matches, _ := filepath.Glob(filepath.Join(dir, "S[0-9][0-9]"+service)); len(matches) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sysvPID reads the conventional /var/run/<name>.pid file, if any
+func sysvPID(service string) uint32 {
+	data, err := os.ReadFile(filepath.Join("/var/run", service+".pid"))
+	if err != nil {
+		return 0
+	}
+	var pid uint32
+	fmt.Sscanf(strings.TrimSpace(string(data)), "%d", &pid)
+	return pid
+}
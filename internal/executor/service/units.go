@@ -0,0 +1,398 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"einfra/agent/internal/executor"
+	"einfra/agent/internal/fsutil"
+	"einfra/agent/internal/logger"
+)
+
+// unitDir is where administrator-managed unit files live
+const unitDir = "/etc/systemd/system"
+
+var (
+	// validUnitName matches unit names, including template instances. The
+	// leading alphanumeric keeps a name from being read as an option
+	validUnitName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9:_.\\@-]*$`)
+	// installableUnit matches the unit types unit_install writes
+	installableUnit = regexp.MustCompile(`\.(service|socket|timer|target|mount|path)$`)
+	// validDropInName matches drop-in file names without the .conf suffix
+	validDropInName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+)
+
+// unitParam reads 'service' as a unit name, adding .service when it has no
+// unit type suffix
+func unitParam(action *executor.Action) (string, error) {
+	serviceName, ok := action.Params["service"].(string)
+	if !ok {
+		return "", fmt.Errorf("missing 'service' parameter")
+	}
+
+	unit := unitName(serviceName)
+	if !validUnitName.MatchString(unit) || strings.Contains(unit, "..") {
+		return "", fmt.Errorf("invalid unit name: %s", unit)
+	}
+	return unit, nil
+}
+
+// unitPaths resolves the file a unit_install/remove touches: the unit itself
+// or, when dropin is set, <unit>.d/<dropin>.conf
+func unitPaths(action *executor.Action) (unit, path string, err error) {
+	unit, err = unitParam(action)
+	if err != nil {
+		return "", "", err
+	}
+	if !installableUnit.MatchString(unit) {
+		return "", "", fmt.Errorf("unsupported unit type: %s", unit)
+	}
+
+	dropIn := action.StringParam("dropin", "")
+	if dropIn == "" {
+		return unit, filepath.Join(unitDir, unit), nil
+	}
+
+	dropIn = strings.TrimSuffix(dropIn, ".conf")
+	if !validDropInName.MatchString(dropIn) {
+		return "", "", fmt.Errorf("invalid drop-in name: %s", dropIn)
+	}
+	return unit, filepath.Join(unitDir, unit+".d", dropIn+".conf"), nil
+}
+
+// installUnit writes a unit file or drop-in, validates it, reloads systemd and
+// optionally enables and starts it. Any failure restores the previous content
+func (e *Executor) installUnit(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	if e.systemd == nil {
+		return failResult(result, "", unsupported(e.backend.name(), "unit files"))
+	}
+
+	unit, path, err := unitPaths(action)
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+	// Writing through a masked (-> /dev/null) or linked unit would change its target
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		result.Success = false
+		result.Error = fmt.Sprintf("%s is a symlink (masked or linked unit); unmask or unlink it first", path)
+		return result
+	}
+
+	content, ok := action.Params["content"].(string)
+	if !ok || strings.TrimSpace(content) == "" {
+		result.Success = false
+		result.Error = "missing 'content' parameter"
+		return result
+	}
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+
+	previous, err := os.ReadFile(path)
+	existed := err == nil
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to read existing unit: %v", err)
+		return result
+	}
+
+	result.Data["unit"] = unit
+	result.Data["path"] = path
+	result.Data["replaced"] = existed
+
+	if existed && string(previous) == content {
+		result.Data["changed"] = false
+	} else {
+		result.Data["changed"] = true
+
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			result.Success = false
+			result.Error = fmt.Sprintf("failed to create unit directory: %v", err)
+			return result
+		}
+		if err := fsutil.WriteFileAtomic(path, []byte(content), 0644); err != nil {
+			result.Success = false
+			result.Error = fmt.Sprintf("failed to write unit: %v", err)
+			return result
+		}
+
+		if output, err := verifyUnit(ctx, unit, path); err != nil {
+			result.Output = output
+			e.rollbackUnit(ctx, path, previous, existed, result)
+			result.Success = false
+			result.Error = fmt.Sprintf("unit validation failed: %v", err)
+			return result
+		}
+
+		if err := e.daemonReload(ctx); err != nil {
+			e.rollbackUnit(ctx, path, previous, existed, result)
+			result.Success = false
+			result.Error = fmt.Sprintf("daemon-reload failed: %v", err)
+			return result
+		}
+
+		logger.Info().
+			Str("unit", unit).
+			Str("path", path).
+			Msg("Unit file installed")
+	}
+
+	// enabled records that this call enabled a unit that was not enabled, so
+	// a rollback disables it again before its file goes
+	enabled := false
+	if action.BoolParam("enable", false) {
+		wasEnabled := false
+		if st, err := e.systemd.serviceStatus(ctx, unit); err == nil {
+			wasEnabled = strings.HasPrefix(st.UnitFileState, "enabled")
+		}
+		if _, err := e.systemd.setBoot(ctx, unit, true); err != nil {
+			if result.Data["changed"] == true {
+				if !wasEnabled {
+					// Drop any symlinks created before the failure
+					e.systemd.setBoot(ctx, unit, false)
+				}
+				e.rollbackUnit(ctx, path, previous, existed, result)
+				e.daemonReload(ctx)
+			}
+			result.Success = false
+			result.Error = fmt.Sprintf("failed to enable unit: %v", err)
+			return result
+		}
+		enabled = !wasEnabled
+	}
+
+	// A changed unit must be (re)started for the new content to take effect
+	if action.BoolParam("start", false) {
+		if _, err := e.systemd.controlService(ctx, unit, "restart"); err != nil {
+			if result.Data["changed"] == true {
+				if enabled {
+					// Disable while the unit file exists, so no wants/ symlinks dangle
+					e.systemd.setBoot(ctx, unit, false)
+				}
+				e.rollbackUnit(ctx, path, previous, existed, result)
+				e.daemonReload(ctx)
+				if existed {
+					// Bring the previous version back up
+					e.systemd.controlService(ctx, unit, "restart")
+				}
+			}
+			result.Success = false
+			result.Error = fmt.Sprintf("unit failed to start: %v", err)
+			if st, statusErr := e.systemd.serviceStatus(ctx, unit); statusErr == nil {
+				result.Data["status"] = st
+			}
+			return result
+		}
+		result.Data["started"] = true
+	}
+
+	if st, err := e.systemd.serviceStatus(ctx, unit); err == nil {
+		result.Data["status"] = st
+	}
+	result.Success = true
+	return result
+}
+
+// removeUnit stops, disables and deletes a unit file (or one drop-in)
+func (e *Executor) removeUnit(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	if e.systemd == nil {
+		return failResult(result, "", unsupported(e.backend.name(), "unit files"))
+	}
+
+	unit, path, err := unitPaths(action)
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("unit file not found: %v", err)
+		return result
+	}
+
+	isDropIn := action.StringParam("dropin", "") != ""
+	if !isDropIn {
+		// Stop and disable first so no symlinks or running process are left behind
+		e.systemd.controlService(ctx, unit, "stop")
+		e.systemd.setBoot(ctx, unit, false)
+	}
+
+	if err := os.Remove(path); err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to remove unit file: %v", err)
+		return result
+	}
+	if isDropIn {
+		// Leave no empty <unit>.d directory behind
+		os.Remove(filepath.Dir(path))
+	}
+
+	if err := e.daemonReload(ctx); err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("daemon-reload failed: %v", err)
+		return result
+	}
+
+	logger.Info().
+		Str("unit", unit).
+		Str("path", path).
+		Msg("Unit file removed")
+
+	result.Data["unit"] = unit
+	result.Data["path"] = path
+	result.Success = true
+	return result
+}
+
+// catUnit returns the unit file and drop-ins systemd loaded for a unit
+func (e *Executor) catUnit(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	if e.systemd == nil {
+		return failResult(result, "", unsupported(e.backend.name(), "unit files"))
+	}
+
+	unit, err := unitParam(action)
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+
+	fragment, dropIns, err := e.systemd.unitFiles(ctx, unit)
+	if errors.Is(err, errBusUnavailable) {
+		output, err := exec.CommandContext(ctx, "systemctl", "cat", "--no-pager", unit).CombinedOutput()
+		result.Output = string(output)
+		if err != nil {
+			result.Success = false
+			result.Error = fmt.Sprintf("systemctl cat failed: %v", err)
+			return result
+		}
+		result.Success = true
+		return result
+	}
+	if err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to read unit: %v", err)
+		return result
+	}
+	if fragment == "" {
+		result.Success = false
+		result.Error = fmt.Sprintf("unit %s has no unit file", unit)
+		return result
+	}
+
+	files := make([]map[string]interface{}, 0, len(dropIns)+1)
+	for _, p := range append([]string{fragment}, dropIns...) {
+		data, err := os.ReadFile(p)
+		entry := map[string]interface{}{"path": p}
+		if err != nil {
+			entry["error"] = err.Error()
+		} else {
+			entry["content"] = string(data)
+		}
+		files = append(files, entry)
+	}
+
+	result.Data["unit"] = unit
+	result.Data["files"] = files
+	result.Success = true
+	return result
+}
+
+// maskControl masks or unmasks a unit
+func (e *Executor) maskControl(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	if e.systemd == nil {
+		return failResult(result, "", unsupported(e.backend.name(), "masking"))
+	}
+
+	unit, err := unitParam(action)
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+	mask := action.Type == "service_mask"
+
+	changes, err := e.systemd.setMasked(ctx, unit, mask)
+	if errors.Is(err, errBusUnavailable) {
+		op := "unmask"
+		if mask {
+			op = "mask"
+		}
+		output, cmdErr := exec.CommandContext(ctx, "systemctl", op, unit).CombinedOutput()
+		result.Output = string(output)
+		err = cmdErr
+		changes = nil
+	}
+	if err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to %s unit: %v", strings.TrimPrefix(action.Type, "service_"), err)
+		return result
+	}
+
+	result.Data["unit"] = unit
+	result.Data["changes"] = changes
+	result.Success = true
+	return result
+}
+
+// rollbackUnit restores the previous unit content, or removes a newly created file
+func (e *Executor) rollbackUnit(ctx context.Context, path string, previous []byte, existed bool, result *executor.Result) {
+	var err error
+	if existed {
+		err = fsutil.WriteFileAtomic(path, previous, 0644)
+	} else {
+		err = os.Remove(path)
+	}
+
+	if err != nil {
+		logger.Error().Err(err).Str("path", path).Msg("Failed to roll back unit file")
+		result.Data["rolled_back"] = false
+		return
+	}
+
+	logger.Warn().Str("path", path).Msg("Unit file rolled back")
+	result.Data["rolled_back"] = true
+}
+
+// verifyUnit runs systemd-analyze verify against the unit
+func verifyUnit(ctx context.Context, unit, path string) (string, error) {
+	if _, err := exec.LookPath("systemd-analyze"); err != nil {
+		// Nothing to validate with; daemon-reload will still reject bad syntax
+		return "", nil
+	}
+
+	// Drop-ins are verified through the unit they modify
+	target := path
+	if filepath.Base(filepath.Dir(path)) == unit+".d" {
+		target = unit
+	}
+
+	output, err := exec.CommandContext(ctx, "systemd-analyze", "verify", target).CombinedOutput()
+	if err != nil {
+		return string(output), fmt.Errorf("%s", strings.TrimSpace(string(output)))
+	}
+	return string(output), nil
+}
+
+// daemonReload reloads systemd, over D-Bus when possible
+func (e *Executor) daemonReload(ctx context.Context) error {
+	err := e.systemd.reload(ctx)
+	if errors.Is(err, errBusUnavailable) {
+		if output, err := exec.CommandContext(ctx, "systemctl", "daemon-reload").CombinedOutput(); err != nil {
+			return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(output)))
+		}
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"einfra/agent/internal/logger"
+
+	sddbus "github.com/coreos/go-systemd/v22/dbus"
+)
+
+// StateChange is an ActiveState transition observed for a watched service
+type StateChange struct {
+	Unit      string    `json:"unit"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	SubState  string    `json:"sub_state"`
+	Result    string    `json:"result,omitempty"`
+	ExitCode  int32     `json:"exit_code"`
+	MainPID   uint32    `json:"main_pid"`
+	Restarts  uint32    `json:"restart_count"`
+	Source    string    `json:"source"` // "signal" or "poll"
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Watch reports ActiveState transitions of the given services until ctx is
+// done. Every init system is polled each interval; on systemd it also
+// subscribes to PropertiesChanged signals when the system bus is available, so
+// a missed signal or a missing bus only delays detection
+func (e *Executor) Watch(ctx context.Context, services []string, interval time.Duration) (<-chan StateChange, error) {
+	if len(services) == 0 {
+		return nil, fmt.Errorf("no services to watch")
+	}
+
+	units := make(map[string]bool, len(services))
+	for _, s := range services {
+		if !validServiceName(s) {
+			return nil, fmt.Errorf("invalid service name: %s", s)
+		}
+		if e.systemd != nil {
+			s = unitName(s)
+		}
+		units[s] = true
+	}
+
+	out := make(chan StateChange, 64)
+	go e.watchLoop(ctx, units, interval, out)
+	return out, nil
+}
+
+// watchLoop tracks the last known state of each unit and emits transitions
+func (e *Executor) watchLoop(ctx context.Context, units map[string]bool, interval time.Duration, out chan<- StateChange) {
+	defer close(out)
+
+	last := make(map[string]string, len(units))
+	check := func(unit, source string) {
+		st, err := e.backend.serviceStatus(ctx, unit)
+		if err != nil {
+			logger.Debug().Err(err).Str("unit", unit).Msg("Failed to read unit state")
+			return
+		}
+
+		prev, known := last[unit]
+		last[unit] = st.ActiveState
+		// The first observation only establishes the baseline
+		if !known || prev == st.ActiveState {
+			return
+		}
+
+		change := StateChange{
+			Unit:      unit,
+			From:      prev,
+			To:        st.ActiveState,
+			SubState:  st.SubState,
+			Result:    st.Result,
+			ExitCode:  st.ExecMainStatus,
+			MainPID:   st.MainPID,
+			Restarts:  st.NRestarts,
+			Source:    source,
+			Timestamp: time.Now().UTC(),
+		}
+		select {
+		case out <- change:
+		case <-ctx.Done():
+		}
+	}
+
+	for unit := range units {
+		check(unit, "poll")
+	}
+
+	updates, closeSignals := e.subscribe(ctx)
+	defer closeSignals()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case update := <-updates:
+			if units[update.UnitName] {
+				if _, changed := update.Changed["ActiveState"]; changed {
+					check(update.UnitName, "signal")
+				}
+			}
+		case <-ticker.C:
+			for unit := range units {
+				check(unit, "poll")
+			}
+		}
+	}
+}
+
+// subscribe opens a dedicated bus connection for unit property signals. It
+// returns a nil channel when systemd or its bus is unavailable, which
+// disables that case
+func (e *Executor) subscribe(ctx context.Context) (<-chan *sddbus.PropertiesUpdate, func()) {
+	if e.systemd == nil {
+		return nil, func() {}
+	}
+
+	conn, err := sddbus.NewSystemConnectionContext(ctx)
+	if err != nil {
+		logger.Info().Err(err).Msg("systemd D-Bus unavailable, watching services by polling")
+		return nil, func() {}
+	}
+
+	if err := conn.Subscribe(); err != nil {
+		conn.Close()
+		logger.Warn().Err(err).Msg("Failed to subscribe to systemd signals, watching services by polling")
+		return nil, func() {}
+	}
+
+	updates := make(chan *sddbus.PropertiesUpdate, 256)
+	errs := make(chan error, 16)
+	conn.SetPropertiesSubscriber(updates, errs)
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case err := <-errs:
+				// A full channel means we dropped signals; the next poll catches up
+				if !errors.Is(err, context.Canceled) {
+					logger.Debug().Err(err).Msg("systemd signal subscription error")
+				}
+			}
+		}
+	}()
+
+	return updates, conn.Close
+}
@@ -0,0 +1,255 @@
+package system
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"syscall"
+
+	"einfra/agent/internal/executor"
+
+	"github.com/shirou/gopsutil/v3/net"
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// Listener is a socket accepting connections or datagrams
+type Listener struct {
+	Protocol string `json:"protocol"` // tcp, tcp6, udp or udp6
+	Address  string `json:"address"`
+	Port     uint32 `json:"port"`
+	// PID is 0 when the owning process cannot be resolved
+	PID     int32  `json:"pid"`
+	Process string `json:"process,omitempty"`
+	User    string `json:"user,omitempty"`
+}
+
+// Key identifies the listener across scans. The owning process is left out,
+// since it cannot always be resolved
+func (l Listener) Key() string {
+	return fmt.Sprintf("%s|%s|%d", l.Protocol, l.Address, l.Port)
+}
+
+// Ephemeral reports whether the listener is a UDP socket on a port the kernel
+// hands out to clients, such as a resolver or NTP query
+func (l Listener) Ephemeral() bool {
+	if !strings.HasPrefix(l.Protocol, "udp") {
+		return false
+	}
+	low, high := ephemeralPorts()
+	return l.Port >= low && l.Port <= high
+}
+
+// ephemeralPorts returns the local port range for outgoing connections,
+// falling back to the IANA dynamic range
+func ephemeralPorts() (uint32, uint32) {
+	if data, err := os.ReadFile("/proc/sys/net/ipv4/ip_local_port_range"); err == nil {
+		var low, high uint32
+		if n, _ := fmt.Sscan(string(data), &low, &high); n == 2 && low <= high {
+			return low, high
+		}
+	}
+	return 49152, 65535
+}
+
+// Connection is a socket with a remote peer
+type Connection struct {
+	Protocol      string `json:"protocol"`
+	LocalAddress  string `json:"local_address"`
+	LocalPort     uint32 `json:"local_port"`
+	RemoteAddress string `json:"remote_address"`
+	RemotePort    uint32 `json:"remote_port"`
+	// State is the TCP state; connected UDP sockets report NONE
+	State   string `json:"state"`
+	PID     int32  `json:"pid"`
+	Process string `json:"process,omitempty"`
+	User    string `json:"user,omitempty"`
+}
+
+// owner is the process holding a socket
+type owner struct {
+	name string
+	user string
+}
+
+// owners resolves PIDs to process names and users, once per PID
+type owners map[int32]owner
+
+// lookup returns the owner of pid, empty when it is unknown or gone
+func (o owners) lookup(ctx context.Context, pid int32) owner {
+	if pid <= 0 {
+		return owner{}
+	}
+	if cached, ok := o[pid]; ok {
+		return cached
+	}
+	var found owner
+	if p, err := process.NewProcessWithContext(ctx, pid); err == nil {
+		found.name, _ = p.NameWithContext(ctx)
+		found.user, _ = p.UsernameWithContext(ctx)
+	}
+	o[pid] = found
+	return found
+}
+
+// Listeners returns the TCP sockets in LISTEN state and the unconnected UDP
+// sockets, ordered by protocol, port and address
+func (e *Executor) Listeners(ctx context.Context) ([]Listener, error) {
+	conns, err := net.ConnectionsWithContext(ctx, "inet")
+	if err != nil {
+		return nil, err
+	}
+
+	resolve := owners{}
+	seen := make(map[string]bool)
+	listeners := []Listener{}
+	for _, c := range conns {
+		if !isListening(c) {
+			continue
+		}
+		o := resolve.lookup(ctx, c.Pid)
+		l := Listener{
+			Protocol: socketProtocol(c),
+			Address:  c.Laddr.IP,
+			Port:     c.Laddr.Port,
+			PID:      c.Pid,
+			Process:  o.name,
+			User:     o.user,
+		}
+		// Forked workers share their parent's listening socket
+		key := fmt.Sprintf("%s|%d|%s", l.Key(), l.PID, l.Process)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		listeners = append(listeners, l)
+	}
+
+	sort.Slice(listeners, func(i, j int) bool {
+		a, b := listeners[i], listeners[j]
+		if a.Protocol != b.Protocol {
+			return a.Protocol < b.Protocol
+		}
+		if a.Port != b.Port {
+			return a.Port < b.Port
+		}
+		if a.Address != b.Address {
+			return a.Address < b.Address
+		}
+		return a.PID < b.PID
+	})
+	return listeners, nil
+}
+
+// listListeners reports listening sockets, optionally only for 'protocol' (tcp or udp)
+func (e *Executor) listListeners(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	protocol := strings.ToLower(action.StringParam("protocol", ""))
+	if protocol != "" && protocol != "tcp" && protocol != "udp" {
+		result.Success = false
+		result.Error = "'protocol' must be tcp or udp"
+		return result
+	}
+
+	listeners, err := e.Listeners(ctx)
+	if err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to list sockets: %v", err)
+		return result
+	}
+	if protocol != "" {
+		filtered := listeners[:0]
+		for _, l := range listeners {
+			if strings.HasPrefix(l.Protocol, protocol) {
+				filtered = append(filtered, l)
+			}
+		}
+		listeners = filtered
+	}
+
+	result.Data["listeners"] = listeners
+	result.Data["count"] = len(listeners)
+	result.Success = true
+	return result
+}
+
+// listConnections reports sockets with a remote peer, ESTABLISHED by default.
+// 'state' selects another TCP state or "all", and 'pid' one process
+func (e *Executor) listConnections(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	state := strings.ToUpper(action.StringParam("state", "ESTABLISHED"))
+	var pid int32
+	if action.HasParam("pid") {
+		var err error
+		if pid, err = pidParam(action); err != nil {
+			result.Success = false
+			result.Error = err.Error()
+			return result
+		}
+	}
+
+	conns, err := net.ConnectionsPidWithContext(ctx, "inet", pid)
+	if err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to list sockets: %v", err)
+		return result
+	}
+
+	resolve := owners{}
+	connections := []Connection{}
+	for _, c := range conns {
+		if c.Raddr.Port == 0 {
+			continue
+		}
+		if state != "ALL" && c.Status != state {
+			continue
+		}
+		o := resolve.lookup(ctx, c.Pid)
+		connections = append(connections, Connection{
+			Protocol:      socketProtocol(c),
+			LocalAddress:  c.Laddr.IP,
+			LocalPort:     c.Laddr.Port,
+			RemoteAddress: c.Raddr.IP,
+			RemotePort:    c.Raddr.Port,
+			State:         c.Status,
+			PID:           c.Pid,
+			Process:       o.name,
+			User:          o.user,
+		})
+	}
+
+	sort.Slice(connections, func(i, j int) bool {
+		a, b := connections[i], connections[j]
+		if a.RemoteAddress != b.RemoteAddress {
+			return a.RemoteAddress < b.RemoteAddress
+		}
+		if a.RemotePort != b.RemotePort {
+			return a.RemotePort < b.RemotePort
+		}
+		return a.LocalPort < b.LocalPort
+	})
+
+	result.Data["connections"] = connections
+	result.Data["count"] = len(connections)
+	result.Success = true
+	return result
+}
+
+// isListening reports TCP sockets in LISTEN and UDP sockets without a peer
+func isListening(c net.ConnectionStat) bool {
+	if c.Type == syscall.SOCK_STREAM {
+		return c.Status == "LISTEN"
+	}
+	return c.Type == syscall.SOCK_DGRAM && c.Raddr.Port == 0
+}
+
+// socketProtocol names the socket's protocol and address family
+func socketProtocol(c net.ConnectionStat) string {
+	protocol := "tcp"
+	if c.Type == syscall.SOCK_DGRAM {
+		protocol = "udp"
+	}
+	if c.Family == syscall.AF_INET6 {
+		protocol += "6"
+	}
+	return protocol
+}
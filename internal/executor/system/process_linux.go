@@ -0,0 +1,51 @@
+//go:build linux
+
+package system
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// setNice sets the nice value of every thread: Linux keeps one per thread,
+// and setpriority on a PID only changes the main thread
+func setNice(pid int32, priority int) error {
+	tasks, err := os.ReadDir(fmt.Sprintf("/proc/%d/task", pid))
+	if err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		tid, err := strconv.Atoi(task.Name())
+		if err != nil {
+			continue
+		}
+		// Threads that exited since the listing are skipped
+		if err := syscall.Setpriority(syscall.PRIO_PROCESS, tid, priority); err != nil && err != syscall.ESRCH {
+			return err
+		}
+	}
+	return nil
+}
+
+// getNice returns the nice value of the main thread. getpriority(2) returns
+// 20 - nice on Linux, which gopsutil reports unconverted
+func getNice(pid int32) (int32, error) {
+	prio, err := syscall.Getpriority(syscall.PRIO_PROCESS, int(pid))
+	if err != nil {
+		return 0, err
+	}
+	return int32(20 - prio), nil
+}
+
+// processCgroup reads the process's cgroup membership lines, such as
+// "0::/system.slice/nginx.service"
+func processCgroup(pid int32) []string {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return nil
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
@@ -0,0 +1,273 @@
+package system
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"einfra/agent/internal/executor"
+
+	"github.com/shirou/gopsutil/v3/mem"
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+const (
+	// defaultCPUSample is the window process_list measures CPU usage over
+	defaultCPUSample = 500 * time.Millisecond
+	// maxCPUSample bounds the 'sample_ms' parameter
+	maxCPUSample = 5 * time.Second
+)
+
+// processSorts orders process_list entries; usage sorts put the largest first
+var processSorts = map[string]func(a, b *ProcessEntry) bool{
+	"cpu":   func(a, b *ProcessEntry) bool { return a.CPU > b.CPU },
+	"mem":   func(a, b *ProcessEntry) bool { return a.RSS > b.RSS },
+	"rss":   func(a, b *ProcessEntry) bool { return a.RSS > b.RSS },
+	"pid":   func(a, b *ProcessEntry) bool { return a.PID < b.PID },
+	"name":  func(a, b *ProcessEntry) bool { return strings.ToLower(a.Name) < strings.ToLower(b.Name) },
+	"start": func(a, b *ProcessEntry) bool { return a.created > b.created },
+}
+
+// ProcessEntry is one row of the process list
+type ProcessEntry struct {
+	PID     int32  `json:"pid"`
+	PPID    int32  `json:"ppid"`
+	User    string `json:"user"`
+	Name    string `json:"name"`
+	Command string `json:"command"`
+	State   string `json:"state,omitempty"`
+	// CPU is the percentage of one core used over the sampling window
+	CPU float64 `json:"cpu"`
+	// Mem is RSS as a percentage of physical memory
+	Mem       float64 `json:"mem"`
+	RSS       uint64  `json:"rss"`
+	VMS       uint64  `json:"vms"`
+	Threads   int32   `json:"threads"`
+	StartTime string  `json:"start_time,omitempty"`
+
+	created int64
+}
+
+// processFilter holds the process_list parameters
+type processFilter struct {
+	user   string
+	name   *regexp.Regexp
+	full   bool
+	minCPU float64
+	minMem float64
+	sortBy string
+	limit  int
+	sample time.Duration
+}
+
+// getProcesses lists running processes, filtered by 'user', a 'name' regex
+// ('full' matches the command line), 'min_cpu' and 'min_mem', ordered by
+// 'sort' and cut to the top 'limit'
+func (e *Executor) getProcesses(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	filter, err := processListFilter(action)
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+
+	procs, err := process.ProcessesWithContext(ctx)
+	if err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to list processes: %v", err)
+		return result
+	}
+
+	var candidates []*process.Process
+	var users []string
+	for _, p := range procs {
+		if filter.name != nil && !matchProcess(ctx, p, filter.name, filter.full) {
+			continue
+		}
+		username, _ := p.UsernameWithContext(ctx)
+		if filter.user != "" && !matchUser(username, filter.user) {
+			continue
+		}
+		candidates = append(candidates, p)
+		users = append(users, username)
+	}
+
+	cpu, err := sampleCPU(ctx, candidates, filter.sample)
+	if err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to sample CPU usage: %v", err)
+		return result
+	}
+
+	var totalMem uint64
+	if vm, err := mem.VirtualMemoryWithContext(ctx); err == nil {
+		totalMem = vm.Total
+	}
+
+	entries := make([]*ProcessEntry, 0, len(candidates))
+	for i, p := range candidates {
+		usage, ok := cpu[p.Pid]
+		if !ok {
+			// Exited during the sampling window
+			continue
+		}
+		entry := &ProcessEntry{PID: p.Pid, User: users[i], CPU: usage}
+		if m, err := p.MemoryInfoWithContext(ctx); err == nil {
+			entry.RSS, entry.VMS = m.RSS, m.VMS
+			if totalMem > 0 {
+				entry.Mem = float64(m.RSS) / float64(totalMem) * 100
+			}
+		}
+		if entry.CPU < filter.minCPU || entry.Mem < filter.minMem {
+			continue
+		}
+
+		entry.PPID, _ = p.PpidWithContext(ctx)
+		entry.Name, _ = p.NameWithContext(ctx)
+		entry.Command, _ = p.CmdlineWithContext(ctx)
+		if entry.Command == "" {
+			// Kernel threads have no command line
+			entry.Command = "[" + entry.Name + "]"
+		}
+		if status, err := p.StatusWithContext(ctx); err == nil && len(status) > 0 {
+			entry.State = status[0]
+		}
+		entry.Threads, _ = p.NumThreadsWithContext(ctx)
+		if created, err := p.CreateTimeWithContext(ctx); err == nil {
+			entry.created = created
+			entry.StartTime = time.UnixMilli(created).UTC().Format(time.RFC3339)
+		}
+		entries = append(entries, entry)
+	}
+
+	less := processSorts[filter.sortBy]
+	sort.SliceStable(entries, func(i, j int) bool { return less(entries[i], entries[j]) })
+
+	result.Data["total"] = len(entries)
+	if filter.limit > 0 && len(entries) > filter.limit {
+		entries = entries[:filter.limit]
+	}
+
+	result.Data["processes"] = entries
+	result.Data["sort"] = filter.sortBy
+	result.Data["sample_ms"] = filter.sample.Milliseconds()
+	result.Success = true
+	return result
+}
+
+// processListFilter validates the process_list parameters
+func processListFilter(action *executor.Action) (*processFilter, error) {
+	filter := &processFilter{
+		user:   action.StringParam("user", ""),
+		full:   action.BoolParam("full", false),
+		minCPU: action.FloatParam("min_cpu", 0),
+		minMem: action.FloatParam("min_mem", 0),
+		sortBy: strings.ToLower(action.StringParam("sort", "cpu")),
+		limit:  action.IntParam("limit", 0),
+		sample: defaultCPUSample,
+	}
+
+	if pattern := action.StringParam("name", ""); pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid name pattern: %v", err)
+		}
+		filter.name = re
+	}
+	if _, ok := processSorts[filter.sortBy]; !ok {
+		return nil, fmt.Errorf("unknown sort key %q", filter.sortBy)
+	}
+	if filter.limit < 0 {
+		return nil, fmt.Errorf("'limit' must not be negative")
+	}
+	if action.HasParam("sample_ms") {
+		filter.sample = time.Duration(action.IntParam("sample_ms", 0)) * time.Millisecond
+		if filter.sample < 0 || filter.sample > maxCPUSample {
+			return nil, fmt.Errorf("'sample_ms' must be between 0 and %d", maxCPUSample.Milliseconds())
+		}
+	}
+	return filter, nil
+}
+
+// matchProcess matches re against the process name, or its command line when full is set
+func matchProcess(ctx context.Context, p *process.Process, re *regexp.Regexp, full bool) bool {
+	var subject string
+	if full {
+		subject, _ = p.CmdlineWithContext(ctx)
+	} else {
+		subject, _ = p.NameWithContext(ctx)
+	}
+	return subject != "" && re.MatchString(subject)
+}
+
+// matchUser compares usernames, ignoring the DOMAIN\ prefix Windows reports
+func matchUser(username, want string) bool {
+	if username == want {
+		return true
+	}
+	if i := strings.LastIndex(username, `\`); i >= 0 {
+		return strings.EqualFold(username[i+1:], want) || strings.EqualFold(username, want)
+	}
+	return false
+}
+
+// sampleCPU measures each process's CPU usage over window, as a percentage
+// of one core. A zero window falls back to the average since the process
+// started. Processes that exit meanwhile are left out
+func sampleCPU(ctx context.Context, procs []*process.Process, window time.Duration) (map[int32]float64, error) {
+	usage := make(map[int32]float64, len(procs))
+	if window == 0 {
+		for _, p := range procs {
+			if running, err := p.IsRunningWithContext(ctx); err == nil && !running {
+				continue
+			}
+			usage[p.Pid], _ = p.CPUPercentWithContext(ctx)
+		}
+		return usage, nil
+	}
+
+	type sample struct {
+		busy    float64
+		created int64
+	}
+	before := make(map[int32]sample, len(procs))
+	for _, p := range procs {
+		if times, err := p.TimesWithContext(ctx); err == nil {
+			created, _ := p.CreateTimeWithContext(ctx)
+			before[p.Pid] = sample{busy: times.User + times.System, created: created}
+		}
+	}
+	start := time.Now()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(window):
+	}
+	elapsed := time.Since(start).Seconds()
+
+	for _, p := range procs {
+		times, err := p.TimesWithContext(ctx)
+		if err != nil {
+			if running, err := p.IsRunningWithContext(ctx); err == nil && running {
+				// CPU times are unreadable (protected process), not gone
+				usage[p.Pid] = 0
+			}
+			continue
+		}
+		prev, ok := before[p.Pid]
+		if created, _ := p.CreateTimeWithContext(ctx); !ok || created != prev.created {
+			// Started during the window, possibly reusing the PID
+			continue
+		}
+		if busy := times.User + times.System - prev.busy; busy > 0 {
+			usage[p.Pid] = busy / elapsed * 100
+		} else {
+			usage[p.Pid] = 0
+		}
+	}
+	return usage, nil
+}
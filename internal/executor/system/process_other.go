@@ -0,0 +1,28 @@
+//go:build !linux
+
+package system
+
+import (
+	"runtime"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// setNice is only implemented for Linux
+func setNice(pid int32, priority int) error {
+	return unsupported(runtime.GOOS, "renice")
+}
+
+// getNice returns what gopsutil reports as the process priority
+func getNice(pid int32) (int32, error) {
+	p, err := process.NewProcess(pid)
+	if err != nil {
+		return 0, err
+	}
+	return p.Nice()
+}
+
+// processCgroup is empty outside Linux, which has no cgroups
+func processCgroup(pid int32) []string {
+	return nil
+}
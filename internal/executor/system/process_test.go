@@ -0,0 +1,100 @@
+//go:build !windows
+
+package system
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"syscall"
+	"testing"
+	"time"
+
+	"einfra/agent/internal/executor"
+)
+
+// startSleepers runs n sleep processes sharing a command line unique to this test
+func startSleepers(t *testing.T, n int) (string, []*exec.Cmd) {
+	t.Helper()
+	arg := fmt.Sprintf("3%d.%d", os.Getpid(), time.Now().UnixNano()%1000000)
+	var cmds []*exec.Cmd
+	for i := 0; i < n; i++ {
+		cmd := exec.Command("sleep", arg)
+		if err := cmd.Start(); err != nil {
+			t.Skipf("cannot start sleep: %v", err)
+		}
+		cmds = append(cmds, cmd)
+		go cmd.Wait()
+	}
+	t.Cleanup(func() {
+		for _, cmd := range cmds {
+			cmd.Process.Kill()
+		}
+	})
+	return "^sleep " + regexp.QuoteMeta(arg) + "$", cmds
+}
+
+// running reports whether the process has not exited yet
+func running(cmd *exec.Cmd) bool {
+	return cmd.Process.Signal(syscall.Signal(0)) == nil
+}
+
+func TestKillProcessMaxMatches(t *testing.T) {
+	pattern, cmds := startSleepers(t, 2)
+	e := NewExecutor()
+	kill := func(params map[string]interface{}) *executor.Result {
+		params["pattern"] = pattern
+		params["full"] = true
+		return e.Execute(context.Background(), &executor.Action{Type: "process_kill", Params: params})
+	}
+
+	if result := kill(map[string]interface{}{}); result.Success {
+		t.Fatal("process_kill with two matches succeeded without 'all'")
+	} else if got := len(result.Data["processes"].([]*KillResult)); got != 2 {
+		t.Errorf("reported %d matches, want 2", got)
+	}
+	if result := kill(map[string]interface{}{"max_matches": float64(0)}); result.Success {
+		t.Error("max_matches 0 accepted")
+	}
+	if result := kill(map[string]interface{}{"all": true, "dry_run": true}); !result.Success {
+		t.Errorf("dry_run failed: %s", result.Error)
+	}
+	for _, cmd := range cmds {
+		if !running(cmd) {
+			t.Fatalf("PID %d was signalled before 'all' was set", cmd.Process.Pid)
+		}
+	}
+
+	if result := kill(map[string]interface{}{"max_matches": float64(2)}); !result.Success {
+		t.Fatalf("process_kill with max_matches 2 failed: %s", result.Error)
+	}
+	time.Sleep(100 * time.Millisecond)
+	for _, cmd := range cmds {
+		if running(cmd) {
+			t.Errorf("PID %d still running", cmd.Process.Pid)
+		}
+	}
+}
+
+func TestParseSignal(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		err  bool
+	}{
+		{in: "TERM", want: "TERM"},
+		{in: "sigkill", want: "KILL"},
+		{in: " hup ", want: "HUP"},
+		{in: "9", want: "KILL"},
+		{in: "BOGUS", err: true},
+		{in: "999", err: true},
+	}
+	for _, tt := range tests {
+		_, name, err := parseSignal(tt.in)
+		if tt.err != (err != nil) || name != tt.want {
+			t.Errorf("parseSignal(%q) = %q, %v; want %q, error %v", tt.in, name, err, tt.want, tt.err)
+		}
+	}
+}
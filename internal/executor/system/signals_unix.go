@@ -0,0 +1,31 @@
+//go:build !windows
+
+package system
+
+import (
+	"context"
+	"syscall"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// killSignal is sent when a process outlives its timeout and 'escalate' is set
+const killSignal = syscall.SIGKILL
+
+// signals are the signals process_kill accepts, by name without SIG
+var signals = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"KILL": syscall.SIGKILL,
+	"TERM": syscall.SIGTERM,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+	"STOP": syscall.SIGSTOP,
+	"CONT": syscall.SIGCONT,
+}
+
+// sendSignal delivers sig to the process
+func sendSignal(ctx context.Context, p *process.Process, sig syscall.Signal) error {
+	return p.SendSignalWithContext(ctx, sig)
+}
@@ -0,0 +1,28 @@
+//go:build windows
+
+package system
+
+import (
+	"context"
+	"syscall"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// killSignal is sent when a process outlives its timeout and 'escalate' is set
+const killSignal = syscall.SIGKILL
+
+// signals are the names process_kill accepts; Windows can only terminate
+var signals = map[string]syscall.Signal{
+	"KILL": syscall.SIGKILL,
+	"TERM": syscall.SIGTERM,
+}
+
+// sendSignal terminates the process for TERM and KILL, the only signals
+// Windows can deliver to another process
+func sendSignal(ctx context.Context, p *process.Process, sig syscall.Signal) error {
+	if sig != syscall.SIGTERM && sig != syscall.SIGKILL {
+		return unsupported("windows", "signal "+sig.String())
+	}
+	return p.KillWithContext(ctx)
+}
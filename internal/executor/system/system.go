@@ -2,12 +2,8 @@ package system
 
 import (
 	"context"
-	"fmt"
 	"os"
-	"os/exec"
 	"runtime"
-	"strconv"
-	"strings"
 
 	"einfra/agent/internal/executor"
 
@@ -32,6 +28,12 @@ func (e *Executor) SupportedActions() []string {
 		"system_info",
 		"system_metrics",
 		"process_list",
+		"process_kill",
+		"process_renice",
+		"process_tree",
+		"process_info",
+		"net_listeners",
+		"net_connections",
 	}
 }
 
@@ -48,7 +50,19 @@ func (e *Executor) Execute(ctx context.Context, action *executor.Action) *execut
 	case "system_metrics":
 		return e.getMetrics(ctx, result)
 	case "process_list":
-		return e.getProcesses(ctx, result)
+		return e.getProcesses(ctx, action, result)
+	case "process_kill":
+		return e.killProcess(ctx, action, result)
+	case "process_renice":
+		return e.reniceProcess(ctx, action, result)
+	case "process_tree":
+		return e.processTree(ctx, action, result)
+	case "process_info":
+		return e.processInfo(ctx, action, result)
+	case "net_listeners":
+		return e.listListeners(ctx, action, result)
+	case "net_connections":
+		return e.listConnections(ctx, action, result)
 	default:
 		result.Success = false
 		result.Error = "unknown system action"
@@ -103,54 +117,3 @@ func (e *Executor) getMetrics(ctx context.Context, result *executor.Result) *exe
 	result.Success = true
 	return result
 }
-
-// getProcesses lists running processes
-func (e *Executor) getProcesses(ctx context.Context, result *executor.Result) *executor.Result {
-	var cmd *exec.Cmd
-
-	if runtime.GOOS == "linux" {
-		cmd = exec.CommandContext(ctx, "ps", "aux", "--sort=-%cpu", "--no-headers")
-	} else if runtime.GOOS == "windows" {
-		cmd = exec.CommandContext(ctx, "powershell", "-Command",
-			"Get-Process | Sort-Object CPU -Descending | Select-Object -First 50 | ConvertTo-Json")
-	}
-
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		result.Success = false
-		result.Error = fmt.Sprintf("failed to get processes: %v", err)
-		return result
-	}
-
-	// Parse process list
-	processes := parseProcessList(string(output))
-	result.Data["processes"] = processes
-	result.Success = true
-	return result
-}
-
-// parseProcessList parses ps output into structured data
-func parseProcessList(output string) []map[string]interface{} {
-	lines := strings.Split(strings.TrimSpace(output), "\n")
-	processes := make([]map[string]interface{}, 0, len(lines))
-
-	for _, line := range lines {
-		fields := strings.Fields(line)
-		if len(fields) < 11 {
-			continue
-		}
-
-		cpu, _ := strconv.ParseFloat(fields[2], 64)
-		mem, _ := strconv.ParseFloat(fields[3], 64)
-
-		processes = append(processes, map[string]interface{}{
-			"user":    fields[0],
-			"pid":     fields[1],
-			"cpu":     cpu,
-			"mem":     mem,
-			"command": strings.Join(fields[10:], " "),
-		})
-	}
-
-	return processes
-}
@@ -0,0 +1,220 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"einfra/agent/internal/executor"
+)
+
+var (
+	// validName follows shadow-utils' portable user and group names, plus the
+	// trailing $ of Samba machine accounts
+	validName = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,31}\$?$`)
+	// validCryptHash accepts modular crypt strings: $id$[params$]salt$hash
+	validCryptHash = regexp.MustCompile(`^\$[0-9a-z]+\$[./A-Za-z0-9$=,]+$`)
+)
+
+// Account is one passwd entry
+type Account struct {
+	Username string `json:"username"`
+	UID      int    `json:"uid"`
+	GID      int    `json:"gid"`
+	Comment  string `json:"comment,omitempty"`
+	Home     string `json:"home"`
+	Shell    string `json:"shell"`
+}
+
+// unsupportedError reports an option the platform has no equivalent for
+type unsupportedError struct {
+	platform  string
+	operation string
+}
+
+func (e *unsupportedError) Error() string {
+	return fmt.Sprintf("unsupported: %s has no equivalent for %s", e.platform, e.operation)
+}
+
+// unsupported builds an unsupportedError
+func unsupported(platform, operation string) error {
+	return &unsupportedError{platform: platform, operation: operation}
+}
+
+// failResult reports err on result, flagging options the platform cannot apply
+func failResult(result *executor.Result, prefix string, err error) *executor.Result {
+	result.Success = false
+
+	var u *unsupportedError
+	if errors.As(err, &u) {
+		result.Error = err.Error()
+		result.Data["unsupported"] = true
+		return result
+	}
+
+	result.Error = fmt.Sprintf("%s: %v", prefix, err)
+	return result
+}
+
+// rejectParams fails with an unsupported error for the first of keys the
+// action sets
+func rejectParams(action *executor.Action, platform string, keys ...string) error {
+	for _, key := range keys {
+		if action.HasParam(key) {
+			return unsupported(platform, "'"+key+"'")
+		}
+	}
+	return nil
+}
+
+// nameParam reads and validates a user or group name
+func nameParam(action *executor.Action, key string) (string, error) {
+	name, ok := action.Params[key].(string)
+	if !ok {
+		return "", fmt.Errorf("missing '%s' parameter", key)
+	}
+	if !validName.MatchString(name) {
+		return "", fmt.Errorf("invalid %s: %s", key, name)
+	}
+	return name, nil
+}
+
+// namesParam reads and validates a list of user or group names
+func namesParam(action *executor.Action, key string) ([]string, error) {
+	names := action.StringsParam(key)
+	for _, name := range names {
+		if !validName.MatchString(name) {
+			return nil, fmt.Errorf("invalid name in '%s': %s", key, name)
+		}
+	}
+	return names, nil
+}
+
+// linesParam reads a list of strings, or one newline separated string. Unlike
+// StringsParam it never splits on commas, which entries may contain
+func linesParam(action *executor.Action, key string) ([]string, error) {
+	var lines []string
+	switch v := action.Params[key].(type) {
+	case string:
+		lines = strings.Split(v, "\n")
+	case []interface{}:
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("'%s' must be a list of strings", key)
+			}
+			lines = append(lines, s)
+		}
+	case []string:
+		lines = v
+	case nil:
+	default:
+		return nil, fmt.Errorf("'%s' must be a string or a list of strings", key)
+	}
+	return lines, nil
+}
+
+// fieldParam reads an optional passwd field, which must not contain the
+// ':' separator or a newline
+func fieldParam(action *executor.Action, key string) (string, error) {
+	value := action.StringParam(key, "")
+	if strings.ContainsAny(value, ":\r\n") {
+		return "", fmt.Errorf("'%s' must not contain ':' or line breaks", key)
+	}
+	return value, nil
+}
+
+// pathParam reads an optional absolute path such as a home directory or shell
+func pathParam(action *executor.Action, key string) (string, error) {
+	value, err := fieldParam(action, key)
+	if err != nil || value == "" {
+		return value, err
+	}
+	if !filepath.IsAbs(value) {
+		return "", fmt.Errorf("'%s' must be an absolute path", key)
+	}
+	return filepath.Clean(value), nil
+}
+
+// idParam reads an optional non-negative uid or gid; ok is false when unset
+func idParam(action *executor.Action, key string) (int, bool, error) {
+	if !action.HasParam(key) {
+		return 0, false, nil
+	}
+	id := action.IntParam(key, -1)
+	if id < 0 {
+		return 0, false, fmt.Errorf("'%s' must be a non-negative number", key)
+	}
+	return id, true, nil
+}
+
+// run runs a command and returns its combined output, folding the tail of
+// the output into the error on failure
+func run(ctx context.Context, name string, args ...string) (string, error) {
+	return runInput(ctx, "", name, args...)
+}
+
+// runInput runs a command with input on stdin, so secrets stay out of the
+// process list
+func runInput(ctx context.Context, input, name string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	if input != "" {
+		cmd.Stdin = strings.NewReader(input)
+	}
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return string(output), fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return string(output), nil
+}
+
+// powershell runs a PowerShell command
+func powershell(ctx context.Context, command string) (string, error) {
+	return run(ctx, "powershell", "-NoProfile", "-NonInteractive", "-Command", command)
+}
+
+// psQuote quotes a string as a PowerShell single-quoted literal
+func psQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// lookupAccount reads one passwd entry through NSS
+func lookupAccount(ctx context.Context, username string) (*Account, error) {
+	output, err := exec.CommandContext(ctx, "getent", "passwd", username).Output()
+	if err != nil {
+		return nil, fmt.Errorf("user %s not found", username)
+	}
+	account, ok := parsePasswdLine(strings.TrimSpace(string(output)))
+	if !ok {
+		return nil, fmt.Errorf("unexpected passwd entry for %s", username)
+	}
+	return account, nil
+}
+
+// parsePasswdLine parses "name:x:uid:gid:comment:home:shell"
+func parsePasswdLine(line string) (*Account, bool) {
+	parts := strings.Split(line, ":")
+	if len(parts) < 7 {
+		return nil, false
+	}
+	uid, err1 := strconv.Atoi(parts[2])
+	gid, err2 := strconv.Atoi(parts[3])
+	if err1 != nil || err2 != nil {
+		return nil, false
+	}
+	return &Account{
+		Username: parts[0],
+		UID:      uid,
+		GID:      gid,
+		Comment:  parts[4],
+		Home:     parts[5],
+		Shell:    parts[6],
+	}, true
+}
@@ -0,0 +1,451 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"einfra/agent/internal/executor"
+)
+
+// windowsGroupQuery renders local groups with their members as JSON
+const windowsGroupQuery = "Select-Object Name,Description,@{n='SID';e={$_.SID.Value}}," +
+	"@{n='Members';e={@(Get-LocalGroupMember -Group $_.Name | ForEach-Object { $_.Name })}} | ConvertTo-Json -Depth 3"
+
+// Group is one group entry. Members lists supplementary members only: users
+// whose primary group it is are not repeated in /etc/group
+type Group struct {
+	Name string `json:"name"`
+	// GID is the numeric gid, or the SID on Windows
+	GID         string   `json:"gid"`
+	Description string   `json:"description,omitempty"`
+	Members     []string `json:"members"`
+}
+
+// listGroups lists all groups with their members
+func (e *Executor) listGroups(ctx context.Context, result *executor.Result) *executor.Result {
+	var groups []Group
+	var err error
+	switch runtime.GOOS {
+	case "linux":
+		var output []byte
+		if output, err = exec.CommandContext(ctx, "getent", "group").Output(); err == nil {
+			groups = parseGroups(string(output))
+		}
+	case "windows":
+		groups, err = windowsGroups(ctx, "Get-LocalGroup")
+	default:
+		return failResult(result, "", unsupported(runtime.GOOS, "user management"))
+	}
+	if err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to list groups: %v", err)
+		return result
+	}
+
+	result.Data["groups"] = groups
+	result.Success = true
+	return result
+}
+
+// addGroup creates a group with an optional gid and initial members
+func (e *Executor) addGroup(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	name, err := nameParam(action, "group")
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+	gid, hasGID, err := idParam(action, "gid")
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+	members, err := namesParam(action, "members")
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+	description, err := fieldParam(action, "description")
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+
+	var output string
+	switch runtime.GOOS {
+	case "linux":
+		if err := rejectParams(action, "linux", "description"); err != nil {
+			return failResult(result, "", err)
+		}
+		var args []string
+		if hasGID {
+			args = append(args, "-g", strconv.Itoa(gid))
+		}
+		if action.BoolParam("system", false) {
+			args = append(args, "-r")
+		}
+		if output, err = run(ctx, "groupadd", append(args, name)...); err != nil {
+			break
+		}
+		if len(members) > 0 {
+			var more string
+			more, err = run(ctx, "gpasswd", "-M", strings.Join(members, ","), name)
+			output += more
+			if err != nil {
+				result.Output = output
+				result.Success = false
+				result.Error = fmt.Sprintf("group created but adding members failed: %v", err)
+				return result
+			}
+		}
+	case "windows":
+		if err := rejectParams(action, "windows", "gid", "system"); err != nil {
+			return failResult(result, "", err)
+		}
+		command := fmt.Sprintf("New-LocalGroup -Name '%s'", name)
+		if description != "" {
+			command += " -Description " + psQuote(description)
+		}
+		if len(members) > 0 {
+			command += fmt.Sprintf("; Add-LocalGroupMember -Group '%s' -Member %s", name, psList(members))
+		}
+		output, err = powershell(ctx, command)
+	default:
+		return failResult(result, "", unsupported(runtime.GOOS, "user management"))
+	}
+
+	result.Output = output
+	if err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to add group: %v", err)
+		return result
+	}
+
+	e.reportGroup(ctx, name, result)
+	result.Success = true
+	return result
+}
+
+// deleteGroup removes a group
+func (e *Executor) deleteGroup(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	name, err := nameParam(action, "group")
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+
+	var output string
+	switch runtime.GOOS {
+	case "linux":
+		output, err = run(ctx, "groupdel", name)
+	case "windows":
+		output, err = powershell(ctx, fmt.Sprintf("Remove-LocalGroup -Name '%s'", name))
+	default:
+		return failResult(result, "", unsupported(runtime.GOOS, "user management"))
+	}
+
+	result.Output = output
+	if err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to delete group: %v", err)
+		return result
+	}
+
+	result.Data["group"] = map[string]interface{}{"name": name}
+	result.Success = true
+	return result
+}
+
+// modifyGroup renames a group, changes its gid or description, or replaces
+// its member list
+func (e *Executor) modifyGroup(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	name, err := nameParam(action, "group")
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+	gid, hasGID, err := idParam(action, "gid")
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+	newName := ""
+	if action.HasParam("new_name") {
+		if newName, err = nameParam(action, "new_name"); err != nil {
+			result.Success = false
+			result.Error = err.Error()
+			return result
+		}
+	}
+	// An empty list removes every member, so "members" is only applied when set
+	members, err := namesParam(action, "members")
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+	setMembers := action.HasParam("members")
+	description, err := fieldParam(action, "description")
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+
+	var output string
+	switch runtime.GOOS {
+	case "linux":
+		if err := rejectParams(action, "linux", "description"); err != nil {
+			return failResult(result, "", err)
+		}
+		if !hasGID && newName == "" && !setMembers {
+			result.Success = false
+			result.Error = "no changes requested"
+			return result
+		}
+		if setMembers {
+			if output, err = run(ctx, "gpasswd", "-M", strings.Join(members, ","), name); err != nil {
+				break
+			}
+		}
+		var args []string
+		if hasGID {
+			args = append(args, "-g", strconv.Itoa(gid))
+		}
+		if newName != "" {
+			args = append(args, "-n", newName)
+		}
+		if len(args) > 0 {
+			var more string
+			more, err = run(ctx, "groupmod", append(args, name)...)
+			output += more
+		}
+	case "windows":
+		if err := rejectParams(action, "windows", "gid"); err != nil {
+			return failResult(result, "", err)
+		}
+		var commands []string
+		if action.HasParam("description") {
+			commands = append(commands, fmt.Sprintf("Set-LocalGroup -Name '%s' -Description %s", name, psQuote(description)))
+		}
+		if setMembers {
+			commands = append(commands, fmt.Sprintf("Get-LocalGroupMember -Group '%s' | ForEach-Object { Remove-LocalGroupMember -Group '%s' -Member $_ }", name, name))
+			if len(members) > 0 {
+				commands = append(commands, fmt.Sprintf("Add-LocalGroupMember -Group '%s' -Member %s", name, psList(members)))
+			}
+		}
+		if newName != "" {
+			commands = append(commands, fmt.Sprintf("Rename-LocalGroup -Name '%s' -NewName '%s'", name, newName))
+		}
+		if len(commands) == 0 {
+			result.Success = false
+			result.Error = "no changes requested"
+			return result
+		}
+		output, err = powershell(ctx, "$ErrorActionPreference='Stop'; "+strings.Join(commands, "; "))
+	default:
+		return failResult(result, "", unsupported(runtime.GOOS, "user management"))
+	}
+
+	result.Output = output
+	if err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to modify group: %v", err)
+		return result
+	}
+
+	if newName != "" {
+		name = newName
+	}
+	e.reportGroup(ctx, name, result)
+	result.Success = true
+	return result
+}
+
+// changeMembers adds users to or removes them from a group. Users already in
+// the requested state are skipped and reported as unchanged
+func (e *Executor) changeMembers(ctx context.Context, action *executor.Action, result *executor.Result, add bool) *executor.Result {
+	name, err := nameParam(action, "group")
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+	users, err := namesParam(action, "users")
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+	if len(users) == 0 {
+		result.Success = false
+		result.Error = "missing 'users' parameter"
+		return result
+	}
+
+	verb := "remove"
+	if add {
+		verb = "add"
+	}
+
+	changed, unchanged := []string{}, []string{}
+	var output string
+	switch runtime.GOOS {
+	case "linux":
+		group, err := lookupGroup(ctx, name)
+		if err != nil {
+			result.Success = false
+			result.Error = err.Error()
+			return result
+		}
+		current := make(map[string]bool, len(group.Members))
+		for _, member := range group.Members {
+			current[member] = true
+		}
+
+		flag := "-d"
+		if add {
+			flag = "-a"
+		}
+		for _, user := range users {
+			if current[user] == add {
+				unchanged = append(unchanged, user)
+				continue
+			}
+			more, err := run(ctx, "gpasswd", flag, user, name)
+			output += more
+			if err != nil {
+				result.Output = output
+				result.Data["changed"] = changed
+				result.Success = false
+				result.Error = fmt.Sprintf("failed to %s %s: %v", verb, user, err)
+				return result
+			}
+			changed = append(changed, user)
+		}
+	case "windows":
+		cmdlet := "Remove-LocalGroupMember"
+		if add {
+			cmdlet = "Add-LocalGroupMember"
+		}
+		output, err = powershell(ctx, fmt.Sprintf("%s -Group '%s' -Member %s", cmdlet, name, psList(users)))
+		if err != nil {
+			result.Output = output
+			result.Success = false
+			result.Error = fmt.Sprintf("failed to %s members: %v", verb, err)
+			return result
+		}
+		changed = users
+	default:
+		return failResult(result, "", unsupported(runtime.GOOS, "user management"))
+	}
+
+	result.Output = output
+	result.Data["changed"] = changed
+	result.Data["unchanged"] = unchanged
+	e.reportGroup(ctx, name, result)
+	result.Success = true
+	return result
+}
+
+// reportGroup adds the group's current entry to the result, when it can be read
+func (e *Executor) reportGroup(ctx context.Context, name string, result *executor.Result) {
+	result.Data["group"] = map[string]interface{}{"name": name}
+	if group, err := lookupGroup(ctx, name); err == nil {
+		result.Data["group"] = group
+	}
+}
+
+// lookupGroup reads one group entry
+func lookupGroup(ctx context.Context, name string) (*Group, error) {
+	if runtime.GOOS == "windows" {
+		groups, err := windowsGroups(ctx, fmt.Sprintf("Get-LocalGroup -Name '%s'", name))
+		if err != nil || len(groups) == 0 {
+			return nil, fmt.Errorf("group %s not found", name)
+		}
+		return &groups[0], nil
+	}
+
+	output, err := exec.CommandContext(ctx, "getent", "group", name).Output()
+	if err != nil {
+		return nil, fmt.Errorf("group %s not found", name)
+	}
+	groups := parseGroups(string(output))
+	if len(groups) == 0 {
+		return nil, fmt.Errorf("unexpected group entry for %s", name)
+	}
+	return &groups[0], nil
+}
+
+// parseGroups parses "name:x:gid:member,member" lines
+func parseGroups(output string) []Group {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	groups := make([]Group, 0, len(lines))
+
+	for _, line := range lines {
+		parts := strings.Split(line, ":")
+		if len(parts) < 3 {
+			continue
+		}
+		group := Group{Name: parts[0], GID: parts[2], Members: []string{}}
+		if len(parts) >= 4 && parts[3] != "" {
+			group.Members = strings.Split(parts[3], ",")
+		}
+		groups = append(groups, group)
+	}
+
+	return groups
+}
+
+// windowsGroups runs a Get-LocalGroup query and parses the groups it returns
+func windowsGroups(ctx context.Context, query string) ([]Group, error) {
+	output, err := powershell(ctx, query+" | "+windowsGroupQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []struct {
+		Name        string   `json:"Name"`
+		Description string   `json:"Description"`
+		SID         string   `json:"SID"`
+		Members     []string `json:"Members"`
+	}
+	output = strings.TrimSpace(output)
+	if !strings.HasPrefix(output, "[") {
+		// A single group is rendered as an object
+		output = "[" + output + "]"
+	}
+	if err := json.Unmarshal([]byte(output), &records); err != nil {
+		return nil, fmt.Errorf("failed to parse Get-LocalGroup output: %v", err)
+	}
+
+	groups := make([]Group, 0, len(records))
+	for _, r := range records {
+		members := r.Members
+		if members == nil {
+			members = []string{}
+		}
+		groups = append(groups, Group{Name: r.Name, GID: r.SID, Description: r.Description, Members: members})
+	}
+	return groups, nil
+}
+
+// psList renders names as a PowerShell array of single-quoted literals
+func psList(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = psQuote(name)
+	}
+	return "@(" + strings.Join(quoted, ",") + ")"
+}
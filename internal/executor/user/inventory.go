@@ -0,0 +1,430 @@
+package user
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"einfra/agent/internal/executor"
+	"einfra/agent/internal/inventory"
+)
+
+const (
+	loginDefsPath = "/etc/login.defs"
+	lastlogPath   = "/var/log/lastlog"
+	wtmpPath      = "/var/log/wtmp"
+
+	// lastlogSize is struct lastlog: int32 time, char line[32], char host[256]
+	lastlogSize = 292
+	// utmpSize is glibc's struct utmp on 64-bit Linux, which keeps 32-bit times
+	utmpSize = 384
+	// utmpUserProcess is ut_type for a login session
+	utmpUserProcess = 7
+)
+
+// adminGroups grant sudo, or administrator rights on Windows, through membership
+var adminGroups = map[string]bool{"sudo": true, "wheel": true, "admin": true, "Administrators": true}
+
+// UserRecord is one account in the user inventory
+type UserRecord struct {
+	Username string `json:"username"`
+	// UID and GID are empty on Windows, which identifies accounts by SID
+	UID          *int     `json:"uid,omitempty"`
+	GID          *int     `json:"gid,omitempty"`
+	SID          string   `json:"sid,omitempty"`
+	PrimaryGroup string   `json:"primary_group,omitempty"`
+	Groups       []string `json:"groups"`
+	Comment      string   `json:"comment,omitempty"`
+	Home         string   `json:"home,omitempty"`
+	Shell        string   `json:"shell,omitempty"`
+	// System accounts have a uid outside login.defs' UID_MIN..UID_MAX, like nobody
+	System          bool   `json:"system"`
+	Locked          bool   `json:"locked"`
+	Expired         bool   `json:"expired"`
+	PasswordExpired bool   `json:"password_expired"`
+	PasswordChanged string `json:"password_changed,omitempty"`
+	// LastLogin is RFC 3339; empty when the user never logged in
+	LastLogin     string `json:"last_login,omitempty"`
+	LastLoginFrom string `json:"last_login_from,omitempty"`
+	Sudo          bool   `json:"sudo"`
+	// SudoVia names what grants sudo: "group:wheel" or a sudoers file
+	SudoVia            []string `json:"sudo_via,omitempty"`
+	AuthorizedKeys     bool     `json:"authorized_keys"`
+	AuthorizedKeyCount int      `json:"authorized_key_count"`
+}
+
+// UserDelta lists accounts added, removed or changed between two inventories
+type UserDelta = inventory.Delta[UserRecord]
+
+// lastLogin is the most recent login recorded for a user
+type lastLogin struct {
+	time time.Time
+	from string
+}
+
+// inventoryUsers lists every account as a structured record
+func (e *Executor) inventoryUsers(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	records, err := e.Inventory(ctx)
+	if err != nil {
+		return failResult(result, "failed to list users", err)
+	}
+
+	if !action.BoolParam("include_system", true) {
+		filtered := make([]UserRecord, 0, len(records))
+		for _, r := range records {
+			if !r.System {
+				filtered = append(filtered, r)
+			}
+		}
+		records = filtered
+	}
+
+	result.Data["users"] = records
+	result.Data["count"] = len(records)
+	result.Success = true
+	return result
+}
+
+// Inventory reads every account with its status, last login and privileges,
+// sorted by username
+func (e *Executor) Inventory(ctx context.Context) ([]UserRecord, error) {
+	var records []UserRecord
+	var err error
+	switch runtime.GOOS {
+	case "linux":
+		records, err = linuxInventory(ctx, time.Now())
+	case "windows":
+		records, err = windowsInventory(ctx)
+	default:
+		return nil, unsupported(runtime.GOOS, "user management")
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Username < records[j].Username })
+	return records, nil
+}
+
+// linuxInventory joins passwd, group, shadow, the login records, sudoers
+// and each home's authorized_keys. Only passwd is required; the rest fill in
+// what they can
+func linuxInventory(ctx context.Context, now time.Time) ([]UserRecord, error) {
+	output, err := exec.CommandContext(ctx, "getent", "passwd").Output()
+	if err != nil {
+		return nil, fmt.Errorf("getent passwd: %v", err)
+	}
+
+	groupNames := make(map[int]string)
+	memberOf := make(map[string][]string)
+	if output, err := exec.CommandContext(ctx, "getent", "group").Output(); err == nil {
+		for _, group := range parseGroups(string(output)) {
+			if gid, err := strconv.Atoi(group.GID); err == nil {
+				groupNames[gid] = group.Name
+			}
+			for _, member := range group.Members {
+				memberOf[member] = append(memberOf[member], group.Name)
+			}
+		}
+	}
+
+	shadow, _ := readShadow(now)
+	sudoers, _ := readSudoersFiles()
+	logins := readWtmp(wtmpPath)
+	uidMin := loginDefsInt("UID_MIN", 1000)
+	uidMax := loginDefsInt("UID_MAX", 60000)
+
+	lastlog, _ := os.Open(lastlogPath)
+	if lastlog != nil {
+		defer lastlog.Close()
+	}
+
+	var records []UserRecord
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		account, ok := parsePasswdLine(line)
+		if !ok {
+			continue
+		}
+		uid, gid := account.UID, account.GID
+		r := UserRecord{
+			Username:     account.Username,
+			UID:          &uid,
+			GID:          &gid,
+			PrimaryGroup: groupNames[gid],
+			Groups:       memberOf[account.Username],
+			Comment:      account.Comment,
+			Home:         account.Home,
+			Shell:        account.Shell,
+			System:       uid < uidMin || uid > uidMax,
+		}
+		if r.Groups == nil {
+			r.Groups = []string{}
+		}
+
+		if aging, ok := shadow[account.Username]; ok {
+			r.Locked = aging.Locked
+			r.Expired = aging.AccountExpired
+			r.PasswordExpired = aging.PasswordExpired
+			r.PasswordChanged = aging.LastChange
+		}
+
+		login := logins[account.Username]
+		if lastlog != nil {
+			if l, ok := readLastlog(lastlog, uid); ok && l.time.After(login.time) {
+				login = l
+			}
+		}
+		if !login.time.IsZero() {
+			r.LastLogin = login.time.UTC().Format(time.RFC3339)
+			r.LastLoginFrom = login.from
+		}
+
+		r.SudoVia = sudoGrants(account.Username, append([]string{r.PrimaryGroup}, r.Groups...), sudoers)
+		r.Sudo = len(r.SudoVia) > 0
+		r.AuthorizedKeyCount, r.AuthorizedKeys = countAuthorizedKeys(account)
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+// sudoGrants lists what gives the user sudo: membership of an admin group,
+// or a sudoers rule naming the user, one of its groups or ALL. Rules using
+// User_Alias are not resolved
+func sudoGrants(username string, groups []string, files []SudoersFile) []string {
+	var via []string
+	inGroup := make(map[string]bool, len(groups))
+	for _, group := range groups {
+		if group == "" {
+			continue
+		}
+		inGroup[group] = true
+		if adminGroups[group] {
+			via = append(via, "group:"+group)
+		}
+	}
+
+	for _, file := range files {
+		for _, rule := range file.Rules {
+			if ruleMatchesUser(rule, username, inGroup) {
+				via = append(via, file.Path)
+				break
+			}
+		}
+	}
+	return via
+}
+
+// ruleMatchesUser reports whether a sudoers rule applies to the user
+func ruleMatchesUser(rule SudoRule, username string, inGroup map[string]bool) bool {
+	for _, user := range rule.Users {
+		switch {
+		case user == "ALL", user == username:
+			return true
+		case strings.HasPrefix(user, "%"):
+			// %admin in /etc/sudoers is already reported as group membership
+			if group := strings.TrimPrefix(user, "%"); inGroup[group] && !adminGroups[group] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// countAuthorizedKeys counts the valid keys in the user's authorized_keys
+func countAuthorizedKeys(account *Account) (int, bool) {
+	if !filepath.IsAbs(account.Home) {
+		return 0, false
+	}
+	if _, err := os.Lstat(authorizedKeysPath(account)); err != nil {
+		return 0, false
+	}
+	lines, err := readAuthorizedKeys(account)
+	if err != nil {
+		return 0, false
+	}
+	count := 0
+	for _, line := range lines {
+		if isBlankOrComment(line) {
+			continue
+		}
+		if _, err := parseAuthorizedKey(line); err == nil {
+			count++
+		}
+	}
+	return count, true
+}
+
+// readLastlog reads the user's record from the lastlog file, which is
+// indexed by uid
+func readLastlog(f *os.File, uid int) (lastLogin, bool) {
+	buf := make([]byte, lastlogSize)
+	if _, err := f.ReadAt(buf, int64(uid)*lastlogSize); err != nil {
+		return lastLogin{}, false
+	}
+	seconds := binary.NativeEndian.Uint32(buf[0:4])
+	if seconds == 0 {
+		return lastLogin{}, false
+	}
+	return lastLogin{time: time.Unix(int64(seconds), 0), from: cString(buf[36:292])}, true
+}
+
+// readWtmp returns each user's most recent login session in wtmp, reading
+// one record at a time since busy hosts keep hundreds of MB. Files in another
+// layout, such as 32-bit systems', are ignored
+func readWtmp(path string) map[string]lastLogin {
+	logins := make(map[string]lastLogin)
+	f, err := os.Open(path)
+	if err != nil {
+		return logins
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err != nil || info.Size()%utmpSize != 0 {
+		return logins
+	}
+
+	r := bufio.NewReaderSize(f, 64*utmpSize)
+	rec := make([]byte, utmpSize)
+	for {
+		if _, err := io.ReadFull(r, rec); err != nil {
+			return logins
+		}
+		if int16(binary.NativeEndian.Uint16(rec[0:2])) != utmpUserProcess {
+			continue
+		}
+		// ut_user at 44, ut_host at 76, ut_tv.tv_sec at 340
+		user := cString(rec[44:76])
+		at := time.Unix(int64(int32(binary.NativeEndian.Uint32(rec[340:344]))), 0)
+		if user != "" && at.After(logins[user].time) {
+			logins[user] = lastLogin{time: at, from: cString(rec[76:332])}
+		}
+	}
+}
+
+// cString returns a NUL-padded string field
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// loginDefsInt reads a numeric setting from login.defs
+func loginDefsInt(key string, fallback int) int {
+	f, err := os.Open(loginDefsPath)
+	if err != nil {
+		return fallback
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == key {
+			if n, err := strconv.Atoi(fields[1]); err == nil {
+				return n
+			}
+		}
+	}
+	return fallback
+}
+
+// windowsInventory reads local users and their local group memberships
+func windowsInventory(ctx context.Context) ([]UserRecord, error) {
+	output, err := powershell(ctx, "$groups = @{}; Get-LocalGroup | ForEach-Object { $g = $_.Name; "+
+		"Get-LocalGroupMember -Group $g -ErrorAction SilentlyContinue | ForEach-Object { $groups[$_.SID.Value] += @($g) } }; "+
+		"Get-LocalUser | Select-Object Name,Description,Enabled,@{n='SID';e={$_.SID.Value}},"+
+		"@{n='LastLogon';e={if($_.LastLogon){$_.LastLogon.ToUniversalTime().ToString('o')}}},"+
+		"@{n='PasswordLastSet';e={if($_.PasswordLastSet){$_.PasswordLastSet.ToString('yyyy-MM-dd')}}},"+
+		"@{n='PasswordExpires';e={if($_.PasswordExpires){$_.PasswordExpires.ToString('yyyy-MM-dd')}}},"+
+		"@{n='AccountExpires';e={if($_.AccountExpires){$_.AccountExpires.ToString('yyyy-MM-dd')}}},"+
+		"@{n='Groups';e={@($groups[$_.SID.Value])}} | ConvertTo-Json -Depth 3")
+	if err != nil {
+		return nil, err
+	}
+
+	var users []struct {
+		Name            string   `json:"Name"`
+		Description     string   `json:"Description"`
+		Enabled         bool     `json:"Enabled"`
+		SID             string   `json:"SID"`
+		LastLogon       string   `json:"LastLogon"`
+		PasswordLastSet string   `json:"PasswordLastSet"`
+		PasswordExpires string   `json:"PasswordExpires"`
+		AccountExpires  string   `json:"AccountExpires"`
+		Groups          []string `json:"Groups"`
+	}
+	output = strings.TrimSpace(output)
+	if !strings.HasPrefix(output, "[") {
+		// A single user is rendered as an object
+		output = "[" + output + "]"
+	}
+	if err := json.Unmarshal([]byte(output), &users); err != nil {
+		return nil, fmt.Errorf("failed to parse Get-LocalUser output: %v", err)
+	}
+
+	today := time.Now().Format(shadowDate)
+	records := make([]UserRecord, 0, len(users))
+	for _, u := range users {
+		r := UserRecord{
+			Username:        u.Name,
+			SID:             u.SID,
+			Groups:          []string{},
+			Comment:         u.Description,
+			Locked:          !u.Enabled,
+			Expired:         u.AccountExpires != "" && u.AccountExpires <= today,
+			PasswordExpired: u.PasswordExpires != "" && u.PasswordExpires <= today,
+			PasswordChanged: u.PasswordLastSet,
+		}
+		// Built-in accounts have relative IDs below 1000
+		if i := strings.LastIndex(u.SID, "-"); i >= 0 {
+			if rid, err := strconv.Atoi(u.SID[i+1:]); err == nil {
+				r.System = rid < 1000
+			}
+		}
+		if t, err := time.Parse(time.RFC3339, u.LastLogon); err == nil {
+			r.LastLogin = t.UTC().Format(time.RFC3339)
+		}
+		for _, group := range u.Groups {
+			if group == "" {
+				continue
+			}
+			r.Groups = append(r.Groups, group)
+			if adminGroups[group] {
+				r.SudoVia = append(r.SudoVia, "group:"+group)
+			}
+		}
+		r.Sudo = len(r.SudoVia) > 0
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+// DiffUsers compares a previously reported inventory with the current one.
+// Login times alone do not make an account changed
+func DiffUsers(previous, current []UserRecord) UserDelta {
+	return inventory.Diff(previous, current,
+		func(r UserRecord) string { return r.Username },
+		func(a, b UserRecord) bool { return UserChecksum([]UserRecord{a}) == UserChecksum([]UserRecord{b}) })
+}
+
+// UserChecksum hashes an inventory so both sides can confirm they agree. The
+// last login is left out, since every login would otherwise count as a change
+func UserChecksum(records []UserRecord) string {
+	stable := make([]UserRecord, len(records))
+	for i, r := range records {
+		r.LastLogin, r.LastLoginFrom = "", ""
+		stable[i] = r
+	}
+	return inventory.Checksum(stable)
+}
@@ -0,0 +1,63 @@
+package user
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// utmpRecord encodes a 64-bit Linux struct utmp
+func utmpRecord(typ int16, user, host string, sec int32) []byte {
+	rec := make([]byte, utmpSize)
+	binary.NativeEndian.PutUint16(rec[0:2], uint16(typ))
+	copy(rec[44:76], user)
+	copy(rec[76:332], host)
+	binary.NativeEndian.PutUint32(rec[340:344], uint32(sec))
+	return rec
+}
+
+func TestReadWtmp(t *testing.T) {
+	dir := t.TempDir()
+	var data []byte
+	for _, rec := range [][]byte{
+		utmpRecord(utmpUserProcess, "alice", "10.0.0.1", 1000),
+		utmpRecord(utmpUserProcess, "bob", "", 1500),
+		utmpRecord(utmpUserProcess, "alice", "10.0.0.2", 2000),
+		utmpRecord(utmpUserProcess, "alice", "10.0.0.3", 1200),
+		utmpRecord(8, "carol", "10.0.0.4", 3000), // DEAD_PROCESS
+		utmpRecord(utmpUserProcess, "", "10.0.0.5", 4000),
+	} {
+		data = append(data, rec...)
+	}
+	path := filepath.Join(dir, "wtmp")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]lastLogin{
+		"alice": {time: time.Unix(2000, 0), from: "10.0.0.2"},
+		"bob":   {time: time.Unix(1500, 0)},
+	}
+	got := readWtmp(path)
+	if len(got) != len(want) {
+		t.Errorf("got %d users, want %d: %v", len(got), len(want), got)
+	}
+	for user, w := range want {
+		if g := got[user]; !g.time.Equal(w.time) || g.from != w.from {
+			t.Errorf("%s: got %v from %q, want %v from %q", user, g.time, g.from, w.time, w.from)
+		}
+	}
+
+	// A size that is not a multiple of the record means another layout
+	if err := os.WriteFile(path, append(data, 0), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := readWtmp(path); len(got) != 0 {
+		t.Errorf("foreign layout: got %v, want nothing", got)
+	}
+	if got := readWtmp(filepath.Join(dir, "missing")); len(got) != 0 {
+		t.Errorf("missing file: got %v, want nothing", got)
+	}
+}
@@ -0,0 +1,521 @@
+package user
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"einfra/agent/internal/executor"
+	"einfra/agent/internal/fsutil"
+)
+
+const (
+	shadowPath = "/etc/shadow"
+	// shadowDate is how dates are passed to chage and reported back
+	shadowDate = "2006-01-02"
+)
+
+// PasswordAging is an account's password aging and expiry state. Dates are
+// YYYY-MM-DD; a nil limit is not enforced
+type PasswordAging struct {
+	Username string `json:"username"`
+	// Locked means the password cannot be used to log in
+	Locked bool `json:"locked"`
+	// MustChange forces a password change at the next login
+	MustChange      bool   `json:"must_change"`
+	LastChange      string `json:"last_change,omitempty"`
+	MinDays         *int   `json:"min_days"`
+	MaxDays         *int   `json:"max_days"`
+	WarnDays        *int   `json:"warn_days"`
+	InactiveDays    *int   `json:"inactive_days"`
+	PasswordExpires string `json:"password_expires,omitempty"`
+	PasswordExpired bool   `json:"password_expired"`
+	AccountExpires  string `json:"account_expires,omitempty"`
+	AccountExpired  bool   `json:"account_expired"`
+}
+
+// lockExpireDay is the expiry day user_lock sets: 1970-01-02, always past
+const lockExpireDay = "1"
+
+// lockUser locks or unlocks an account. On Linux locking also expires the
+// account, which blocks SSH key logins as well as the password; unlocking
+// restores the expiry date the account had before it was locked
+func (e *Executor) lockUser(ctx context.Context, action *executor.Action, result *executor.Result, lock bool) *executor.Result {
+	username, err := nameParam(action, "username")
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+
+	verb := "unlock"
+	if lock {
+		verb = "lock"
+	}
+
+	var output string
+	switch runtime.GOOS {
+	case "linux":
+		if lock {
+			output, err = e.lockLinux(ctx, username)
+		} else {
+			var expires string
+			output, expires, err = e.unlockLinux(ctx, username)
+			result.Data["account_expires"] = expires
+		}
+	case "windows":
+		cmdlet := "Enable-LocalUser"
+		if lock {
+			cmdlet = "Disable-LocalUser"
+		}
+		output, err = powershell(ctx, fmt.Sprintf("%s -Name '%s'", cmdlet, username))
+	default:
+		return failResult(result, "", unsupported(runtime.GOOS, "user management"))
+	}
+
+	result.Output = output
+	if err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to %s user: %v", verb, err)
+		return result
+	}
+
+	result.Data["username"] = username
+	result.Data["locked"] = lock
+	result.Success = true
+	return result
+}
+
+// lockLinux saves the account's expiry day, then locks and expires it. An
+// account that is already locked keeps the day saved by the earlier lock
+func (e *Executor) lockLinux(ctx context.Context, username string) (string, error) {
+	e.lockMu.Lock()
+	defer e.lockMu.Unlock()
+
+	saved, err := e.loadLockState()
+	if err != nil {
+		return "", err
+	}
+	expire, err := shadowExpireDay(username)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := saved[username]; !ok || expire != lockExpireDay {
+		if expire == lockExpireDay {
+			// Locked before its previous expiry was recorded
+			expire = ""
+		}
+		saved[username] = expire
+		if err := e.saveLockState(saved); err != nil {
+			return "", err
+		}
+	}
+	return run(ctx, "usermod", "--lock", "--expiredate", lockExpireDay, username)
+}
+
+// unlockLinux unlocks the account and restores the expiry day saved when it
+// was locked. An expiry changed since the lock is left as it is. It returns
+// the resulting expiry date, empty when the account never expires
+func (e *Executor) unlockLinux(ctx context.Context, username string) (string, string, error) {
+	e.lockMu.Lock()
+	defer e.lockMu.Unlock()
+
+	saved, err := e.loadLockState()
+	if err != nil {
+		return "", "", err
+	}
+	current, err := shadowExpireDay(username)
+	if err != nil {
+		return "", "", err
+	}
+
+	args := []string{"--unlock"}
+	expire := current
+	if current == lockExpireDay {
+		expire = saved[username]
+		date := ""
+		if day := shadowInt(expire); day != nil {
+			date = shadowDay(*day)
+		}
+		args = append(args, "--expiredate", date)
+	}
+	output, err := run(ctx, "usermod", append(args, username)...)
+	if err != nil {
+		return output, "", err
+	}
+
+	if _, ok := saved[username]; ok {
+		delete(saved, username)
+		if err := e.saveLockState(saved); err != nil {
+			return output, "", err
+		}
+	}
+	if day := shadowInt(expire); day != nil {
+		return output, shadowDay(*day), nil
+	}
+	return output, "", nil
+}
+
+// loadLockState reads the expiry days saved by user_lock, keyed by username
+func (e *Executor) loadLockState() (map[string]string, error) {
+	saved := make(map[string]string)
+	data, err := os.ReadFile(e.lockStatePath)
+	if os.IsNotExist(err) {
+		return saved, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("corrupt %s: %v", e.lockStatePath, err)
+	}
+	return saved, nil
+}
+
+// saveLockState persists the saved expiry days
+func (e *Executor) saveLockState(saved map[string]string) error {
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(e.lockStatePath), 0700); err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(e.lockStatePath, data, 0600)
+}
+
+// shadowExpireDay returns the raw account expiry field of the user's
+// /etc/shadow entry, empty when the account never expires
+func shadowExpireDay(username string) (string, error) {
+	f, err := os.Open(shadowPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ":")
+		if len(fields) >= 8 && fields[0] == username {
+			return fields[7], nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("user %s not found in %s", username, shadowPath)
+}
+
+// setPassword sets a password from a crypt hash, so no plaintext is sent
+func (e *Executor) setPassword(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	username, err := nameParam(action, "username")
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+	hash, err := passwordHashParam(action, true)
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+	if runtime.GOOS != "linux" {
+		// Windows only accepts plaintext passwords
+		return failResult(result, "", unsupported(runtime.GOOS, "pre-hashed passwords"))
+	}
+
+	output, err := setPasswordHash(ctx, username, hash)
+	result.Output = output
+	if err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to set password: %v", err)
+		return result
+	}
+
+	if action.BoolParam("must_change", false) {
+		output, err := run(ctx, "chage", "-d", "0", username)
+		result.Output += output
+		if err != nil {
+			result.Success = false
+			result.Error = fmt.Sprintf("password set but forcing a change failed: %v", err)
+			return result
+		}
+	}
+
+	result.Data["username"] = username
+	result.Success = true
+	return result
+}
+
+// getExpiry reports password aging and account expiry
+func (e *Executor) getExpiry(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	username, err := nameParam(action, "username")
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+
+	var aging *PasswordAging
+	switch runtime.GOOS {
+	case "linux":
+		aging, err = readPasswordAging(username, time.Now())
+	case "windows":
+		aging, err = windowsPasswordAging(ctx, username)
+	default:
+		return failResult(result, "", unsupported(runtime.GOOS, "user management"))
+	}
+	if err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to read password aging: %v", err)
+		return result
+	}
+
+	result.Data["aging"] = aging
+	result.Success = true
+	return result
+}
+
+// setExpiry changes password aging and account expiry with chage
+func (e *Executor) setExpiry(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	username, err := nameParam(action, "username")
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+	expireDate, err := dateParam(action, "expire_date")
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+
+	var output string
+	switch runtime.GOOS {
+	case "linux":
+		var args []string
+		if expireDate != "" {
+			args = append(args, "-E", expireDate)
+		}
+		lastChange, err := dateParam(action, "last_change")
+		if err != nil {
+			result.Success = false
+			result.Error = err.Error()
+			return result
+		}
+		if action.BoolParam("must_change", false) {
+			lastChange = "0"
+		}
+		if lastChange != "" {
+			args = append(args, "-d", lastChange)
+		}
+		for _, opt := range []struct{ key, flag string }{
+			{"min_days", "-m"}, {"max_days", "-M"}, {"warn_days", "-W"}, {"inactive_days", "-I"},
+		} {
+			if !action.HasParam(opt.key) {
+				continue
+			}
+			// -1 removes the limit
+			days := action.IntParam(opt.key, -2)
+			if days < -1 {
+				result.Success = false
+				result.Error = fmt.Sprintf("'%s' must be a number of days, or -1 to remove the limit", opt.key)
+				return result
+			}
+			args = append(args, opt.flag, strconv.Itoa(days))
+		}
+		if len(args) == 0 {
+			result.Success = false
+			result.Error = "no changes requested"
+			return result
+		}
+		output, err = run(ctx, "chage", append(args, username)...)
+
+	case "windows":
+		if err := rejectParams(action, "windows", "last_change", "must_change", "min_days", "max_days", "warn_days", "inactive_days"); err != nil {
+			return failResult(result, "", err)
+		}
+		switch expireDate {
+		case "":
+			result.Success = false
+			result.Error = "no changes requested"
+			return result
+		case "-1":
+			output, err = powershell(ctx, fmt.Sprintf("Set-LocalUser -Name '%s' -AccountNeverExpires", username))
+		default:
+			output, err = powershell(ctx, fmt.Sprintf("Set-LocalUser -Name '%s' -AccountExpires ([datetime]'%s')", username, expireDate))
+		}
+
+	default:
+		return failResult(result, "", unsupported(runtime.GOOS, "user management"))
+	}
+
+	result.Output = output
+	if err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to set expiry: %v", err)
+		return result
+	}
+
+	result.Data["username"] = username
+	result.Success = true
+	return result
+}
+
+// passwordHashParam reads 'password_hash', a crypt(3) string such as
+// $6$salt$hash or $y$j9T$salt$hash
+func passwordHashParam(action *executor.Action, required bool) (string, error) {
+	hash, ok := action.Params["password_hash"].(string)
+	if !ok {
+		if required {
+			return "", fmt.Errorf("missing 'password_hash' parameter")
+		}
+		return "", nil
+	}
+	if !validCryptHash.MatchString(hash) {
+		return "", fmt.Errorf("'password_hash' must be a crypt hash such as $6$salt$hash")
+	}
+	return hash, nil
+}
+
+// dateParam reads a YYYY-MM-DD date; "never" becomes -1, which chage uses
+// to remove a date
+func dateParam(action *executor.Action, key string) (string, error) {
+	value := action.StringParam(key, "")
+	if value == "" {
+		return "", nil
+	}
+	if value == "never" {
+		return "-1", nil
+	}
+	if _, err := time.Parse(shadowDate, value); err != nil {
+		return "", fmt.Errorf("'%s' must be a YYYY-MM-DD date or \"never\"", key)
+	}
+	return value, nil
+}
+
+// setPasswordHash installs a crypt hash through chpasswd's stdin, keeping it
+// out of the process list
+func setPasswordHash(ctx context.Context, username, hash string) (string, error) {
+	return runInput(ctx, username+":"+hash+"\n", "chpasswd", "-e")
+}
+
+// readPasswordAging reads the account's /etc/shadow entry
+func readPasswordAging(username string, now time.Time) (*PasswordAging, error) {
+	entries, err := readShadow(now)
+	if err != nil {
+		return nil, err
+	}
+	aging, ok := entries[username]
+	if !ok {
+		return nil, fmt.Errorf("user %s not found in %s", username, shadowPath)
+	}
+	return aging, nil
+}
+
+// readShadow reads every /etc/shadow entry, keyed by username
+func readShadow(now time.Time) (map[string]*PasswordAging, error) {
+	f, err := os.Open(shadowPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries := make(map[string]*PasswordAging)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ":")
+		if len(fields) >= 8 {
+			entries[fields[0]] = parseShadowEntry(fields, now)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// parseShadowEntry interprets "name:hash:lastchg:min:max:warn:inactive:expire:",
+// where dates are days since the epoch
+func parseShadowEntry(fields []string, now time.Time) *PasswordAging {
+	aging := &PasswordAging{
+		Username:     fields[0],
+		Locked:       strings.HasPrefix(fields[1], "!") || fields[1] == "*",
+		MinDays:      shadowInt(fields[3]),
+		MaxDays:      shadowInt(fields[4]),
+		WarnDays:     shadowInt(fields[5]),
+		InactiveDays: shadowInt(fields[6]),
+	}
+	today := int(now.Unix() / 86400)
+
+	if last := shadowInt(fields[2]); last != nil {
+		if *last == 0 {
+			aging.MustChange = true
+		} else {
+			aging.LastChange = shadowDay(*last)
+			// A maximum of 99999 days is the conventional "never"
+			if aging.MaxDays != nil && *aging.MaxDays >= 0 && *aging.MaxDays < 99999 {
+				expires := *last + *aging.MaxDays
+				aging.PasswordExpires = shadowDay(expires)
+				aging.PasswordExpired = today >= expires
+			}
+		}
+	}
+	if expire := shadowInt(fields[7]); expire != nil {
+		aging.AccountExpires = shadowDay(*expire)
+		aging.AccountExpired = today >= *expire
+	}
+	return aging
+}
+
+// shadowInt parses an optional numeric shadow field
+func shadowInt(field string) *int {
+	n, err := strconv.Atoi(field)
+	if err != nil {
+		return nil
+	}
+	return &n
+}
+
+// shadowDay converts days since the epoch to a date
+func shadowDay(days int) string {
+	return time.Unix(int64(days)*86400, 0).UTC().Format(shadowDate)
+}
+
+// windowsPasswordAging reads the expiry fields of a local user
+func windowsPasswordAging(ctx context.Context, username string) (*PasswordAging, error) {
+	output, err := powershell(ctx, fmt.Sprintf("Get-LocalUser -Name '%s' | Select-Object Enabled,"+
+		"@{n='PasswordLastSet';e={if($_.PasswordLastSet){$_.PasswordLastSet.ToString('yyyy-MM-dd')}}},"+
+		"@{n='PasswordExpires';e={if($_.PasswordExpires){$_.PasswordExpires.ToString('yyyy-MM-dd')}}},"+
+		"@{n='AccountExpires';e={if($_.AccountExpires){$_.AccountExpires.ToString('yyyy-MM-dd')}}} | ConvertTo-Json", username))
+	if err != nil {
+		return nil, err
+	}
+
+	var record struct {
+		Enabled         bool   `json:"Enabled"`
+		PasswordLastSet string `json:"PasswordLastSet"`
+		PasswordExpires string `json:"PasswordExpires"`
+		AccountExpires  string `json:"AccountExpires"`
+	}
+	if err := json.Unmarshal([]byte(output), &record); err != nil {
+		return nil, fmt.Errorf("failed to parse Get-LocalUser output: %v", err)
+	}
+
+	today := time.Now().Format(shadowDate)
+	return &PasswordAging{
+		Username:        username,
+		Locked:          !record.Enabled,
+		LastChange:      record.PasswordLastSet,
+		PasswordExpires: record.PasswordExpires,
+		PasswordExpired: record.PasswordExpires != "" && record.PasswordExpires <= today,
+		AccountExpires:  record.AccountExpires,
+		AccountExpired:  record.AccountExpires != "" && record.AccountExpires <= today,
+	}, nil
+}
@@ -0,0 +1,56 @@
+package user
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseShadowEntry(t *testing.T) {
+	// Day 19723 is 2024-01-01
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	days := func(n int) *int { return &n }
+
+	tests := []struct {
+		line string
+		want PasswordAging
+	}{
+		{
+			line: "alice:$6$salt$hash:19723:0:99999:7:::",
+			want: PasswordAging{Username: "alice", LastChange: "2024-01-01", MinDays: days(0), MaxDays: days(99999), WarnDays: days(7)},
+		},
+		{
+			line: "bob:$6$salt$hash:19723:1:90:7:14:20000:",
+			want: PasswordAging{
+				Username:        "bob",
+				LastChange:      "2024-01-01",
+				MinDays:         days(1),
+				MaxDays:         days(90),
+				WarnDays:        days(7),
+				InactiveDays:    days(14),
+				PasswordExpires: "2024-03-31",
+				PasswordExpired: true,
+				AccountExpires:  "2024-10-04",
+			},
+		},
+		{
+			line: "carol:!$6$salt$hash:19723::365::::",
+			want: PasswordAging{Username: "carol", Locked: true, LastChange: "2024-01-01", MaxDays: days(365), PasswordExpires: "2024-12-31"},
+		},
+		{
+			line: "dave:*:0:::::1:",
+			want: PasswordAging{Username: "dave", Locked: true, MustChange: true, AccountExpires: "1970-01-02", AccountExpired: true},
+		},
+		{
+			line: "erin:$6$salt$hash::::::19875:",
+			want: PasswordAging{Username: "erin", AccountExpires: "2024-06-01", AccountExpired: true},
+		},
+	}
+	for _, tt := range tests {
+		got := parseShadowEntry(strings.Split(tt.line, ":"), now)
+		if !reflect.DeepEqual(*got, tt.want) {
+			t.Errorf("parseShadowEntry(%q) = %+v, want %+v", tt.line, *got, tt.want)
+		}
+	}
+}
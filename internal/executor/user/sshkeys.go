@@ -0,0 +1,432 @@
+package user
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"einfra/agent/internal/executor"
+)
+
+// keyTypes maps the key types sshd accepts to their size in bits, or 0 when
+// the size has to be read from the key itself
+var keyTypes = map[string]int{
+	"ssh-rsa":                            0,
+	"ssh-dss":                            0,
+	"ssh-ed25519":                        256,
+	"ecdsa-sha2-nistp256":                256,
+	"ecdsa-sha2-nistp384":                384,
+	"ecdsa-sha2-nistp521":                521,
+	"sk-ssh-ed25519@openssh.com":         256,
+	"sk-ecdsa-sha2-nistp256@openssh.com": 256,
+}
+
+// AuthorizedKey is one parsed authorized_keys entry
+type AuthorizedKey struct {
+	// Line is the 1-based line number in the file
+	Line        int    `json:"line"`
+	Type        string `json:"type"`
+	Bits        int    `json:"bits,omitempty"`
+	Fingerprint string `json:"fingerprint"`
+	Comment     string `json:"comment,omitempty"`
+	// Options are kept verbatim, e.g. from="10.0.0.0/8" or no-pty
+	Options []string `json:"options,omitempty"`
+	Key     string   `json:"key"`
+	blob    []byte
+}
+
+// invalidLine is an authorized_keys line that could not be parsed
+type invalidLine struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// listSSHKeys lists the keys in a user's authorized_keys
+func (e *Executor) listSSHKeys(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	account, err := sshAccount(ctx, action)
+	if err != nil {
+		return failResult(result, "failed to list SSH keys", err)
+	}
+
+	lines, err := readAuthorizedKeys(account)
+	if err != nil {
+		return failResult(result, "failed to list SSH keys", err)
+	}
+
+	keys := []AuthorizedKey{}
+	invalid := []invalidLine{}
+	for i, line := range lines {
+		if isBlankOrComment(line) {
+			continue
+		}
+		key, err := parseAuthorizedKey(line)
+		if err != nil {
+			invalid = append(invalid, invalidLine{Line: i + 1, Error: err.Error()})
+			continue
+		}
+		key.Line = i + 1
+		keys = append(keys, *key)
+	}
+
+	result.Data["username"] = account.Username
+	result.Data["path"] = authorizedKeysPath(account)
+	result.Data["keys"] = keys
+	result.Data["invalid"] = invalid
+	result.Success = true
+	return result
+}
+
+// addSSHKeys adds keys to a user's authorized_keys. A key that is already
+// present has its line replaced, so its options and comment can be updated
+func (e *Executor) addSSHKeys(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	account, err := sshAccount(ctx, action)
+	if err != nil {
+		return failResult(result, "failed to add SSH keys", err)
+	}
+	newKeys, err := keyLinesParam(action, "key")
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+	if len(newKeys) == 0 {
+		result.Success = false
+		result.Error = "missing 'key' parameter"
+		return result
+	}
+
+	lines, err := readAuthorizedKeys(account)
+	if err != nil {
+		return failResult(result, "failed to add SSH keys", err)
+	}
+
+	added, updated, unchanged := []string{}, []string{}, []string{}
+	for _, key := range newKeys {
+		index := findKey(lines, key.blob)
+		switch {
+		case index < 0:
+			lines = append(lines, key.String())
+			added = append(added, key.Fingerprint)
+		case lines[index] != key.String():
+			lines[index] = key.String()
+			updated = append(updated, key.Fingerprint)
+		default:
+			unchanged = append(unchanged, key.Fingerprint)
+		}
+	}
+
+	if len(added)+len(updated) > 0 {
+		if err := writeAuthorizedKeys(account, lines); err != nil {
+			return failResult(result, "failed to add SSH keys", err)
+		}
+	}
+
+	result.Data["username"] = account.Username
+	result.Data["added"] = added
+	result.Data["updated"] = updated
+	result.Data["unchanged"] = unchanged
+	result.Success = true
+	return result
+}
+
+// removeSSHKeys removes keys matched by fingerprint or by key from a user's
+// authorized_keys. Keys that are not present are ignored
+func (e *Executor) removeSSHKeys(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	account, err := sshAccount(ctx, action)
+	if err != nil {
+		return failResult(result, "failed to remove SSH keys", err)
+	}
+	targets, err := keyLinesParam(action, "key")
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+	fingerprints := make(map[string]bool)
+	for _, fp := range action.StringsParam("fingerprint") {
+		fingerprints["SHA256:"+strings.TrimPrefix(fp, "SHA256:")] = true
+	}
+	for _, key := range targets {
+		fingerprints[key.Fingerprint] = true
+	}
+	if len(fingerprints) == 0 {
+		result.Success = false
+		result.Error = "missing 'fingerprint' or 'key' parameter"
+		return result
+	}
+
+	lines, err := readAuthorizedKeys(account)
+	if err != nil {
+		return failResult(result, "failed to remove SSH keys", err)
+	}
+
+	removed := []string{}
+	kept := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if !isBlankOrComment(line) {
+			if key, err := parseAuthorizedKey(line); err == nil && fingerprints[key.Fingerprint] {
+				removed = append(removed, key.Fingerprint)
+				continue
+			}
+		}
+		kept = append(kept, line)
+	}
+
+	if len(removed) > 0 {
+		if err := writeAuthorizedKeys(account, kept); err != nil {
+			return failResult(result, "failed to remove SSH keys", err)
+		}
+	}
+
+	result.Data["username"] = account.Username
+	result.Data["removed"] = removed
+	result.Success = true
+	return result
+}
+
+// replaceSSHKeys replaces the whole authorized_keys with the given keys; an
+// empty list revokes every key
+func (e *Executor) replaceSSHKeys(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	account, err := sshAccount(ctx, action)
+	if err != nil {
+		return failResult(result, "failed to replace SSH keys", err)
+	}
+	if !action.HasParam("keys") {
+		result.Success = false
+		result.Error = "missing 'keys' parameter"
+		return result
+	}
+	keys, err := keyLinesParam(action, "keys")
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+
+	lines := make([]string, 0, len(keys))
+	fingerprints := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if findKey(lines, key.blob) >= 0 {
+			continue
+		}
+		lines = append(lines, key.String())
+		fingerprints = append(fingerprints, key.Fingerprint)
+	}
+
+	if err := writeAuthorizedKeys(account, lines); err != nil {
+		return failResult(result, "failed to replace SSH keys", err)
+	}
+
+	result.Data["username"] = account.Username
+	result.Data["keys"] = fingerprints
+	result.Success = true
+	return result
+}
+
+// String renders the key as an authorized_keys line
+func (k *AuthorizedKey) String() string {
+	var b strings.Builder
+	if len(k.Options) > 0 {
+		b.WriteString(strings.Join(k.Options, ","))
+		b.WriteByte(' ')
+	}
+	b.WriteString(k.Type)
+	b.WriteByte(' ')
+	b.WriteString(k.Key)
+	if k.Comment != "" {
+		b.WriteByte(' ')
+		b.WriteString(k.Comment)
+	}
+	return b.String()
+}
+
+// sshAccount resolves the 'username' parameter to an account with a home
+func sshAccount(ctx context.Context, action *executor.Action) (*Account, error) {
+	if runtime.GOOS != "linux" {
+		return nil, unsupported(runtime.GOOS, "authorized_keys management")
+	}
+	username, err := nameParam(action, "username")
+	if err != nil {
+		return nil, err
+	}
+	account, err := lookupAccount(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	if !filepath.IsAbs(account.Home) {
+		return nil, fmt.Errorf("user %s has no home directory", username)
+	}
+	return account, nil
+}
+
+// keyLinesParam reads and parses authorized_keys lines
+func keyLinesParam(action *executor.Action, key string) ([]*AuthorizedKey, error) {
+	lines, err := linesParam(action, key)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]*AuthorizedKey, 0, len(lines))
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if isBlankOrComment(line) {
+			continue
+		}
+		parsed, err := parseAuthorizedKey(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key in '%s': %v", key, err)
+		}
+		keys = append(keys, parsed)
+	}
+	return keys, nil
+}
+
+// isBlankOrComment reports lines sshd skips
+func isBlankOrComment(line string) bool {
+	line = strings.TrimSpace(line)
+	return line == "" || strings.HasPrefix(line, "#")
+}
+
+// findKey returns the index of the line holding the key blob, or -1
+func findKey(lines []string, blob []byte) int {
+	for i, line := range lines {
+		if isBlankOrComment(line) {
+			continue
+		}
+		if key, err := parseAuthorizedKey(line); err == nil && bytes.Equal(key.blob, blob) {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseAuthorizedKey parses "[options] type base64 [comment]" and checks
+// that the key data matches its type
+func parseAuthorizedKey(line string) (*AuthorizedKey, error) {
+	line = strings.TrimSpace(line)
+	key := &AuthorizedKey{}
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty line")
+	}
+	if !isKeyType(fields[0]) {
+		options, rest, err := splitOptions(line)
+		if err != nil {
+			return nil, err
+		}
+		key.Options = options
+		fields = strings.Fields(rest)
+	}
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("expected a key type followed by base64 key data")
+	}
+	if !isKeyType(fields[0]) {
+		return nil, fmt.Errorf("unknown key type %q", fields[0])
+	}
+	key.Type = fields[0]
+	key.Key = fields[1]
+	key.Comment = strings.Join(fields[2:], " ")
+
+	blob, err := base64.StdEncoding.DecodeString(key.Key)
+	if err != nil {
+		return nil, fmt.Errorf("key data is not valid base64")
+	}
+	parts, err := sshStrings(blob)
+	if err != nil || len(parts) == 0 || string(parts[0]) != key.Type {
+		return nil, fmt.Errorf("key data does not match type %s", key.Type)
+	}
+	key.blob = blob
+	key.Bits = keyBits(key.Type, parts)
+
+	sum := sha256.Sum256(blob)
+	key.Fingerprint = "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+	return key, nil
+}
+
+// isKeyType reports whether s is a supported key or certificate type
+func isKeyType(s string) bool {
+	_, ok := keyTypes[strings.Replace(s, "-cert-v01@openssh.com", "", 1)]
+	return ok
+}
+
+// splitOptions splits the leading option list off a line. Options are
+// separated by commas and end at the first whitespace outside double quotes
+func splitOptions(line string) ([]string, string, error) {
+	var options []string
+	start := 0
+	inQuotes := false
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '\\' && inQuotes && i+1 < len(line):
+			i++
+		case c == '"':
+			inQuotes = !inQuotes
+		case c == ',' && !inQuotes:
+			options = append(options, line[start:i])
+			start = i + 1
+		case (c == ' ' || c == '\t') && !inQuotes:
+			options = append(options, line[start:i])
+			for _, option := range options {
+				if option == "" {
+					return nil, "", fmt.Errorf("empty option")
+				}
+			}
+			return options, line[i:], nil
+		}
+	}
+	if inQuotes {
+		return nil, "", fmt.Errorf("unterminated quote in options")
+	}
+	return nil, "", fmt.Errorf("expected a key type followed by base64 key data")
+}
+
+// sshStrings splits SSH wire data into its length-prefixed fields
+func sshStrings(data []byte) ([][]byte, error) {
+	var parts [][]byte
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return nil, fmt.Errorf("truncated key data")
+		}
+		n := binary.BigEndian.Uint32(data)
+		data = data[4:]
+		if uint64(n) > uint64(len(data)) {
+			return nil, fmt.Errorf("truncated key data")
+		}
+		parts = append(parts, data[:n])
+		data = data[n:]
+	}
+	return parts, nil
+}
+
+// keyBits returns the key size: the modulus of RSA keys, the prime of DSA
+// keys and the curve size otherwise
+func keyBits(keyType string, parts [][]byte) int {
+	switch keyType {
+	case "ssh-rsa":
+		// "ssh-rsa", e, n
+		if len(parts) >= 3 {
+			return new(big.Int).SetBytes(parts[2]).BitLen()
+		}
+	case "ssh-dss":
+		// "ssh-dss", p, q, g, y
+		if len(parts) >= 2 {
+			return new(big.Int).SetBytes(parts[1]).BitLen()
+		}
+	default:
+		return keyTypes[keyType]
+	}
+	return 0
+}
+
+// authorizedKeysPath returns the account's authorized_keys file
+func authorizedKeysPath(account *Account) string {
+	return filepath.Join(account.Home, ".ssh", "authorized_keys")
+}
@@ -0,0 +1,109 @@
+package user
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// sshBlob encodes fields as SSH wire data, base64 encoded
+func sshBlob(fields ...[]byte) string {
+	var blob []byte
+	for _, f := range fields {
+		blob = binary.BigEndian.AppendUint32(blob, uint32(len(f)))
+		blob = append(blob, f...)
+	}
+	return base64.StdEncoding.EncodeToString(blob)
+}
+
+func TestParseAuthorizedKey(t *testing.T) {
+	ed25519 := sshBlob([]byte("ssh-ed25519"), make([]byte, 32))
+	modulus := append([]byte{0x80}, make([]byte, 255)...)
+	rsa := sshBlob([]byte("ssh-rsa"), []byte{1, 0, 1}, modulus)
+
+	tests := []struct {
+		name    string
+		line    string
+		want    *AuthorizedKey
+		wantErr bool
+	}{
+		{
+			name: "plain",
+			line: "ssh-ed25519 " + ed25519 + " alice@laptop",
+			want: &AuthorizedKey{Type: "ssh-ed25519", Bits: 256, Key: ed25519, Comment: "alice@laptop"},
+		},
+		{
+			name: "rsa modulus size and multi-word comment",
+			line: "  ssh-rsa " + rsa + " deploy key 2024  ",
+			want: &AuthorizedKey{Type: "ssh-rsa", Bits: 2048, Key: rsa, Comment: "deploy key 2024"},
+		},
+		{
+			name: "options with quoted comma and space",
+			line: `from="10.0.0.0/8,192.168.0.0/16",command="echo \"a b\"",no-pty ssh-ed25519 ` + ed25519,
+			want: &AuthorizedKey{
+				Type:    "ssh-ed25519",
+				Bits:    256,
+				Key:     ed25519,
+				Options: []string{`from="10.0.0.0/8,192.168.0.0/16"`, `command="echo \"a b\""`, "no-pty"},
+			},
+		},
+		{name: "empty", line: "   ", wantErr: true},
+		{name: "missing key data", line: "ssh-ed25519", wantErr: true},
+		{name: "unknown type", line: "no-pty ssh-foo " + ed25519, wantErr: true},
+		{name: "bad base64", line: "ssh-ed25519 !!!!", wantErr: true},
+		{name: "type mismatch", line: "ssh-rsa " + ed25519, wantErr: true},
+		{name: "truncated blob", line: "ssh-ed25519 " + base64.StdEncoding.EncodeToString([]byte{0, 0, 0, 11, 's'}), wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseAuthorizedKey(tt.line)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: got %+v, want an error", tt.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		blob, _ := base64.StdEncoding.DecodeString(tt.want.Key)
+		sum := sha256.Sum256(blob)
+		tt.want.Fingerprint = "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+		tt.want.blob = blob
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSplitOptions(t *testing.T) {
+	tests := []struct {
+		line    string
+		options []string
+		rest    string
+		wantErr bool
+	}{
+		{line: "no-pty ssh-rsa AAAA", options: []string{"no-pty"}, rest: " ssh-rsa AAAA"},
+		{line: "no-pty,no-agent-forwarding\tssh-rsa", options: []string{"no-pty", "no-agent-forwarding"}, rest: "\tssh-rsa"},
+		{line: `command="a, b" ssh-rsa`, options: []string{`command="a, b"`}, rest: " ssh-rsa"},
+		{line: `command="say \"hi there\"" ssh-rsa`, options: []string{`command="say \"hi there\""`}, rest: " ssh-rsa"},
+		{line: "no-pty,,no-X11-forwarding ssh-rsa", wantErr: true},
+		{line: "no-pty, ssh-rsa", wantErr: true},
+		{line: `command="unterminated ssh-rsa`, wantErr: true},
+		{line: "no-pty", wantErr: true},
+	}
+	for _, tt := range tests {
+		options, rest, err := splitOptions(tt.line)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("splitOptions(%q) = %q, %q; want an error", tt.line, options, rest)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(options, tt.options) || rest != tt.rest {
+			t.Errorf("splitOptions(%q) = %q, %q, %v; want %q, %q", tt.line, options, rest, err, tt.options, tt.rest)
+		}
+	}
+}
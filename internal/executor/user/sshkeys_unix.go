@@ -0,0 +1,158 @@
+//go:build !windows
+
+package user
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// readAuthorizedKeys returns the lines of the account's authorized_keys; a
+// missing file has no lines. Neither ~/.ssh nor the file is followed if it
+// is a symlink, since the user controls where they point
+func readAuthorizedKeys(account *Account) ([]string, error) {
+	dir, err := openSSHDir(account, false)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer unix.Close(dir)
+
+	path := authorizedKeysPath(account)
+	// O_NONBLOCK keeps a FIFO planted in its place from blocking the open
+	fd, err := unix.Openat(dir, "authorized_keys", unix.O_RDONLY|unix.O_NOFOLLOW|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	switch {
+	case err == unix.ENOENT:
+		return nil, nil
+	case err == unix.ELOOP:
+		return nil, fmt.Errorf("%s is not a regular file", path)
+	case err != nil:
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	f := os.NewFile(uintptr(fd), path)
+	defer f.Close()
+
+	if info, err := f.Stat(); err != nil {
+		return nil, err
+	} else if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", path)
+	}
+
+	var data bytes.Buffer
+	if _, err := data.ReadFrom(f); err != nil {
+		return nil, err
+	}
+	content := strings.TrimRight(data.String(), "\n")
+	if content == "" {
+		return nil, nil
+	}
+	return strings.Split(content, "\n"), nil
+}
+
+// writeAuthorizedKeys atomically replaces the account's authorized_keys,
+// creating ~/.ssh as needed and enforcing ownership and 700/600 permissions.
+// Every step works on descriptors, so swapping ~/.ssh for a symlink midway
+// cannot redirect the write
+func writeAuthorizedKeys(account *Account, lines []string) error {
+	if info, err := os.Stat(account.Home); err != nil || !info.IsDir() {
+		return fmt.Errorf("home directory %s does not exist", account.Home)
+	}
+
+	dir, err := openSSHDir(account, true)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(dir)
+
+	if err := unix.Fchown(dir, account.UID, account.GID); err != nil {
+		return err
+	}
+	if err := unix.Fchmod(dir, 0700); err != nil {
+		return err
+	}
+
+	path := authorizedKeysPath(account)
+	var st unix.Stat_t
+	if err := unix.Fstatat(dir, "authorized_keys", &st, unix.AT_SYMLINK_NOFOLLOW); err == nil && st.Mode&unix.S_IFMT != unix.S_IFREG {
+		return fmt.Errorf("%s is not a regular file", path)
+	}
+
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	tmpName := ".authorized_keys.tmp-" + hex.EncodeToString(suffix)
+	fd, err := unix.Openat(dir, tmpName, unix.O_WRONLY|unix.O_CREAT|unix.O_EXCL|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmp := os.NewFile(uintptr(fd), filepath.Join(filepath.Dir(path), tmpName))
+	defer unix.Unlinkat(dir, tmpName, 0)
+
+	content := ""
+	if len(lines) > 0 {
+		content = strings.Join(lines, "\n") + "\n"
+	}
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := tmp.Chown(account.UID, account.GID); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temp file: %w", err)
+	}
+
+	if err := unix.Renameat(dir, tmpName, dir, "authorized_keys"); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	unix.Fsync(dir)
+	return nil
+}
+
+// openSSHDir opens the account's ~/.ssh without following a symlink,
+// creating it with mode 0700 when create is set
+func openSSHDir(account *Account, create bool) (int, error) {
+	home, err := unix.Open(account.Home, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return -1, &os.PathError{Op: "open", Path: account.Home, Err: err}
+	}
+	defer unix.Close(home)
+
+	path := filepath.Join(account.Home, ".ssh")
+	flags := unix.O_RDONLY | unix.O_DIRECTORY | unix.O_NOFOLLOW | unix.O_CLOEXEC
+	fd, err := unix.Openat(home, ".ssh", flags, 0)
+	if err == unix.ENOENT && create {
+		if err := unix.Mkdirat(home, ".ssh", 0700); err != nil && err != unix.EEXIST {
+			return -1, &os.PathError{Op: "mkdir", Path: path, Err: err}
+		}
+		fd, err = unix.Openat(home, ".ssh", flags, 0)
+	}
+	switch {
+	case err == unix.ELOOP || err == unix.ENOTDIR:
+		return -1, fmt.Errorf("%s is not a directory", path)
+	case err != nil:
+		return -1, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	return fd, nil
+}
@@ -0,0 +1,13 @@
+//go:build windows
+
+package user
+
+// readAuthorizedKeys is not implemented on Windows
+func readAuthorizedKeys(account *Account) ([]string, error) {
+	return nil, unsupported("windows", "authorized_keys management")
+}
+
+// writeAuthorizedKeys is not implemented on Windows
+func writeAuthorizedKeys(account *Account, lines []string) error {
+	return unsupported("windows", "authorized_keys management")
+}
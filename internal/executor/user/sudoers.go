@@ -0,0 +1,566 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"einfra/agent/internal/executor"
+)
+
+const (
+	sudoersPath    = "/etc/sudoers"
+	sudoersDir     = "/etc/sudoers.d"
+	sudoersHeader  = "# Managed by einfra-agent; local changes will be overwritten"
+	sudoersDirMode = 0750
+	sudoersMode    = 0440
+)
+
+var (
+	// validDropIn matches names sudo reads from sudoers.d: it skips files
+	// containing a '.' or ending in '~'
+	validDropIn = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	// validHost matches host names, addresses and networks
+	validHost = regexp.MustCompile(`^[A-Za-z0-9_.:/*-]+$`)
+	// sudoTag matches a command tag such as NOPASSWD: or SETENV:
+	sudoTag = regexp.MustCompile(`^([A-Z_]+):\s*`)
+)
+
+// SudoRule is one sudoers user specification:
+// users hosts = (runas:runas_groups) TAGS: commands
+type SudoRule struct {
+	// Users holds user names, with groups written as %name
+	Users       []string `json:"users"`
+	Hosts       []string `json:"hosts"`
+	RunAs       []string `json:"runas,omitempty"`
+	RunAsGroups []string `json:"runas_groups,omitempty"`
+	NoPasswd    bool     `json:"nopasswd"`
+	// Tags are tags other than NOPASSWD, such as SETENV
+	Tags     []string `json:"tags,omitempty"`
+	Commands []string `json:"commands"`
+	Line     int      `json:"line,omitempty"`
+}
+
+// SudoersFile is one parsed sudoers file
+type SudoersFile struct {
+	// Name is the drop-in name, empty for /etc/sudoers itself
+	Name    string     `json:"name,omitempty"`
+	Path    string     `json:"path"`
+	Managed bool       `json:"managed"`
+	Rules   []SudoRule `json:"rules"`
+	// Other holds lines that are not user specifications: Defaults, aliases
+	// and includes
+	Other []string `json:"other,omitempty"`
+}
+
+// listSudoers parses /etc/sudoers and the drop-ins sudo reads from sudoers.d
+func (e *Executor) listSudoers(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	if runtime.GOOS != "linux" {
+		return failResult(result, "", unsupported(runtime.GOOS, "sudoers"))
+	}
+
+	files, err := readSudoersFiles()
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Data["files"] = files
+	result.Data["included"] = sudoersIncludesDir()
+	result.Success = true
+	return result
+}
+
+// readSudoersFiles parses /etc/sudoers and the drop-ins sudo reads
+func readSudoersFiles() ([]SudoersFile, error) {
+	paths := []string{sudoersPath}
+	entries, err := os.ReadDir(sudoersDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s: %v", sudoersDir, err)
+	}
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && validDropIn.MatchString(entry.Name()) {
+			paths = append(paths, filepath.Join(sudoersDir, entry.Name()))
+		}
+	}
+
+	files := make([]SudoersFile, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		file := parseSudoers(string(data))
+		file.Path = path
+		if path != sudoersPath {
+			file.Name = filepath.Base(path)
+		}
+		files = append(files, *file)
+	}
+	return files, nil
+}
+
+// setSudoers writes a drop-in from structured rules, validating it with
+// visudo before it is moved into place
+func (e *Executor) setSudoers(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	if runtime.GOOS != "linux" {
+		return failResult(result, "", unsupported(runtime.GOOS, "sudoers"))
+	}
+	name, err := dropInParam(action)
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+	rules, err := sudoRulesParam(action)
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+
+	path := filepath.Join(sudoersDir, name)
+	existing, err := os.ReadFile(path)
+	replaced := err == nil
+	if replaced && !parseSudoers(string(existing)).Managed && !action.BoolParam("overwrite", false) {
+		result.Success = false
+		result.Error = fmt.Sprintf("%s exists and is not managed by the agent; set 'overwrite' to replace it", path)
+		return result
+	}
+
+	content := renderSudoers(rules)
+	if replaced && string(existing) == content {
+		result.Data["name"] = name
+		result.Data["path"] = path
+		result.Data["changed"] = false
+		result.Success = true
+		return result
+	}
+
+	output, err := installSudoers(ctx, path, content)
+	result.Output = output
+	if err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to write %s: %v", path, err)
+		return result
+	}
+
+	result.Data["name"] = name
+	result.Data["path"] = path
+	result.Data["changed"] = true
+	result.Data["replaced"] = replaced
+	result.Data["rules"] = parseSudoers(content).Rules
+	result.Data["included"] = sudoersIncludesDir()
+	result.Success = true
+	return result
+}
+
+// removeSudoers deletes a drop-in. When the configuration was valid before,
+// it must still be valid afterwards, or the file is put back
+func (e *Executor) removeSudoers(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	if runtime.GOOS != "linux" {
+		return failResult(result, "", unsupported(runtime.GOOS, "sudoers"))
+	}
+	name, err := dropInParam(action)
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+
+	path := filepath.Join(sudoersDir, name)
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		result.Data["name"] = name
+		result.Data["removed"] = false
+		result.Success = true
+		return result
+	}
+
+	_, validBefore := run(ctx, "visudo", "-c")
+
+	// sudo ignores names containing a '.', so the file is parked there until
+	// the remaining configuration checks out
+	parked := filepath.Join(sudoersDir, "."+name+".removed")
+	if err := os.Rename(path, parked); err != nil {
+		result.Success = false
+		result.Error = fmt.Sprintf("failed to remove %s: %v", path, err)
+		return result
+	}
+	if validBefore == nil {
+		if output, err := run(ctx, "visudo", "-c"); err != nil {
+			os.Rename(parked, path)
+			result.Output = output
+			result.Success = false
+			result.Error = fmt.Sprintf("removing %s would leave an invalid sudoers configuration: %v", path, err)
+			return result
+		}
+	}
+	os.Remove(parked)
+
+	result.Data["name"] = name
+	result.Data["path"] = path
+	result.Data["removed"] = true
+	result.Success = true
+	return result
+}
+
+// dropInParam reads the 'name' of a sudoers.d drop-in
+func dropInParam(action *executor.Action) (string, error) {
+	name, ok := action.Params["name"].(string)
+	if !ok {
+		return "", fmt.Errorf("missing 'name' parameter")
+	}
+	if !validDropIn.MatchString(name) {
+		// sudo would silently skip the file
+		return "", fmt.Errorf("invalid name %q: only letters, digits, '_' and '-' are allowed", name)
+	}
+	return name, nil
+}
+
+// sudoRulesParam reads the 'rules' list, or a single rule from the action's
+// own parameters
+func sudoRulesParam(action *executor.Action) ([]SudoRule, error) {
+	var specs []map[string]interface{}
+	switch v := action.Params["rules"].(type) {
+	case nil:
+		specs = append(specs, action.Params)
+	case []interface{}:
+		for _, item := range v {
+			spec, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("'rules' must be a list of objects")
+			}
+			specs = append(specs, spec)
+		}
+	default:
+		return nil, fmt.Errorf("'rules' must be a list of objects")
+	}
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("'rules' must not be empty")
+	}
+
+	rules := make([]SudoRule, 0, len(specs))
+	for i, spec := range specs {
+		rule, err := sudoRule(&executor.Action{Params: spec})
+		if err != nil {
+			if action.HasParam("rules") {
+				return nil, fmt.Errorf("rule %d: %v", i+1, err)
+			}
+			return nil, err
+		}
+		rules = append(rules, *rule)
+	}
+	return rules, nil
+}
+
+// sudoRule builds a rule from 'user' or 'group' (or lists of either),
+// 'hosts', 'runas', 'runas_group', 'commands' and 'nopasswd'
+func sudoRule(spec *executor.Action) (*SudoRule, error) {
+	users, err := namesParam(spec, "user")
+	if err != nil {
+		return nil, err
+	}
+	groups, err := namesParam(spec, "group")
+	if err != nil {
+		return nil, err
+	}
+	rule := &SudoRule{Users: users, NoPasswd: spec.BoolParam("nopasswd", false)}
+	for _, group := range groups {
+		rule.Users = append(rule.Users, "%"+group)
+	}
+	if len(rule.Users) == 0 {
+		return nil, fmt.Errorf("missing 'user' or 'group' parameter")
+	}
+
+	rule.Hosts = spec.StringsParam("hosts")
+	if len(rule.Hosts) == 0 {
+		rule.Hosts = []string{"ALL"}
+	}
+	for _, host := range rule.Hosts {
+		if !validHost.MatchString(host) {
+			return nil, fmt.Errorf("invalid host: %s", host)
+		}
+	}
+
+	if rule.RunAs, err = runAsParam(spec, "runas"); err != nil {
+		return nil, err
+	}
+	if rule.RunAsGroups, err = runAsParam(spec, "runas_group"); err != nil {
+		return nil, err
+	}
+
+	commands, err := linesParam(spec, "commands")
+	if err != nil {
+		return nil, err
+	}
+	for _, command := range commands {
+		command = strings.TrimSpace(command)
+		if command == "" {
+			continue
+		}
+		if strings.ContainsAny(command, "\r\n") {
+			return nil, fmt.Errorf("commands must not contain line breaks")
+		}
+		if command != "ALL" && !strings.HasPrefix(command, "/") && !strings.HasPrefix(command, "sudoedit ") {
+			return nil, fmt.Errorf("command %q must be ALL, sudoedit or an absolute path", command)
+		}
+		rule.Commands = append(rule.Commands, command)
+	}
+	if len(rule.Commands) == 0 {
+		return nil, fmt.Errorf("missing 'commands' parameter")
+	}
+	return rule, nil
+}
+
+// runAsParam reads a run-as user or group list, which may be ALL
+func runAsParam(spec *executor.Action, key string) ([]string, error) {
+	names := spec.StringsParam(key)
+	for _, name := range names {
+		if name != "ALL" && !validName.MatchString(name) {
+			return nil, fmt.Errorf("invalid name in '%s': %s", key, name)
+		}
+	}
+	return names, nil
+}
+
+// String renders the rule as a sudoers line
+func (r *SudoRule) String() string {
+	var b strings.Builder
+	b.WriteString(strings.Join(r.Users, ","))
+	b.WriteByte(' ')
+	b.WriteString(strings.Join(r.Hosts, ","))
+	b.WriteString(" = ")
+	if len(r.RunAs) > 0 || len(r.RunAsGroups) > 0 {
+		b.WriteString("(" + strings.Join(r.RunAs, ","))
+		if len(r.RunAsGroups) > 0 {
+			b.WriteString(":" + strings.Join(r.RunAsGroups, ","))
+		}
+		b.WriteString(") ")
+	}
+	if r.NoPasswd {
+		b.WriteString("NOPASSWD: ")
+	}
+	for _, tag := range r.Tags {
+		b.WriteString(tag + ": ")
+	}
+	commands := make([]string, len(r.Commands))
+	for i, command := range r.Commands {
+		commands[i] = escapeSudoCommand(command)
+	}
+	b.WriteString(strings.Join(commands, ", "))
+	return b.String()
+}
+
+// renderSudoers renders a managed drop-in
+func renderSudoers(rules []SudoRule) string {
+	var b strings.Builder
+	b.WriteString(sudoersHeader + "\n")
+	for _, rule := range rules {
+		b.WriteString(rule.String() + "\n")
+	}
+	return b.String()
+}
+
+// escapeSudoCommand escapes the characters sudoers treats specially in
+// command arguments
+func escapeSudoCommand(command string) string {
+	var b strings.Builder
+	for _, c := range command {
+		if strings.ContainsRune(`\,:=`, c) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// installSudoers writes content to a temp file that sudo ignores, checks it
+// with visudo -cf and only then renames it over path
+func installSudoers(ctx context.Context, path, content string) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(path), sudoersDirMode); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(tmpName, sudoersMode); err != nil {
+		return "", err
+	}
+	if err := os.Chown(tmpName, 0, 0); err != nil {
+		return "", err
+	}
+
+	output, err := run(ctx, "visudo", "-cf", tmpName)
+	if err != nil {
+		return output, fmt.Errorf("visudo rejected the rules: %w", err)
+	}
+	return output, os.Rename(tmpName, path)
+}
+
+// sudoersIncludesDir reports whether /etc/sudoers reads sudoers.d at all
+func sudoersIncludesDir() bool {
+	data, err := os.ReadFile(sudoersPath)
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && (fields[0] == "#includedir" || fields[0] == "@includedir") &&
+			strings.TrimSuffix(fields[1], "/") == sudoersDir {
+			return true
+		}
+	}
+	return false
+}
+
+// parseSudoers splits sudoers content into user specifications and other lines
+func parseSudoers(content string) *SudoersFile {
+	file := &SudoersFile{Rules: []SudoRule{}}
+	lines := strings.Split(content, "\n")
+	file.Managed = len(lines) > 0 && lines[0] == sudoersHeader
+
+	for i := 0; i < len(lines); i++ {
+		number := i + 1
+		line := lines[i]
+		// A trailing backslash continues the line
+		for strings.HasSuffix(line, "\\") && i+1 < len(lines) {
+			i++
+			line = strings.TrimSuffix(line, "\\") + " " + strings.TrimSpace(lines[i])
+		}
+		line = strings.TrimSpace(line)
+
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#include"), strings.HasPrefix(line, "@include"):
+			file.Other = append(file.Other, line)
+		case strings.HasPrefix(line, "#"):
+		default:
+			rule, ok := parseSudoRule(line)
+			if !ok {
+				file.Other = append(file.Other, line)
+				continue
+			}
+			rule.Line = number
+			file.Rules = append(file.Rules, *rule)
+		}
+	}
+	return file
+}
+
+// parseSudoRule parses a single "users hosts = (runas) TAGS: commands" line.
+// Defaults, aliases and multi-host specifications are not parsed
+func parseSudoRule(line string) (*SudoRule, bool) {
+	first := strings.Fields(line)[0]
+	if strings.HasPrefix(first, "Defaults") || strings.HasSuffix(first, "_Alias") {
+		return nil, false
+	}
+
+	left, right, ok := splitUnescaped(line, '=')
+	if !ok {
+		return nil, false
+	}
+	fields := strings.Fields(strings.ReplaceAll(left, ", ", ","))
+	if len(fields) != 2 {
+		return nil, false
+	}
+	rule := &SudoRule{Users: strings.Split(fields[0], ","), Hosts: strings.Split(fields[1], ",")}
+
+	right = strings.TrimSpace(right)
+	if strings.HasPrefix(right, "(") {
+		end := strings.Index(right, ")")
+		if end < 0 {
+			return nil, false
+		}
+		users, groups, _ := strings.Cut(right[1:end], ":")
+		rule.RunAs = splitList(users)
+		rule.RunAsGroups = splitList(groups)
+		right = strings.TrimSpace(right[end+1:])
+	}
+	for {
+		m := sudoTag.FindStringSubmatch(right)
+		if m == nil {
+			break
+		}
+		if m[1] == "NOPASSWD" {
+			rule.NoPasswd = true
+		} else {
+			rule.Tags = append(rule.Tags, m[1])
+		}
+		right = right[len(m[0]):]
+	}
+
+	for {
+		command, rest, more := splitUnescaped(right, ',')
+		if _, _, multi := splitUnescaped(command, ':'); multi {
+			// "cmd : host = cmd" joins several specifications on one line
+			return nil, false
+		}
+		if command = unescapeSudoCommand(strings.TrimSpace(command)); command != "" {
+			rule.Commands = append(rule.Commands, command)
+		}
+		if !more {
+			break
+		}
+		right = rest
+	}
+	if len(rule.Commands) == 0 {
+		return nil, false
+	}
+	return rule, true
+}
+
+// splitUnescaped splits s at the first sep not preceded by a backslash
+func splitUnescaped(s string, sep byte) (string, string, bool) {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			return s[:i], s[i+1:], true
+		}
+	}
+	return s, "", false
+}
+
+// unescapeSudoCommand reverses escapeSudoCommand
+func unescapeSudoCommand(command string) string {
+	var b strings.Builder
+	for i := 0; i < len(command); i++ {
+		if command[i] == '\\' && i+1 < len(command) && strings.IndexByte(`\,:=`, command[i+1]) >= 0 {
+			i++
+		}
+		b.WriteByte(command[i])
+	}
+	return b.String()
+}
+
+// splitList splits a comma separated sudoers list
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
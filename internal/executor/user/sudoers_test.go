@@ -0,0 +1,90 @@
+package user
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSudoRule(t *testing.T) {
+	tests := []struct {
+		line string
+		want *SudoRule
+	}{
+		{
+			line: "alice ALL=(ALL:ALL) ALL",
+			want: &SudoRule{Users: []string{"alice"}, Hosts: []string{"ALL"}, RunAs: []string{"ALL"}, RunAsGroups: []string{"ALL"}, Commands: []string{"ALL"}},
+		},
+		{
+			line: "%wheel ALL = NOPASSWD: SETENV: /usr/bin/systemctl restart nginx, /usr/bin/journalctl",
+			want: &SudoRule{
+				Users:    []string{"%wheel"},
+				Hosts:    []string{"ALL"},
+				NoPasswd: true,
+				Tags:     []string{"SETENV"},
+				Commands: []string{"/usr/bin/systemctl restart nginx", "/usr/bin/journalctl"},
+			},
+		},
+		{
+			line: "bob, carol web1 = (postgres) /usr/bin/psql",
+			want: &SudoRule{Users: []string{"bob", "carol"}, Hosts: []string{"web1"}, RunAs: []string{"postgres"}, Commands: []string{"/usr/bin/psql"}},
+		},
+		{
+			line: `deploy ALL = /usr/bin/env FOO\=1 /bin/echo a\,b\:c`,
+			want: &SudoRule{Users: []string{"deploy"}, Hosts: []string{"ALL"}, Commands: []string{"/usr/bin/env FOO=1 /bin/echo a,b:c"}},
+		},
+		{line: "Defaults env_reset"},
+		{line: "Defaults:alice !requiretty"},
+		{line: "Cmnd_Alias SERVICES = /usr/bin/systemctl"},
+		{line: "alice ALL"},
+		{line: "alice = ALL"},
+		{line: "alice ALL = (root /bin/ls"},
+		{line: "alice ALL = NOPASSWD:"},
+		{line: "alice ALL = /bin/ls : web2 = /bin/cat"},
+	}
+	for _, tt := range tests {
+		got, ok := parseSudoRule(tt.line)
+		if tt.want == nil {
+			if ok {
+				t.Errorf("parseSudoRule(%q) = %+v, want it left unparsed", tt.line, got)
+			}
+			continue
+		}
+		if !ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseSudoRule(%q) = %+v, %v; want %+v", tt.line, got, ok, tt.want)
+		}
+	}
+}
+
+func TestEscapeSudoCommand(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "/usr/bin/systemctl restart nginx", want: "/usr/bin/systemctl restart nginx"},
+		{in: "/usr/bin/env FOO=1", want: `/usr/bin/env FOO\=1`},
+		{in: "/bin/echo a,b:c", want: `/bin/echo a\,b\:c`},
+		{in: `/bin/printf a\nb`, want: `/bin/printf a\\nb`},
+	}
+	for _, tt := range tests {
+		got := escapeSudoCommand(tt.in)
+		if got != tt.want {
+			t.Errorf("escapeSudoCommand(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+		if back := unescapeSudoCommand(got); back != tt.in {
+			t.Errorf("unescapeSudoCommand(%q) = %q, want %q", got, back, tt.in)
+		}
+	}
+
+	// Rendered rules parse back to the same rule
+	rule := SudoRule{
+		Users:    []string{"deploy"},
+		Hosts:    []string{"ALL"},
+		RunAs:    []string{"root"},
+		NoPasswd: true,
+		Commands: []string{"/usr/bin/env A=1 /bin/echo x,y", "/usr/bin/id"},
+	}
+	got, ok := parseSudoRule(rule.String())
+	if !ok || !reflect.DeepEqual(*got, rule) {
+		t.Errorf("round trip of %q = %+v, %v; want %+v", rule.String(), got, ok, rule)
+	}
+}
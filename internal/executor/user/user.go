@@ -3,19 +3,25 @@ package user
 import (
 	"context"
 	"fmt"
-	"os/exec"
+	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
+	"sync"
 
 	"einfra/agent/internal/executor"
 )
 
 // Executor handles user management
-type Executor struct{}
+type Executor struct {
+	// lockStatePath records account expiry dates saved by user_lock
+	lockStatePath string
+	lockMu        sync.Mutex
+}
 
-// NewExecutor creates a user executor
-func NewExecutor() *Executor {
-	return &Executor{}
+// NewExecutor creates a user executor keeping its state in dataDir
+func NewExecutor(dataDir string) *Executor {
+	return &Executor{lockStatePath: filepath.Join(dataDir, "user_locks.json")}
 }
 
 // SupportedActions returns supported actions
@@ -24,7 +30,25 @@ func (e *Executor) SupportedActions() []string {
 		"user_list",
 		"user_add",
 		"user_delete",
+		"user_modify",
+		"user_lock",
+		"user_unlock",
+		"user_set_password",
+		"user_get_expiry",
+		"user_set_expiry",
+		"user_ssh_keys_list",
+		"user_ssh_key_add",
+		"user_ssh_key_remove",
+		"user_ssh_keys_replace",
+		"sudoers_list",
+		"sudoers_set",
+		"sudoers_remove",
 		"group_list",
+		"group_add",
+		"group_delete",
+		"group_modify",
+		"group_add_member",
+		"group_remove_member",
 	}
 }
 
@@ -37,13 +61,49 @@ func (e *Executor) Execute(ctx context.Context, action *executor.Action) *execut
 
 	switch action.Type {
 	case "user_list":
-		return e.listUsers(ctx, result)
+		return e.inventoryUsers(ctx, action, result)
 	case "user_add":
 		return e.addUser(ctx, action, result)
 	case "user_delete":
 		return e.deleteUser(ctx, action, result)
+	case "user_modify":
+		return e.modifyUser(ctx, action, result)
+	case "user_lock":
+		return e.lockUser(ctx, action, result, true)
+	case "user_unlock":
+		return e.lockUser(ctx, action, result, false)
+	case "user_set_password":
+		return e.setPassword(ctx, action, result)
+	case "user_get_expiry":
+		return e.getExpiry(ctx, action, result)
+	case "user_set_expiry":
+		return e.setExpiry(ctx, action, result)
+	case "user_ssh_keys_list":
+		return e.listSSHKeys(ctx, action, result)
+	case "user_ssh_key_add":
+		return e.addSSHKeys(ctx, action, result)
+	case "user_ssh_key_remove":
+		return e.removeSSHKeys(ctx, action, result)
+	case "user_ssh_keys_replace":
+		return e.replaceSSHKeys(ctx, action, result)
+	case "sudoers_list":
+		return e.listSudoers(ctx, action, result)
+	case "sudoers_set":
+		return e.setSudoers(ctx, action, result)
+	case "sudoers_remove":
+		return e.removeSudoers(ctx, action, result)
 	case "group_list":
 		return e.listGroups(ctx, result)
+	case "group_add":
+		return e.addGroup(ctx, action, result)
+	case "group_delete":
+		return e.deleteGroup(ctx, action, result)
+	case "group_modify":
+		return e.modifyGroup(ctx, action, result)
+	case "group_add_member":
+		return e.changeMembers(ctx, action, result, true)
+	case "group_remove_member":
+		return e.changeMembers(ctx, action, result, false)
 	default:
 		result.Success = false
 		result.Error = "unknown user action"
@@ -51,130 +111,279 @@ func (e *Executor) Execute(ctx context.Context, action *executor.Action) *execut
 	}
 }
 
-// listUsers lists all users
-func (e *Executor) listUsers(ctx context.Context, result *executor.Result) *executor.Result {
-	var cmd *exec.Cmd
-
-	if runtime.GOOS == "linux" {
-		cmd = exec.CommandContext(ctx, "getent", "passwd")
-	} else if runtime.GOOS == "windows" {
-		cmd = exec.CommandContext(ctx, "powershell", "-Command",
-			"Get-LocalUser | ConvertTo-Json")
+// addUser creates a user with optional uid, groups, home, shell, comment
+// and a pre-hashed password
+func (e *Executor) addUser(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	username, err := nameParam(action, "username")
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
 	}
-
-	output, err := cmd.CombinedOutput()
+	opts, err := accountParams(action)
 	if err != nil {
 		result.Success = false
-		result.Error = fmt.Sprintf("failed to list users: %v", err)
+		result.Error = err.Error()
 		return result
 	}
-
-	result.Output = string(output)
-	result.Success = true
-	return result
-}
-
-// addUser creates a new user
-func (e *Executor) addUser(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
-	username, ok := action.Params["username"].(string)
-	if !ok {
+	hash, err := passwordHashParam(action, false)
+	if err != nil {
 		result.Success = false
-		result.Error = "missing 'username' parameter"
+		result.Error = err.Error()
 		return result
 	}
 
-	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "linux":
+		system := action.BoolParam("system", false)
+		args := opts.usermodArgs()
+		if system {
+			args = append(args, "-r")
+		}
+		if action.BoolParam("create_home", !system) {
+			args = append(args, "-m")
+		} else {
+			args = append(args, "-M")
+		}
+		output, err := run(ctx, "useradd", append(args, username)...)
+		result.Output = output
+		if err != nil {
+			result.Success = false
+			result.Error = fmt.Sprintf("failed to add user: %v", err)
+			return result
+		}
 
-	if runtime.GOOS == "linux" {
-		cmd = exec.CommandContext(ctx, "useradd", username)
-	} else if runtime.GOOS == "windows" {
-		cmd = exec.CommandContext(ctx, "powershell", "-Command",
-			fmt.Sprintf("New-LocalUser -Name %s -NoPassword", username))
-	}
+		if hash != "" {
+			output, err := setPasswordHash(ctx, username, hash)
+			result.Output += output
+			if err != nil {
+				result.Success = false
+				result.Error = fmt.Sprintf("user created but setting the password failed: %v", err)
+				return result
+			}
+		}
+		if account, err := lookupAccount(ctx, username); err == nil {
+			result.Data["user"] = account
+		}
 
-	output, err := cmd.CombinedOutput()
-	result.Output = string(output)
+	case "windows":
+		if err := rejectParams(action, "windows", "uid", "group", "home", "shell", "system", "create_home", "password_hash"); err != nil {
+			return failResult(result, "", err)
+		}
+		command := fmt.Sprintf("New-LocalUser -Name '%s' -NoPassword", username)
+		if opts.comment != "" {
+			command += " -Description " + psQuote(opts.comment)
+		}
+		for _, group := range opts.groups {
+			command += fmt.Sprintf("; Add-LocalGroupMember -Group '%s' -Member '%s'", group, username)
+		}
+		output, err := powershell(ctx, command)
+		result.Output = output
+		if err != nil {
+			result.Success = false
+			result.Error = fmt.Sprintf("failed to add user: %v", err)
+			return result
+		}
 
-	if err != nil {
-		result.Success = false
-		result.Error = fmt.Sprintf("failed to add user: %v", err)
-	} else {
-		result.Success = true
+	default:
+		return failResult(result, "", unsupported(runtime.GOOS, "user management"))
 	}
 
+	result.Data["username"] = username
+	result.Success = true
 	return result
 }
 
-// deleteUser removes a user
-func (e *Executor) deleteUser(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
-	username, ok := action.Params["username"].(string)
-	if !ok {
+// modifyUser changes an existing user's name, uid, groups, home, shell or comment
+func (e *Executor) modifyUser(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	username, err := nameParam(action, "username")
+	if err != nil {
 		result.Success = false
-		result.Error = "missing 'username' parameter"
+		result.Error = err.Error()
 		return result
 	}
+	opts, err := accountParams(action)
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
+	newName := username
+	if action.HasParam("new_username") {
+		if newName, err = nameParam(action, "new_username"); err != nil {
+			result.Success = false
+			result.Error = err.Error()
+			return result
+		}
+	}
 
-	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "linux":
+		args := opts.usermodArgs()
+		if len(opts.groups) > 0 && action.BoolParam("append_groups", false) {
+			args = append(args, "-a")
+		}
+		if opts.home != "" && action.BoolParam("move_home", false) {
+			args = append(args, "-m")
+		}
+		if newName != username {
+			args = append(args, "-l", newName)
+		}
+		if len(args) == 0 {
+			result.Success = false
+			result.Error = "no changes requested"
+			return result
+		}
 
-	if runtime.GOOS == "linux" {
-		cmd = exec.CommandContext(ctx, "userdel", username)
-	} else if runtime.GOOS == "windows" {
-		cmd = exec.CommandContext(ctx, "powershell", "-Command",
-			fmt.Sprintf("Remove-LocalUser -Name %s", username))
-	}
+		output, err := run(ctx, "usermod", append(args, username)...)
+		result.Output = output
+		if err != nil {
+			result.Success = false
+			result.Error = fmt.Sprintf("failed to modify user: %v", err)
+			return result
+		}
+		if account, err := lookupAccount(ctx, newName); err == nil {
+			result.Data["user"] = account
+		}
 
-	output, err := cmd.CombinedOutput()
-	result.Output = string(output)
+	case "windows":
+		if err := rejectParams(action, "windows", "uid", "group", "home", "shell", "move_home"); err != nil {
+			return failResult(result, "", err)
+		}
+		// Local groups can only be joined here; leaving them is a group action
+		if len(opts.groups) > 0 && !action.BoolParam("append_groups", false) {
+			return failResult(result, "", unsupported("windows", "replacing group memberships (set append_groups)"))
+		}
 
-	if err != nil {
-		result.Success = false
-		result.Error = fmt.Sprintf("failed to delete user: %v", err)
-	} else {
-		result.Success = true
+		var commands []string
+		if action.HasParam("comment") {
+			commands = append(commands, fmt.Sprintf("Set-LocalUser -Name '%s' -Description %s", username, psQuote(opts.comment)))
+		}
+		for _, group := range opts.groups {
+			commands = append(commands, fmt.Sprintf("Add-LocalGroupMember -Group '%s' -Member '%s'", group, username))
+		}
+		if newName != username {
+			commands = append(commands, fmt.Sprintf("Rename-LocalUser -Name '%s' -NewName '%s'", username, newName))
+		}
+		if len(commands) == 0 {
+			result.Success = false
+			result.Error = "no changes requested"
+			return result
+		}
+
+		output, err := powershell(ctx, strings.Join(commands, "; "))
+		result.Output = output
+		if err != nil {
+			result.Success = false
+			result.Error = fmt.Sprintf("failed to modify user: %v", err)
+			return result
+		}
+
+	default:
+		return failResult(result, "", unsupported(runtime.GOOS, "user management"))
 	}
 
+	result.Data["username"] = newName
+	result.Success = true
 	return result
 }
 
-// listGroups lists all groups
-func (e *Executor) listGroups(ctx context.Context, result *executor.Result) *executor.Result {
-	var cmd *exec.Cmd
+// deleteUser removes a user, and on Linux optionally its home directory and mail spool
+func (e *Executor) deleteUser(ctx context.Context, action *executor.Action, result *executor.Result) *executor.Result {
+	username, err := nameParam(action, "username")
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+		return result
+	}
 
-	if runtime.GOOS == "linux" {
-		cmd = exec.CommandContext(ctx, "getent", "group")
-	} else if runtime.GOOS == "windows" {
-		cmd = exec.CommandContext(ctx, "powershell", "-Command",
-			"Get-LocalGroup | ConvertTo-Json")
+	var output string
+	switch runtime.GOOS {
+	case "linux":
+		args := []string{username}
+		if action.BoolParam("remove_home", false) {
+			args = []string{"-r", username}
+		}
+		output, err = run(ctx, "userdel", args...)
+	case "windows":
+		if err := rejectParams(action, "windows", "remove_home"); err != nil {
+			return failResult(result, "", err)
+		}
+		output, err = powershell(ctx, fmt.Sprintf("Remove-LocalUser -Name '%s'", username))
+	default:
+		return failResult(result, "", unsupported(runtime.GOOS, "user management"))
 	}
 
-	output, err := cmd.CombinedOutput()
+	result.Output = output
 	if err != nil {
 		result.Success = false
-		result.Error = fmt.Sprintf("failed to list groups: %v", err)
+		result.Error = fmt.Sprintf("failed to delete user: %v", err)
 		return result
 	}
 
-	// Parse groups
-	groups := parseGroups(string(output))
-	result.Data["groups"] = groups
+	result.Data["username"] = username
 	result.Success = true
 	return result
 }
 
-// parseGroups parses group output
-func parseGroups(output string) []map[string]interface{} {
-	lines := strings.Split(strings.TrimSpace(output), "\n")
-	groups := make([]map[string]interface{}, 0, len(lines))
+// accountOptions are the passwd attributes shared by user_add and user_modify
+type accountOptions struct {
+	uid     int
+	hasUID  bool
+	group   string
+	groups  []string
+	home    string
+	shell   string
+	comment string
+}
 
-	for _, line := range lines {
-		parts := strings.Split(line, ":")
-		if len(parts) >= 3 {
-			groups = append(groups, map[string]interface{}{
-				"name": parts[0],
-				"gid":  parts[2],
-			})
+// accountParams reads and validates the shared account attributes
+func accountParams(action *executor.Action) (*accountOptions, error) {
+	opts := &accountOptions{}
+	var err error
+	if opts.uid, opts.hasUID, err = idParam(action, "uid"); err != nil {
+		return nil, err
+	}
+	if action.HasParam("group") {
+		if opts.group, err = nameParam(action, "group"); err != nil {
+			return nil, err
 		}
 	}
+	if opts.groups, err = namesParam(action, "groups"); err != nil {
+		return nil, err
+	}
+	if opts.home, err = pathParam(action, "home"); err != nil {
+		return nil, err
+	}
+	if opts.shell, err = pathParam(action, "shell"); err != nil {
+		return nil, err
+	}
+	if opts.comment, err = fieldParam(action, "comment"); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
 
-	return groups
+// usermodArgs renders the options as the flags useradd and usermod share
+func (o *accountOptions) usermodArgs() []string {
+	var args []string
+	if o.hasUID {
+		args = append(args, "-u", strconv.Itoa(o.uid))
+	}
+	if o.group != "" {
+		args = append(args, "-g", o.group)
+	}
+	if len(o.groups) > 0 {
+		args = append(args, "-G", strings.Join(o.groups, ","))
+	}
+	if o.home != "" {
+		args = append(args, "-d", o.home)
+	}
+	if o.shell != "" {
+		args = append(args, "-s", o.shell)
+	}
+	if o.comment != "" {
+		args = append(args, "-c", o.comment)
+	}
+	return args
 }
@@ -0,0 +1,66 @@
+package fsutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// WriteFileAtomic writes data to a temp file in the same directory and renames
+// it over path, so readers never observe a partially written file. When path
+// already exists its mode and ownership are kept unless perm is non-zero. A
+// symlink is followed and the file it points to is replaced, so the link
+// itself stays in place. A dangling symlink, or one to anything but a regular
+// file such as /dev/null, is refused
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	} else if info, lerr := os.Lstat(path); lerr == nil && info.Mode()&os.ModeSymlink != 0 {
+		return fmt.Errorf("%s is a symlink that cannot be resolved: %w", path, err)
+	}
+	dir := filepath.Dir(path)
+
+	existing, statErr := os.Stat(path)
+	if statErr == nil && !existing.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a regular file", path)
+	}
+	if perm == 0 {
+		perm = 0644
+		if statErr == nil {
+			perm = existing.Mode().Perm()
+		}
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temp file: %w", err)
+	}
+
+	if err := os.Chmod(tmpName, perm); err != nil {
+		return fmt.Errorf("failed to set permissions: %w", err)
+	}
+	if statErr == nil {
+		copyOwner(existing, tmpName)
+	}
+
+	if err := os.Rename(tmpName, path); err != nil {
+		return fmt.Errorf("failed to replace file: %w", err)
+	}
+
+	syncDir(dir)
+	return nil
+}
@@ -0,0 +1,121 @@
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "new.conf")
+
+	if err := WriteFileAtomic(path, []byte("one"), 0); err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, path, "one", 0644)
+
+	if err := os.Chmod(path, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFileAtomic(path, []byte("two"), 0); err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, path, "two", 0600)
+
+	if err := WriteFileAtomic(path, []byte("three"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, path, "three", 0640)
+}
+
+func TestWriteFileAtomicSymlink(t *testing.T) {
+	dir := t.TempDir()
+	targetDir := filepath.Join(dir, "real")
+	if err := os.Mkdir(targetDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(targetDir, "resolv.conf")
+	if err := os.WriteFile(target, []byte("old"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "resolv.conf")
+	if err := os.Symlink("real/resolv.conf", link); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+
+	if err := WriteFileAtomic(link, []byte("new"), 0); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Lstat(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("%s was replaced by a regular file", link)
+	}
+	assertFile(t, target, "new", 0640)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("temp file left behind or created next to the link: %v", entries)
+	}
+}
+
+func TestWriteFileAtomicDanglingSymlink(t *testing.T) {
+	dir := t.TempDir()
+	link := filepath.Join(dir, "conf")
+	if err := os.Symlink("missing", link); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+
+	if err := WriteFileAtomic(link, []byte("x"), 0644); err == nil {
+		t.Error("WriteFileAtomic succeeded on a dangling symlink")
+	}
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("dangling symlink was replaced: %v", err)
+	}
+}
+
+// assertFile checks a file's content and permissions
+func assertFile(t *testing.T, path, content string, perm os.FileMode) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != content {
+		t.Errorf("%s = %q, want %q", path, data, content)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != perm {
+		t.Errorf("%s mode = %o, want %o", path, info.Mode().Perm(), perm)
+	}
+}
+
+func TestWriteFileAtomicNotRegular(t *testing.T) {
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "sub")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink("sub", link); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+
+	for _, path := range []string{sub, link} {
+		if err := WriteFileAtomic(path, []byte("x"), 0644); err == nil {
+			t.Errorf("WriteFileAtomic(%s) succeeded on a directory", path)
+		}
+	}
+	if info, err := os.Stat(sub); err != nil || !info.IsDir() {
+		t.Errorf("directory was replaced: %v", err)
+	}
+}
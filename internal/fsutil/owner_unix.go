@@ -0,0 +1,23 @@
+//go:build !windows
+
+package fsutil
+
+import (
+	"os"
+	"syscall"
+)
+
+// copyOwner gives path the same uid/gid as info, ignoring failures when not root
+func copyOwner(info os.FileInfo, path string) {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		os.Lchown(path, int(st.Uid), int(st.Gid))
+	}
+}
+
+// syncDir flushes a directory entry so a rename survives a crash
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
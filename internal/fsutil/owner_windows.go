@@ -0,0 +1,11 @@
+//go:build windows
+
+package fsutil
+
+import "os"
+
+// copyOwner is a no-op on Windows, where ownership lives in ACLs
+func copyOwner(info os.FileInfo, path string) {}
+
+// syncDir is a no-op on Windows, which cannot open directories for sync
+func syncDir(dir string) {}
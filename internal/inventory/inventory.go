@@ -0,0 +1,59 @@
+package inventory
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+)
+
+// Delta lists records added, removed or changed between two inventories
+type Delta[T any] struct {
+	Added   []T `json:"added"`
+	Removed []T `json:"removed"`
+	Changed []T `json:"changed"`
+}
+
+// Empty reports whether the delta has no entries
+func (d Delta[T]) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Diff compares a previously reported inventory with the current one. key
+// identifies a record across inventories and same reports whether two records
+// with the same key are unchanged. Removed records are ordered by key
+func Diff[T any](previous, current []T, key func(T) string, same func(a, b T) bool) Delta[T] {
+	old := make(map[string]T, len(previous))
+	for _, r := range previous {
+		old[key(r)] = r
+	}
+
+	delta := Delta[T]{
+		Added:   make([]T, 0),
+		Removed: make([]T, 0),
+		Changed: make([]T, 0),
+	}
+	for _, r := range current {
+		k := key(r)
+		prev, existed := old[k]
+		delete(old, k)
+		switch {
+		case !existed:
+			delta.Added = append(delta.Added, r)
+		case !same(prev, r):
+			delta.Changed = append(delta.Changed, r)
+		}
+	}
+	for _, r := range old {
+		delta.Removed = append(delta.Removed, r)
+	}
+	sort.Slice(delta.Removed, func(i, j int) bool { return key(delta.Removed[i]) < key(delta.Removed[j]) })
+	return delta
+}
+
+// Checksum hashes an inventory so both sides can confirm they agree
+func Checksum[T any](records []T) string {
+	data, _ := json.Marshal(records)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
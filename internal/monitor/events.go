@@ -0,0 +1,86 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"einfra/agent/internal/logger"
+	"einfra/agent/internal/transport"
+)
+
+// eventQueue buffers events for a backend endpoint and pushes them in batches,
+// keeping them for the next attempt when the backend is unreachable
+type eventQueue struct {
+	transport  *transport.Client
+	path       string
+	maxPending int
+
+	mu      sync.Mutex
+	pending []interface{}
+}
+
+// newEventQueue creates a queue that posts to path
+func newEventQueue(transport *transport.Client, path string, maxPending int) *eventQueue {
+	return &eventQueue{
+		transport:  transport,
+		path:       path,
+		maxPending: maxPending,
+	}
+}
+
+// add queues an event, dropping the oldest when the queue is full
+func (q *eventQueue) add(event interface{}) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.pending) >= q.maxPending {
+		q.pending = q.pending[1:]
+	}
+	q.pending = append(q.pending, event)
+}
+
+// flush pushes all queued events to the backend
+func (q *eventQueue) flush(ctx context.Context) {
+	q.mu.Lock()
+	if len(q.pending) == 0 {
+		q.mu.Unlock()
+		return
+	}
+	events := q.pending
+	q.pending = nil
+	q.mu.Unlock()
+
+	payload := map[string]interface{}{
+		"events": events,
+	}
+
+	resp, err := q.transport.Post(ctx, q.path, payload)
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode >= 400 {
+			err = fmt.Errorf("HTTP %d", resp.StatusCode)
+		}
+	}
+	if err != nil {
+		logger.Warn().
+			Err(err).
+			Str("path", q.path).
+			Int("events", len(events)).
+			Msg("Failed to push events")
+
+		// Requeue ahead of anything recorded meanwhile
+		q.mu.Lock()
+		q.pending = append(events, q.pending...)
+		if len(q.pending) > q.maxPending {
+			q.pending = q.pending[len(q.pending)-q.maxPending:]
+		}
+		q.mu.Unlock()
+		return
+	}
+
+	logger.Debug().
+		Str("path", q.path).
+		Int("events", len(events)).
+		Msg("Events pushed successfully")
+}
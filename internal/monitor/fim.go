@@ -0,0 +1,391 @@
+package monitor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"einfra/agent/internal/logger"
+	"einfra/agent/internal/transport"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	// fimMaxHashSize skips content hashing for very large files
+	fimMaxHashSize = 512 * 1024 * 1024
+	// fimMaxPending caps buffered events while the backend is unreachable
+	fimMaxPending = 10000
+	// fimFlushInterval batches events before pushing them
+	fimFlushInterval = 5 * time.Second
+)
+
+// FIM event types
+const (
+	FIMCreated           = "created"
+	FIMModified          = "modified"
+	FIMDeleted           = "deleted"
+	FIMPermissionChanged = "permission_changed"
+)
+
+// FileState is the baseline metadata recorded for a watched path
+type FileState struct {
+	Path       string    `json:"path"`
+	IsDir      bool      `json:"is_dir"`
+	Size       int64     `json:"size"`
+	Mode       string    `json:"mode"`
+	UID        int       `json:"uid"`
+	GID        int       `json:"gid"`
+	ModTime    time.Time `json:"mod_time"`
+	SHA256     string    `json:"sha256,omitempty"`
+	LinkTarget string    `json:"link_target,omitempty"`
+}
+
+// FIMEvent describes a change detected against the baseline
+type FIMEvent struct {
+	Type      string     `json:"type"`
+	Path      string     `json:"path"`
+	Before    *FileState `json:"before,omitempty"`
+	After     *FileState `json:"after,omitempty"`
+	Source    string     `json:"source"` // "watch" or "rescan"
+	Timestamp time.Time  `json:"timestamp"`
+}
+
+// FileIntegrityMonitor watches critical paths and reports changes
+type FileIntegrityMonitor struct {
+	transport      *transport.Client
+	paths          []string
+	dbPath         string
+	rescanInterval time.Duration
+
+	events  *eventQueue
+	watcher *fsnotify.Watcher
+
+	mu       sync.Mutex
+	baseline map[string]*FileState
+	dirty    bool
+}
+
+// NewFileIntegrityMonitor creates a file integrity monitor
+func NewFileIntegrityMonitor(transport *transport.Client, paths []string, dbPath string, rescanInterval time.Duration) *FileIntegrityMonitor {
+	if rescanInterval <= 0 {
+		rescanInterval = time.Hour
+	}
+
+	return &FileIntegrityMonitor{
+		transport:      transport,
+		paths:          paths,
+		dbPath:         dbPath,
+		rescanInterval: rescanInterval,
+		events:         newEventQueue(transport, "/api/v1/agent/fim/events", fimMaxPending),
+		baseline:       make(map[string]*FileState),
+	}
+}
+
+// Start loads the baseline and begins watching for changes
+func (m *FileIntegrityMonitor) Start(ctx context.Context) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to create file watcher, FIM disabled")
+		return
+	}
+	defer watcher.Close()
+	m.watcher = watcher
+
+	hadBaseline := m.loadBaseline()
+	if hadBaseline {
+		// Report anything that changed while the agent was not running
+		m.rescan()
+	} else {
+		m.mu.Lock()
+		m.baseline = m.scan()
+		m.dirty = true
+		m.mu.Unlock()
+	}
+	m.saveBaseline()
+
+	logger.Info().
+		Strs("paths", m.paths).
+		Int("entries", len(m.baseline)).
+		Msg("File integrity monitor started")
+
+	rescanTicker := time.NewTicker(m.rescanInterval)
+	defer rescanTicker.Stop()
+	flushTicker := time.NewTicker(fimFlushInterval)
+	defer flushTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			m.saveBaseline()
+			logger.Info().Msg("File integrity monitor stopped")
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			m.handleEvent(event)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			// Overflows mean we may have missed events, so verify everything
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				logger.Warn().Msg("File watcher queue overflowed, rescanning")
+				m.rescan()
+				continue
+			}
+			logger.Warn().Err(err).Msg("File watcher error")
+		case <-rescanTicker.C:
+			m.rescan()
+		case <-flushTicker.C:
+			m.events.flush(ctx)
+			m.saveBaseline()
+		}
+	}
+}
+
+// handleEvent re-reads a single path after a watcher notification. Hashing
+// and walking happen before taking m.mu, which is held only to update the baseline
+func (m *FileIntegrityMonitor) handleEvent(event fsnotify.Event) {
+	path := filepath.Clean(event.Name)
+
+	after, err := statFile(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			logger.Debug().Err(err).Str("path", path).Msg("FIM stat failed")
+			return
+		}
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		// Path is gone: report it and everything that was below it
+		for p, before := range m.baseline {
+			if p == path || strings.HasPrefix(p, path+string(filepath.Separator)) {
+				m.record(FIMDeleted, p, before, nil, "watch")
+				delete(m.baseline, p)
+				m.dirty = true
+			}
+		}
+		return
+	}
+
+	// A new directory may already contain files by the time we see it
+	if after.IsDir {
+		m.mu.Lock()
+		_, known := m.baseline[path]
+		m.mu.Unlock()
+		if !known {
+			states := m.walk(path)
+
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			for p, st := range states {
+				if _, exists := m.baseline[p]; !exists {
+					m.record(FIMCreated, p, nil, st, "watch")
+					m.baseline[p] = st
+					m.dirty = true
+				}
+			}
+			return
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.compare(path, m.baseline[path], after, "watch")
+}
+
+// rescan walks all watched paths and diffs them against the baseline
+func (m *FileIntegrityMonitor) rescan() {
+	current := m.scan()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for p, before := range m.baseline {
+		if _, ok := current[p]; !ok {
+			m.record(FIMDeleted, p, before, nil, "rescan")
+		}
+	}
+	for p, after := range current {
+		m.compare(p, m.baseline[p], after, "rescan")
+	}
+
+	m.baseline = current
+	m.dirty = true
+
+	logger.Debug().Int("entries", len(current)).Msg("FIM rescan completed")
+}
+
+// compare records an event if after differs from before, and updates the baseline
+// Caller must hold m.mu
+func (m *FileIntegrityMonitor) compare(path string, before, after *FileState, source string) {
+	switch {
+	case before == nil:
+		m.record(FIMCreated, path, nil, after, source)
+	case before.SHA256 != after.SHA256 || before.Size != after.Size || before.LinkTarget != after.LinkTarget:
+		m.record(FIMModified, path, before, after, source)
+	case before.Mode != after.Mode || before.UID != after.UID || before.GID != after.GID:
+		m.record(FIMPermissionChanged, path, before, after, source)
+	case !before.ModTime.Equal(after.ModTime) && !after.IsDir:
+		m.record(FIMModified, path, before, after, source)
+	}
+	m.baseline[path] = after
+	m.dirty = true
+}
+
+// record queues an event for the next flush
+// Caller must hold m.mu
+func (m *FileIntegrityMonitor) record(eventType, path string, before, after *FileState, source string) {
+	m.events.add(FIMEvent{
+		Type:      eventType,
+		Path:      path,
+		Before:    before,
+		After:     after,
+		Source:    source,
+		Timestamp: time.Now().UTC(),
+	})
+
+	logger.Info().
+		Str("type", eventType).
+		Str("path", path).
+		Msg("File integrity change detected")
+}
+
+// scan builds a fresh snapshot of all watched paths
+func (m *FileIntegrityMonitor) scan() map[string]*FileState {
+	states := make(map[string]*FileState)
+	for _, root := range m.paths {
+		for p, st := range m.walk(filepath.Clean(root)) {
+			states[p] = st
+		}
+	}
+	return states
+}
+
+// walk records every entry below root and registers directory watches
+func (m *FileIntegrityMonitor) walk(root string) map[string]*FileState {
+	states := make(map[string]*FileState)
+
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			logger.Debug().Err(err).Str("path", path).Msg("FIM walk error")
+			return nil
+		}
+
+		st, err := statFile(path)
+		if err != nil {
+			return nil
+		}
+		states[path] = st
+
+		if d.IsDir() && m.watcher != nil {
+			if err := m.watcher.Add(path); err != nil {
+				logger.Debug().Err(err).Str("path", path).Msg("Failed to watch directory")
+			}
+		}
+		return nil
+	})
+
+	return states
+}
+
+// loadBaseline reads the persisted baseline, reporting whether one existed
+func (m *FileIntegrityMonitor) loadBaseline() bool {
+	data, err := os.ReadFile(m.dbPath)
+	if err != nil {
+		return false
+	}
+
+	var baseline map[string]*FileState
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		logger.Warn().Err(err).Str("path", m.dbPath).Msg("Corrupt FIM baseline, rebuilding")
+		return false
+	}
+
+	m.mu.Lock()
+	m.baseline = baseline
+	m.mu.Unlock()
+	return true
+}
+
+// saveBaseline atomically persists the baseline if it changed
+func (m *FileIntegrityMonitor) saveBaseline() {
+	m.mu.Lock()
+	if !m.dirty {
+		m.mu.Unlock()
+		return
+	}
+	data, err := json.Marshal(m.baseline)
+	m.dirty = false
+	m.mu.Unlock()
+
+	if err != nil {
+		logger.Warn().Err(err).Msg("Failed to marshal FIM baseline")
+		return
+	}
+
+	tmp := m.dbPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		logger.Warn().Err(err).Msg("Failed to write FIM baseline")
+		return
+	}
+	if err := os.Rename(tmp, m.dbPath); err != nil {
+		logger.Warn().Err(err).Msg("Failed to save FIM baseline")
+	}
+}
+
+// statFile captures metadata and content hash for a path without following symlinks
+func statFile(path string) (*FileState, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	uid, gid := fileOwner(info)
+	st := &FileState{
+		Path:    path,
+		IsDir:   info.IsDir(),
+		Size:    info.Size(),
+		Mode:    info.Mode().String(),
+		UID:     uid,
+		GID:     gid,
+		ModTime: info.ModTime().UTC(),
+	}
+
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		st.LinkTarget, _ = os.Readlink(path)
+	case info.Mode().IsRegular() && info.Size() <= fimMaxHashSize:
+		st.SHA256, err = hashFile(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return st, nil
+}
+
+// hashFile returns the hex SHA-256 of a file's content
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package monitor
+
+import "os"
+
+// fileOwner is not tracked on Windows, where ownership lives in ACLs
+func fileOwner(info os.FileInfo) (int, int) {
+	return -1, -1
+}
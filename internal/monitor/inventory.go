@@ -0,0 +1,165 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"einfra/agent/internal/fsutil"
+	"einfra/agent/internal/inventory"
+	"einfra/agent/internal/logger"
+	"einfra/agent/internal/transport"
+)
+
+// errInventoryConflict means the backend's copy does not match our baseline
+var errInventoryConflict = errors.New("backend requested a full inventory")
+
+// inventoryReporter pushes an inventory of records, sending only the delta
+// against the last inventory the backend acknowledged
+type inventoryReporter[T any] struct {
+	transport *transport.Client
+	interval  time.Duration
+	statePath string
+
+	// name labels log messages, e.g. "Package inventory"
+	name string
+	// endpoint receives the reports
+	endpoint string
+	// field holds the records in full reports and in the saved baseline
+	field string
+
+	read     func(ctx context.Context) ([]T, error)
+	checksum func(records []T) string
+	diff     func(previous, current []T) inventory.Delta[T]
+}
+
+// Start reports once immediately, then every interval
+func (r *inventoryReporter[T]) Start(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	logger.Info().Dur("interval", r.interval).Msgf("%s reporter started", r.name)
+
+	r.report(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info().Msgf("%s reporter stopped", r.name)
+			return
+		case <-ticker.C:
+			r.report(ctx)
+		}
+	}
+}
+
+// report sends a delta, or the full inventory when there is no baseline or
+// the backend rejects the delta with 409 Conflict
+func (r *inventoryReporter[T]) report(ctx context.Context) {
+	records, err := r.read(ctx)
+	if err != nil {
+		logger.Warn().Err(err).Msgf("Failed to read %s", strings.ToLower(r.name))
+		return
+	}
+	checksum := r.checksum(records)
+
+	prevChecksum, prev, err := r.loadState()
+	if err != nil && !os.IsNotExist(err) {
+		logger.Warn().Err(err).Msgf("Discarding unreadable %s baseline", strings.ToLower(r.name))
+	}
+
+	if err == nil {
+		if prevChecksum == checksum {
+			logger.Debug().Msgf("%s unchanged", r.name)
+			return
+		}
+		delta := r.diff(prev, records)
+		err = r.push(ctx, map[string]interface{}{
+			"full":          false,
+			"base_checksum": prevChecksum,
+			"checksum":      checksum,
+			"added":         delta.Added,
+			"removed":       delta.Removed,
+			"changed":       delta.Changed,
+		})
+		if err == nil {
+			logger.Info().
+				Int("added", len(delta.Added)).
+				Int("removed", len(delta.Removed)).
+				Int("changed", len(delta.Changed)).
+				Msgf("%s delta pushed", r.name)
+			r.saveState(checksum, records)
+			return
+		}
+		if !errors.Is(err, errInventoryConflict) {
+			logger.Warn().Err(err).Msgf("Failed to push %s delta", strings.ToLower(r.name))
+			return
+		}
+	}
+
+	err = r.push(ctx, map[string]interface{}{
+		"full":     true,
+		"checksum": checksum,
+		r.field:    records,
+	})
+	if err != nil {
+		logger.Warn().Err(err).Msgf("Failed to push %s", strings.ToLower(r.name))
+		return
+	}
+
+	logger.Info().Int(r.field, len(records)).Msgf("Full %s pushed", strings.ToLower(r.name))
+	r.saveState(checksum, records)
+}
+
+// push posts a payload to the inventory endpoint
+func (r *inventoryReporter[T]) push(ctx context.Context, payload map[string]interface{}) error {
+	resp, err := r.transport.Post(ctx, r.endpoint, payload)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusConflict:
+		return errInventoryConflict
+	case resp.StatusCode >= 400:
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// loadState reads the acknowledged baseline
+func (r *inventoryReporter[T]) loadState() (string, []T, error) {
+	data, err := os.ReadFile(r.statePath)
+	if err != nil {
+		return "", nil, err
+	}
+	var state map[string]json.RawMessage
+	if err := json.Unmarshal(data, &state); err != nil {
+		return "", nil, err
+	}
+	var checksum string
+	var records []T
+	if err := json.Unmarshal(state["checksum"], &checksum); err != nil {
+		return "", nil, err
+	}
+	if err := json.Unmarshal(state[r.field], &records); err != nil {
+		return "", nil, err
+	}
+	return checksum, records, nil
+}
+
+// saveState records what the backend now holds
+func (r *inventoryReporter[T]) saveState(checksum string, records []T) {
+	data, err := json.Marshal(map[string]interface{}{"checksum": checksum, r.field: records})
+	if err == nil {
+		err = fsutil.WriteFileAtomic(r.statePath, data, 0600)
+	}
+	if err != nil {
+		logger.Warn().Err(err).Msgf("Failed to save %s baseline", strings.ToLower(r.name))
+	}
+}
@@ -0,0 +1,194 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"einfra/agent/internal/executor/system"
+	"einfra/agent/internal/fsutil"
+	"einfra/agent/internal/logger"
+	"einfra/agent/internal/transport"
+)
+
+// listenerMaxPending caps buffered events while the backend is unreachable
+const listenerMaxPending = 1000
+
+// ListenerEvent is pushed to the backend when a new listening socket appears
+type ListenerEvent struct {
+	Type      string          `json:"type"` // "listener_opened"
+	Message   string          `json:"message"`
+	Listener  system.Listener `json:"listener"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// ListenerWatcher periodically compares listening sockets against the last
+// scan and reports new ones as security events
+type ListenerWatcher struct {
+	source    *system.Executor
+	interval  time.Duration
+	statePath string
+	events    *eventQueue
+
+	// baseline holds the keys of the listeners already known
+	baseline map[string]bool
+	// unconfirmed holds new listeners seen by one scan only; they are
+	// reported if the next scan still finds them
+	unconfirmed map[string]bool
+}
+
+// NewListenerWatcher creates a listener watcher
+func NewListenerWatcher(transport *transport.Client, source *system.Executor, interval time.Duration, statePath string) *ListenerWatcher {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	return &ListenerWatcher{
+		source:    source,
+		interval:  interval,
+		statePath: statePath,
+		events:    newEventQueue(transport, "/api/v1/agent/security/events", listenerMaxPending),
+	}
+}
+
+// Start scans once immediately, then every interval
+func (w *ListenerWatcher) Start(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	if err := w.loadState(); err != nil && !os.IsNotExist(err) {
+		logger.Warn().Err(err).Msg("Discarding unreadable listener baseline")
+	}
+
+	logger.Info().Dur("interval", w.interval).Msg("Listener watcher started")
+
+	w.scan(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info().Msg("Listener watcher stopped")
+			return
+		case <-ticker.C:
+			w.scan(ctx)
+		}
+	}
+}
+
+// scan queues an event for each new listener found by two consecutive
+// scans, then pushes queued events. The first scan without a baseline only
+// records one. UDP sockets on ephemeral ports are client sockets and ignored
+func (w *ListenerWatcher) scan(ctx context.Context) {
+	listeners, err := w.source.Listeners(ctx)
+	if err != nil {
+		logger.Warn().Err(err).Msg("Failed to read listening sockets")
+		return
+	}
+
+	known := make(map[string]bool, len(listeners))
+	unconfirmed := make(map[string]bool)
+	for _, l := range listeners {
+		key := l.Key()
+		if known[key] || unconfirmed[key] || l.Ephemeral() {
+			continue
+		}
+		if w.baseline == nil || w.baseline[key] {
+			known[key] = true
+			continue
+		}
+		if !w.unconfirmed[key] {
+			unconfirmed[key] = true
+			continue
+		}
+		known[key] = true
+
+		msg := fmt.Sprintf("%s is listening on %s", describeOwner(l), endpoint(l))
+		logger.Warn().
+			Str("protocol", l.Protocol).
+			Str("address", l.Address).
+			Uint32("port", l.Port).
+			Int32("pid", l.PID).
+			Str("process", l.Process).
+			Msg("New listening socket")
+
+		w.events.add(ListenerEvent{
+			Type:      "listener_opened",
+			Message:   msg,
+			Listener:  l,
+			Timestamp: time.Now(),
+		})
+	}
+	w.unconfirmed = unconfirmed
+
+	if w.baseline == nil {
+		logger.Info().Int("listeners", len(known)).Msg("Listener baseline recorded")
+	}
+	if !sameKeys(w.baseline, known) {
+		w.baseline = known
+		w.saveState()
+	}
+	w.events.flush(ctx)
+}
+
+// loadState reads the listeners recorded by the last scan
+func (w *ListenerWatcher) loadState() error {
+	data, err := os.ReadFile(w.statePath)
+	if err != nil {
+		return err
+	}
+	var keys []string
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return err
+	}
+	w.baseline = make(map[string]bool, len(keys))
+	for _, key := range keys {
+		w.baseline[key] = true
+	}
+	return nil
+}
+
+// saveState persists the baseline so a restart does not report every listener
+func (w *ListenerWatcher) saveState() {
+	keys := make([]string, 0, len(w.baseline))
+	for key := range w.baseline {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	data, err := json.Marshal(keys)
+	if err == nil {
+		err = fsutil.WriteFileAtomic(w.statePath, data, 0600)
+	}
+	if err != nil {
+		logger.Warn().Err(err).Msg("Failed to save listener baseline")
+	}
+}
+
+// sameKeys reports whether two key sets are equal
+func sameKeys(a, b map[string]bool) bool {
+	if a == nil || len(a) != len(b) {
+		return false
+	}
+	for key := range b {
+		if !a[key] {
+			return false
+		}
+	}
+	return true
+}
+
+// describeOwner names the process holding a listener
+func describeOwner(l system.Listener) string {
+	if l.Process == "" {
+		return "An unknown process"
+	}
+	return fmt.Sprintf("%s (PID %d)", l.Process, l.PID)
+}
+
+// endpoint formats a listener as protocol address:port
+func endpoint(l system.Listener) string {
+	if l.Protocol == "tcp6" || l.Protocol == "udp6" {
+		return fmt.Sprintf("%s [%s]:%d", l.Protocol, l.Address, l.Port)
+	}
+	return fmt.Sprintf("%s %s:%d", l.Protocol, l.Address, l.Port)
+}
@@ -0,0 +1,30 @@
+package monitor
+
+import (
+	"time"
+
+	package_executor "einfra/agent/internal/executor/package"
+	"einfra/agent/internal/transport"
+)
+
+// PackageInventoryReporter pushes the package inventory, sending only the
+// delta against the last inventory the backend acknowledged
+type PackageInventoryReporter = inventoryReporter[package_executor.InventoryRecord]
+
+// NewPackageInventoryReporter creates a package inventory reporter
+func NewPackageInventoryReporter(transport *transport.Client, source *package_executor.Executor, interval time.Duration, statePath string) *PackageInventoryReporter {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	return &PackageInventoryReporter{
+		transport: transport,
+		interval:  interval,
+		statePath: statePath,
+		name:      "Package inventory",
+		endpoint:  "/api/v1/agent/packages/inventory",
+		field:     "packages",
+		read:      source.Inventory,
+		checksum:  package_executor.InventoryChecksum,
+		diff:      package_executor.DiffInventory,
+	}
+}
@@ -0,0 +1,234 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"einfra/agent/internal/executor"
+	"einfra/agent/internal/executor/service"
+	"einfra/agent/internal/logger"
+	"einfra/agent/internal/transport"
+)
+
+// serviceEventFlushInterval retries events the backend did not accept
+const serviceEventFlushInterval = 10 * time.Second
+
+// RestartPolicy controls automatic restarts of failed services
+type RestartPolicy struct {
+	Enabled        bool
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	MaxAttempts    int
+}
+
+// ServiceEvent is pushed to the backend for each state change or restart attempt
+type ServiceEvent struct {
+	Type      string    `json:"type"` // "state_change" or "auto_restart"
+	Unit      string    `json:"unit"`
+	Message   string    `json:"message"`
+	From      string    `json:"from,omitempty"`
+	To        string    `json:"to,omitempty"`
+	SubState  string    `json:"sub_state,omitempty"`
+	Result    string    `json:"result,omitempty"`
+	ExitCode  int32     `json:"exit_code"`
+	Attempt   int       `json:"attempt,omitempty"`
+	Success   bool      `json:"success,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// restartState tracks backoff for one unit
+type restartState struct {
+	attempts    int
+	activeSince time.Time
+	timer       *time.Timer
+}
+
+// ServiceWatcher pushes service state changes and optionally restarts failed units
+type ServiceWatcher struct {
+	source   *service.Executor
+	registry *executor.Registry
+	services []string
+	interval time.Duration
+	policy   RestartPolicy
+	events   *eventQueue
+
+	restarts map[string]*restartState
+	retry    chan string
+}
+
+// NewServiceWatcher creates a service watcher
+func NewServiceWatcher(transport *transport.Client, registry *executor.Registry, source *service.Executor, services []string, interval time.Duration, policy RestartPolicy) *ServiceWatcher {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	if policy.InitialBackoff <= 0 {
+		policy.InitialBackoff = 5 * time.Second
+	}
+	if policy.MaxBackoff < policy.InitialBackoff {
+		policy.MaxBackoff = policy.InitialBackoff
+	}
+
+	return &ServiceWatcher{
+		source:   source,
+		registry: registry,
+		services: services,
+		interval: interval,
+		policy:   policy,
+		events:   newEventQueue(transport, "/api/v1/agent/services/events", 1000),
+		restarts: make(map[string]*restartState),
+		retry:    make(chan string, 16),
+	}
+}
+
+// Start watches the configured services until ctx is cancelled
+func (w *ServiceWatcher) Start(ctx context.Context) {
+	changes, err := w.source.Watch(ctx, w.services, w.interval)
+	if err != nil {
+		logger.Warn().Err(err).Msg("Service watcher disabled")
+		return
+	}
+
+	logger.Info().
+		Strs("services", w.services).
+		Bool("auto_restart", w.policy.Enabled).
+		Msg("Service watcher started")
+
+	flushTicker := time.NewTicker(serviceEventFlushInterval)
+	defer flushTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			for _, rs := range w.restarts {
+				if rs.timer != nil {
+					rs.timer.Stop()
+				}
+			}
+			logger.Info().Msg("Service watcher stopped")
+			return
+		case change, ok := <-changes:
+			if !ok {
+				return
+			}
+			w.handleChange(ctx, change)
+		case unit := <-w.retry:
+			w.restart(ctx, unit)
+		case <-flushTicker.C:
+			w.events.flush(ctx)
+		}
+	}
+}
+
+// handleChange reports a transition and schedules a restart if the unit failed
+func (w *ServiceWatcher) handleChange(ctx context.Context, change service.StateChange) {
+	msg := fmt.Sprintf("%s %s→%s", change.Unit, change.From, change.To)
+
+	logger.Info().
+		Str("unit", change.Unit).
+		Str("from", change.From).
+		Str("to", change.To).
+		Int32("exit_code", change.ExitCode).
+		Msg("Service state changed")
+
+	w.events.add(ServiceEvent{
+		Type:      "state_change",
+		Unit:      change.Unit,
+		Message:   msg,
+		From:      change.From,
+		To:        change.To,
+		SubState:  change.SubState,
+		Result:    change.Result,
+		ExitCode:  change.ExitCode,
+		Timestamp: change.Timestamp,
+	})
+	w.events.flush(ctx)
+
+	rs := w.restarts[change.Unit]
+	if rs == nil {
+		rs = &restartState{}
+		w.restarts[change.Unit] = rs
+	}
+
+	switch change.To {
+	case "active":
+		rs.activeSince = time.Now()
+		if rs.timer != nil {
+			rs.timer.Stop()
+			rs.timer = nil
+		}
+	case "failed":
+		if !w.policy.Enabled {
+			return
+		}
+		// A unit that stayed up longer than the max backoff earns a fresh budget
+		if !rs.activeSince.IsZero() && time.Since(rs.activeSince) > w.policy.MaxBackoff {
+			rs.attempts = 0
+		}
+		w.schedule(change.Unit, rs)
+	}
+}
+
+// schedule arms the next restart attempt with exponential backoff
+func (w *ServiceWatcher) schedule(unit string, rs *restartState) {
+	if w.policy.MaxAttempts > 0 && rs.attempts >= w.policy.MaxAttempts {
+		logger.Warn().
+			Str("unit", unit).
+			Int("attempts", rs.attempts).
+			Msg("Auto-restart attempts exhausted")
+		return
+	}
+	if rs.timer != nil {
+		return
+	}
+
+	delay := w.policy.InitialBackoff << rs.attempts
+	if delay > w.policy.MaxBackoff || delay <= 0 {
+		delay = w.policy.MaxBackoff
+	}
+
+	rs.timer = time.AfterFunc(delay, func() {
+		w.retry <- unit
+	})
+
+	logger.Info().
+		Str("unit", unit).
+		Dur("delay", delay).
+		Msg("Auto-restart scheduled")
+}
+
+// restart runs service_restart through the registry and reports the outcome
+func (w *ServiceWatcher) restart(ctx context.Context, unit string) {
+	rs := w.restarts[unit]
+	rs.timer = nil
+	rs.attempts++
+
+	action := &executor.Action{
+		ID:     "auto-restart-" + time.Now().Format("20060102150405"),
+		Type:   "service_restart",
+		Params: map[string]interface{}{"service": unit},
+	}
+	result := w.registry.Execute(ctx, action)
+
+	event := ServiceEvent{
+		Type:      "auto_restart",
+		Unit:      unit,
+		Message:   fmt.Sprintf("%s auto-restart attempt %d", unit, rs.attempts),
+		Attempt:   rs.attempts,
+		Success:   result.Success,
+		Error:     result.Error,
+		Timestamp: time.Now().UTC(),
+	}
+	w.events.add(event)
+	w.events.flush(ctx)
+
+	if !result.Success {
+		logger.Warn().
+			Str("unit", unit).
+			Int("attempt", rs.attempts).
+			Str("error", result.Error).
+			Msg("Auto-restart failed")
+		w.schedule(unit, rs)
+	}
+}
@@ -0,0 +1,75 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	package_executor "einfra/agent/internal/executor/package"
+	"einfra/agent/internal/logger"
+	"einfra/agent/internal/transport"
+)
+
+// UpdateReporter periodically pushes pending update counts and reboot status
+type UpdateReporter struct {
+	transport *transport.Client
+	source    *package_executor.Executor
+	interval  time.Duration
+}
+
+// NewUpdateReporter creates a pending update reporter
+func NewUpdateReporter(transport *transport.Client, source *package_executor.Executor, interval time.Duration) *UpdateReporter {
+	if interval <= 0 {
+		interval = 6 * time.Hour
+	}
+	return &UpdateReporter{
+		transport: transport,
+		source:    source,
+		interval:  interval,
+	}
+}
+
+// Start reports once immediately, then every interval
+func (r *UpdateReporter) Start(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	logger.Info().Dur("interval", r.interval).Msg("Update reporter started")
+
+	r.report(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info().Msg("Update reporter stopped")
+			return
+		case <-ticker.C:
+			r.report(ctx)
+		}
+	}
+}
+
+// report checks for updates, refreshing repository metadata, and pushes the result
+func (r *UpdateReporter) report(ctx context.Context) {
+	status, err := r.source.CheckUpdates(ctx, true)
+	if err != nil {
+		logger.Warn().Err(err).Msg("Failed to check for updates")
+		return
+	}
+
+	resp, err := r.transport.Post(ctx, "/api/v1/agent/packages/updates", status)
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode >= 400 {
+			err = fmt.Errorf("HTTP %d", resp.StatusCode)
+		}
+	}
+	if err != nil {
+		logger.Warn().Err(err).Msg("Failed to push update status")
+		return
+	}
+
+	logger.Debug().
+		Int("updates", status.Count).
+		Bool("reboot_required", status.Reboot.Required).
+		Msg("Update status pushed")
+}
@@ -0,0 +1,30 @@
+package monitor
+
+import (
+	"time"
+
+	"einfra/agent/internal/executor/user"
+	"einfra/agent/internal/transport"
+)
+
+// UserInventoryReporter pushes the user inventory, sending only the delta
+// against the last inventory the backend acknowledged
+type UserInventoryReporter = inventoryReporter[user.UserRecord]
+
+// NewUserInventoryReporter creates a user inventory reporter
+func NewUserInventoryReporter(transport *transport.Client, source *user.Executor, interval time.Duration, statePath string) *UserInventoryReporter {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	return &UserInventoryReporter{
+		transport: transport,
+		interval:  interval,
+		statePath: statePath,
+		name:      "User inventory",
+		endpoint:  "/api/v1/agent/users/inventory",
+		field:     "users",
+		read:      source.Inventory,
+		checksum:  user.UserChecksum,
+		diff:      user.DiffUsers,
+	}
+}